@@ -31,6 +31,7 @@ type BuildEnv interface {
 	AddEnvDir(envDir string, defaultAction env.ActionType) error
 	WithOverrides(platformDir string, baseConfigDir string) ([]string, error)
 	List() []string
+	Remove(name string)
 }
 
 type Builder struct {
@@ -65,11 +66,17 @@ func (b *Builder) Build() (*files.BuildMetadata, error) {
 	)
 	processMap := newProcessMap()
 	inputs := b.getBuildInputs()
+	environ := os.Environ()
 	if b.AnalyzeMD.RunImage != nil && b.AnalyzeMD.RunImage.TargetMetadata != nil && b.PlatformAPI.AtLeast("0.12") {
-		inputs.Env = env.NewBuildEnv(append(os.Environ(), platform.EnvVarsFor(*b.AnalyzeMD.RunImage.TargetMetadata)...))
-	} else {
-		inputs.Env = env.NewBuildEnv(os.Environ())
+		environ = append(environ, platform.EnvVarsFor(*b.AnalyzeMD.RunImage.TargetMetadata)...)
 	}
+	buildEnvConfig, err := env.LoadBuildEnvConfig(environ)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating build environment")
+	}
+	buildEnv := env.NewBuildEnvFromConfig(environ, buildEnvConfig)
+	buildEnv.Logger = b.Logger
+	inputs.Env = buildEnv
 
 	filteredPlan := b.Plan
 
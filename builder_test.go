@@ -492,6 +492,45 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 					}
 					h.AssertEq(t, foundBuild, expectedBuild)
 				})
+
+				it("does not persist the source of bom entries", func() {
+					builder.Group.Group = []buildpack.GroupElement{
+						{ID: "A", Version: "v1", API: "0.5"},
+					}
+
+					bpA := &buildpack.BpDescriptor{Buildpack: buildpack.BpInfo{BaseInfo: buildpack.BaseInfo{ID: "A", Version: "v1"}}}
+					dirStore.EXPECT().LookupBp("A", "v1").Return(bpA, nil)
+					executor.EXPECT().Build(*bpA, gomock.Any(), gomock.Any()).Return(buildpack.BuildOutputs{
+						BuildBOM: []buildpack.BOMEntry{
+							{
+								Require:   buildpack.Require{Name: "build-dep1"},
+								Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+								Source:    buildpack.BOMSourceBuildTOML,
+							},
+						},
+						LaunchBOM: []buildpack.BOMEntry{
+							{
+								Require:   buildpack.Require{Name: "launch-dep1"},
+								Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+								Source:    buildpack.BOMSourceLaunchTOML,
+							},
+						},
+					}, nil)
+
+					_, err := builder.Build()
+					h.AssertNil(t, err)
+
+					for _, phase := range []string{"launch", "build"} {
+						contents, err := os.ReadFile(filepath.Join(builder.LayersDir, "sbom", phase, "sbom.legacy.json"))
+						h.AssertNil(t, err)
+						var entries []map[string]interface{}
+						h.AssertNil(t, json.Unmarshal(contents, &entries))
+						h.AssertEq(t, len(entries), 1)
+						if _, ok := entries[0]["source"]; ok {
+							t.Fatalf("Expected no source key in %s sbom.legacy.json, got:\n%s", phase, contents)
+						}
+					}
+				})
 			})
 
 			when("buildpacks", func() {
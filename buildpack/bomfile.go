@@ -182,3 +182,15 @@ func (d *BpDescriptor) processSBOMFiles(layersDir string, bp GroupElement, bpLay
 
 	return files, validateMediaTypes(bp, files, d.Buildpack.SBOM)
 }
+
+// launchSBOMFiles returns the SBOM files describing the buildpack's contribution to the launch image as a whole,
+// i.e., <layers>/<buildpack-id>/launch.sbom.<ext>, as opposed to the SBOM files of individual layers.
+func launchSBOMFiles(files []BOMFile) []BOMFile {
+	var launchFiles []BOMFile
+	for _, file := range files {
+		if file.LayerType == LayerTypeLaunch && file.LayerName == "" {
+			launchFiles = append(launchFiles, file)
+		}
+	}
+	return launchFiles
+}
@@ -16,12 +16,13 @@ type BpDescriptor struct {
 	Order       Order            `toml:"order"`
 	WithRootDir string           `toml:"-"`
 	Targets     []TargetMetadata `toml:"targets"`
-	Stacks      []StackMetadata  `tome:"stacks"` // just for backwards compat so we can check if it's the bionic stack, which we translate to a target
+	Stacks      []StackMetadata  `toml:"stacks"` // just for backwards compat so we can check if it's the bionic stack, which we translate to a target
 
 }
 
 type StackMetadata struct {
-	ID string `toml:"id"`
+	ID     string   `toml:"id"`
+	Mixins []string `toml:"mixins"`
 }
 
 type TargetMetadata struct {
@@ -51,6 +52,9 @@ func (d OSDistribution) String() string {
 type BpInfo struct {
 	BaseInfo
 	SBOM []string `toml:"sbom-formats,omitempty" json:"sbom-formats,omitempty"`
+	// SkipBuildIfPlanEmpty declares that the buildpack does nothing when it receives no buildpack plan entries,
+	// allowing the lifecycle to skip running bin/build in that case.
+	SkipBuildIfPlanEmpty bool `toml:"skip-build-if-plan-empty,omitempty" json:"skip-build-if-plan-empty,omitempty"`
 }
 
 type Order []Group
@@ -120,6 +124,12 @@ func (d *BpDescriptor) RootDir() string {
 	return d.WithRootDir
 }
 
+// IsMetaBuildpack returns true if the buildpack declares an order, which makes it a meta-buildpack
+// that composes other buildpacks rather than providing a bin/build of its own.
+func (d *BpDescriptor) IsMetaBuildpack() bool {
+	return len(d.Order) > 0
+}
+
 func (d *BpDescriptor) String() string {
 	return d.Buildpack.Name + " " + d.Buildpack.Version
 }
@@ -7,9 +7,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
-
-	"github.com/BurntSushi/toml"
+	"time"
 
 	"github.com/buildpacks/lifecycle/api"
 	"github.com/buildpacks/lifecycle/env"
@@ -26,6 +27,11 @@ const (
 	// EnvLayersDir is the absolute path of the buildpack layers directory (read-write); a different copy is provided for each buildpack;
 	// contents may be saved to either or both of: the final output image or the cache
 	EnvLayersDir = "CNB_LAYERS_DIR"
+	// EnvBuildPlanOnly is set to "true" when the buildpack is run only to report which buildpack plan entries it would satisfy;
+	// see BuildInputs.PlanOnly
+	EnvBuildPlanOnly = "CNB_BUILD_PLAN_ONLY"
+	// EnvStackID is the stack ID; see BuildInputs.StackID
+	EnvStackID = "CNB_STACK_ID"
 	// Also provided during build: EnvBuildpackDir, EnvPlatformDir (see detect.go)
 )
 
@@ -36,7 +42,93 @@ type BuildInputs struct {
 	PlatformDir    string
 	Env            BuildEnv
 	Out, Err       io.Writer
-	Plan           Plan
+	// In, if provided, is connected to the stdin of the buildpack's bin/build process; by default the process has no stdin.
+	In   io.Reader
+	Plan Plan
+	// RequireBOM, if true, causes Build to fail when a buildpack that satisfied requires declares no BOM entries or SBOM files.
+	RequireBOM bool
+	// RequireBOMExempt lists the IDs of buildpacks that are not subject to RequireBOM.
+	RequireBOMExempt []string
+	// PostBuild, if provided, is invoked with the outputs of the buildpack after they are read;
+	// if it returns an error, Build fails with a *PostBuildError.
+	PostBuild func(BuildOutputs) error
+	// LaunchTOMLName and BuildTOMLName, if provided, override the names of the files read from the buildpack's layers directory;
+	// they default to launch.toml and build.toml.
+	LaunchTOMLName string
+	BuildTOMLName  string
+	// PlanOnly if true runs the buildpack with CNB_BUILD_PLAN_ONLY=true, asking it to only report which plan entries it would satisfy;
+	// after running, only MetRequires is read and the buildpack's layers are not processed.
+	// The buildpack must cooperate in order for no other work to be done.
+	PlanOnly bool
+	// StackID, if provided, is set as CNB_STACK_ID in the buildpack's environment (even when the buildpack clears the environment),
+	// overriding any value from the lifecycle's environment.
+	StackID string
+	// DirMode, if provided, is the permission mode of the buildpack's layers directory and plan directory
+	// when they are created; it defaults to 0777. As with os.MkdirAll, the process umask is applied to the mode.
+	DirMode os.FileMode
+	// Clock, if provided, is used to measure BuildOutputs.Duration; it defaults to SystemClock.
+	Clock Clock
+	// StrictLabelKeys, if true, causes Build to fail when launch.toml declares a label whose key is not
+	// a lowercase reverse-DNS key (see ValidateLabelKey), or whose key is in the reserved io.buildpacks. namespace.
+	StrictLabelKeys bool
+	// FailOnUnmet, if true, causes Build to fail when the buildpack declares any buildpack plan entries as unmet in build.toml.
+	FailOnUnmet bool
+	// ReservedLabelKeysExempt lists the IDs of buildpacks that may declare labels in the reserved io.buildpacks. namespace
+	// when StrictLabelKeys is set.
+	ReservedLabelKeysExempt []string
+	// TrackEnvChanges, if true, causes Build to report the changes the buildpack's layers made to the build environment
+	// in BuildOutputs.EnvChanges; this is intended for debugging.
+	TrackEnvChanges bool
+	// ResourceLimits, if provided, constrain the memory and CPU available to the buildpack's bin/build process (Linux only).
+	ResourceLimits ResourceLimits
+	// DetectStray, if true, causes Build to compare the directories under LayersDir before and after running the buildpack,
+	// and to add a warning to BuildOutputs.Warnings for each directory the buildpack created outside its own layers directory
+	// (and AppDir, if it is within LayersDir).
+	// This walks the entire layers directory twice, and may report directories created by other buildpacks built concurrently.
+	DetectStray bool
+	// VerifyPlan, if true, causes Build to read back the buildpack plan file after writing it,
+	// and to fail before running the buildpack if the entry names or presence of metadata differ from Plan.
+	VerifyPlan bool
+	// GlobalEnvFile, if provided, is the path to a file of KEY=VALUE lines that are merged into the environment of every buildpack
+	// that does not clear its environment, after the same filtering applied to the lifecycle's environment (see env.NewBuildEnv).
+	// Values provided in <platform>/env and values already in the build environment take precedence,
+	// except that global values are prepended to root dir variables such as PATH.
+	GlobalEnvFile string
+}
+
+func (i BuildInputs) dirMode() os.FileMode {
+	if i.DirMode == 0 {
+		return 0777
+	}
+	return i.DirMode
+}
+
+func (i BuildInputs) launchTOMLName() string {
+	if i.LaunchTOMLName == "" {
+		return "launch.toml"
+	}
+	return i.LaunchTOMLName
+}
+
+func (i BuildInputs) buildTOMLName() string {
+	if i.BuildTOMLName == "" {
+		return "build.toml"
+	}
+	return i.BuildTOMLName
+}
+
+// PostBuildError is returned by Build when the BuildInputs.PostBuild hook rejects the outputs of a buildpack.
+type PostBuildError struct {
+	Buildpack string
+	Err       error
+}
+
+func (e *PostBuildError) Error() string {
+	return fmt.Sprintf("post-build check rejected buildpack %s: %s", e.Buildpack, e.Err)
+}
+
+func (e *PostBuildError) Unwrap() error {
+	return e.Err
 }
 
 type BuildEnv interface {
@@ -44,16 +136,75 @@ type BuildEnv interface {
 	AddEnvDir(envDir string, defaultAction env.ActionType) error
 	WithOverrides(platformDir string, buildConfigDir string) ([]string, error)
 	List() []string
+	Remove(name string)
 }
 
+const (
+	// BuildOutputsSchemaVersion is the schema version of the BuildOutputs returned by this lifecycle.
+	BuildOutputsSchemaVersion = "1"
+	// BuildOutputsSchemaVersionUnversioned is assumed for serialized BuildOutputs that predate the SchemaVersion field.
+	BuildOutputsSchemaVersionUnversioned = "0"
+)
+
 type BuildOutputs struct {
-	BOMFiles    []BOMFile
-	BuildBOM    []BOMEntry
-	Labels      []Label
-	LaunchBOM   []BOMEntry
-	MetRequires []string
-	Processes   []launch.Process
-	Slices      []layers.Slice
+	SchemaVersion      string // see BuildOutputsSchemaVersion; empty in BuildOutputs serialized by older lifecycles
+	BOMFiles           []BOMFile
+	BuildBOM           []BOMEntry  // entries from build.toml; only the launch-phase BOM is exported to the app image
+	CachedDependencies []CachedDep // entries from cache.toml, followed by entries derived from cached layers not described in cache.toml
+	Labels             []Label
+	LaunchBOM          []BOMEntry // entries from launch.toml, or from the output buildpack plan for Buildpack API < 0.5
+	LaunchSBOM         []BOMFile  // the top-level launch.sbom.<ext> files in the buildpack's layers directory; these are also included in BOMFiles
+	MetRequires        []string
+	NoOp               bool          // true if bin/build was not run because the buildpack opted out of building with an empty plan
+	Warnings           []string      // from warnings.toml, then any from BuildInputs.DetectStray; non-fatal warnings for the platform to relay to the user
+	Duration           time.Duration // time taken by bin/build, as measured by BuildInputs.Clock; zero if the command was not run
+	EnvChanges         []EnvChange   // changes made to the build environment by the buildpack's layers, sorted by key; only set if BuildInputs.TrackEnvChanges
+	Processes          []launch.Process
+	Slices             []layers.Slice
+}
+
+// Schema returns the schema version of the BuildOutputs, treating a missing version as the earliest schema.
+func (b BuildOutputs) Schema() string {
+	if b.SchemaVersion == "" {
+		return BuildOutputsSchemaVersionUnversioned
+	}
+	return b.SchemaVersion
+}
+
+// WriteFile writes the BuildOutputs to the TOML file at path, replacing any existing file atomically,
+// so that they can be read back with ReadBuildOutputs (e.g., by a later phase).
+// Process commands are always written as arrays; Process.PlatformAPI and BOMEntry.Source are not persisted.
+func (b BuildOutputs) WriteFile(path string) error {
+	if b.Processes != nil {
+		processes := make([]launch.Process, len(b.Processes))
+		for i, proc := range b.Processes {
+			if proc.Command.PlatformAPI == nil {
+				proc.Command = proc.Command.WithPlatformAPI(api.Platform.Latest())
+			}
+			processes[i] = proc
+		}
+		b.Processes = processes
+	}
+	if err := encoding.WriteTOMLAtomic(path, b); err != nil {
+		return fmt.Errorf("writing build outputs to '%s': %w", path, err)
+	}
+	return nil
+}
+
+// ReadBuildOutputs reads BuildOutputs previously written with BuildOutputs.WriteFile.
+func ReadBuildOutputs(path string) (BuildOutputs, error) {
+	var outputs BuildOutputs
+	if err := encoding.DecodeTOMLFile(path, &outputs); err != nil {
+		return BuildOutputs{}, fmt.Errorf("reading build outputs from '%s': %w", path, err)
+	}
+	return outputs, nil
+}
+
+// BOM returns the launch-phase BOM entries followed by the build-phase BOM entries.
+func (b BuildOutputs) BOM() []BOMEntry {
+	bom := make([]BOMEntry, 0, len(b.LaunchBOM)+len(b.BuildBOM))
+	bom = append(bom, b.LaunchBOM...)
+	return append(bom, b.BuildBOM...)
 }
 
 //go:generate mockgen -package testmock -destination ../testmock/build_executor.go github.com/buildpacks/lifecycle/buildpack BuildExecutor
@@ -61,14 +212,59 @@ type BuildExecutor interface {
 	Build(d BpDescriptor, inputs BuildInputs, logger log.Logger) (BuildOutputs, error)
 }
 
-type DefaultBuildExecutor struct{}
+// TOMLDecoder decodes TOML files into values.
+// As with os.Open, it must return an error satisfying os.IsNotExist when the file does not exist.
+type TOMLDecoder interface {
+	DecodeTOMLFile(path string, v interface{}) error
+}
+
+type DefaultBuildExecutor struct {
+	// TOMLDecoder, if provided, is used to read the buildpack plan and the plan.toml, build.toml, cache.toml and warnings.toml files
+	// output by the buildpack; it defaults to github.com/BurntSushi/toml.
+	// launch.toml and <layer>.toml files are always read with github.com/BurntSushi/toml,
+	// because how they are decoded depends on which keys they define.
+	TOMLDecoder TOMLDecoder
+}
+
+func (e *DefaultBuildExecutor) tomlDecoder() TOMLDecoder {
+	if e.TOMLDecoder == nil {
+		return encoding.BurntSushiTOML{}
+	}
+	return e.TOMLDecoder
+}
 
 func (e *DefaultBuildExecutor) Build(d BpDescriptor, inputs BuildInputs, logger log.Logger) (BuildOutputs, error) {
+	return e.build(d, inputs, "", logger)
+}
+
+// BuildFromPlanFile is like Build, but provides the buildpack with the contents of the existing buildpack plan file at planFile
+// instead of a plan serialized from inputs.Plan, so that the plan the buildpack receives is not subject to a decode/encode round-trip.
+// The plan file is still decoded into inputs.Plan to determine which requires the buildpack met.
+// For Buildpack API 0.2, whose plan entries must be converted, the plan is re-encoded as with Build.
+func (e *DefaultBuildExecutor) BuildFromPlanFile(d BpDescriptor, planFile string, inputs BuildInputs, logger log.Logger) (BuildOutputs, error) {
+	inputs.Plan = Plan{}
+	if err := e.tomlDecoder().DecodeTOMLFile(planFile, &inputs.Plan); err != nil {
+		return BuildOutputs{}, fmt.Errorf("reading buildpack plan for buildpack %s: %w", d.Buildpack.ID, err)
+	}
+	return e.build(d, inputs, planFile, logger)
+}
+
+func (e *DefaultBuildExecutor) build(d BpDescriptor, inputs BuildInputs, planFile string, logger log.Logger) (BuildOutputs, error) {
+	if d.IsMetaBuildpack() {
+		return BuildOutputs{}, fmt.Errorf("buildpack %s declares an order: meta-buildpack cannot be built directly", d.Buildpack.ID)
+	}
+
 	if api.MustParse(d.WithAPI).Equal(api.MustParse("0.2")) {
 		logger.Debug("Updating plan entries")
 		for i := range inputs.Plan.Entries {
 			inputs.Plan.Entries[i].convertMetadataToVersion()
 		}
+		planFile = ""
+	}
+
+	if d.Buildpack.SkipBuildIfPlanEmpty && len(inputs.Plan.Entries) == 0 {
+		logger.Debugf("Skipping build for buildpack %s: buildpack plan is empty", d.Buildpack.ID)
+		return BuildOutputs{SchemaVersion: BuildOutputsSchemaVersion, NoOp: true}, nil
 	}
 
 	logger.Debug("Creating plan directory")
@@ -79,15 +275,59 @@ func (e *DefaultBuildExecutor) Build(d BpDescriptor, inputs BuildInputs, logger
 	defer os.RemoveAll(planDir)
 
 	logger.Debug("Preparing paths")
-	bpLayersDir, planPath, err := prepareInputPaths(d.Buildpack.ID, inputs.Plan, inputs.LayersDir, planDir)
+	bpLayersDir, planPath, err := prepareInputPaths(d.Buildpack.ID, inputs.Plan, planFile, inputs.LayersDir, planDir, inputs.dirMode())
 	if err != nil {
 		return BuildOutputs{}, err
 	}
+	if inputs.VerifyPlan {
+		logger.Debug("Verifying plan")
+		if err = verifyPlanFile(planPath, inputs.Plan, e.tomlDecoder()); err != nil {
+			return BuildOutputs{}, err
+		}
+	}
+
+	var (
+		dirsBefore  map[string]struct{}
+		allowedDirs = []string{bpLayersDir, filepath.Clean(inputs.AppDir)}
+	)
+	if inputs.DetectStray {
+		logger.Debug("Recording layers directory contents")
+		if dirsBefore, err = snapshotDirs(inputs.LayersDir, allowedDirs); err != nil {
+			return BuildOutputs{}, fmt.Errorf("recording contents of layers directory: %w", err)
+		}
+	}
 
 	logger.Debug("Running build command")
-	if err := runBuildCmd(d, bpLayersDir, planPath, inputs, inputs.Env); err != nil {
+	clock := clockOrDefault(inputs.Clock)
+	start := clock.Now()
+	if err := runBuildCmd(d, bpLayersDir, planPath, inputs); err != nil {
 		return BuildOutputs{}, err
 	}
+	duration := clock.Now().Sub(start)
+	logger.Debugf("Build command for buildpack %s completed in %s", d.Buildpack.ID, duration)
+
+	var strayWarnings []string
+	if inputs.DetectStray {
+		stray, err := strayDirs(inputs.LayersDir, allowedDirs, dirsBefore)
+		if err != nil {
+			return BuildOutputs{}, fmt.Errorf("checking contents of layers directory: %w", err)
+		}
+		for _, dir := range stray {
+			logger.Warnf("Buildpack %s created directory '%s' outside of its layers directory", d.Buildpack.ID, dir)
+			strayWarnings = append(strayWarnings, fmt.Sprintf("created directory '%s' outside of its layers directory", dir))
+		}
+	}
+
+	if inputs.PlanOnly {
+		logger.Debug("Reading plan output")
+		br, err := d.readPlanOutputBp(bpLayersDir, planPath, inputs, e.tomlDecoder())
+		if err != nil {
+			return BuildOutputs{}, err
+		}
+		br.Duration = duration
+		br.Warnings = append(br.Warnings, strayWarnings...)
+		return br, nil
+	}
 
 	logger.Debug("Processing layers")
 	createdLayers, err := d.processLayers(bpLayersDir, logger)
@@ -96,37 +336,193 @@ func (e *DefaultBuildExecutor) Build(d BpDescriptor, inputs BuildInputs, logger
 	}
 
 	logger.Debug("Updating environment")
+	var envBefore []string
+	if inputs.TrackEnvChanges {
+		envBefore = inputs.Env.List()
+	}
 	if err := d.setupEnv(createdLayers, inputs.Env); err != nil {
 		return BuildOutputs{}, err
 	}
+	var envChanges []EnvChange
+	if inputs.TrackEnvChanges {
+		envChanges = diffEnv(envBefore, inputs.Env.List())
+	}
 
 	logger.Debug("Reading output files")
-	return d.readOutputFilesBp(bpLayersDir, planPath, inputs.Plan, createdLayers, logger)
+	br, err := d.readOutputFilesBp(bpLayersDir, planPath, inputs, createdLayers, e.tomlDecoder(), logger)
+	if err != nil {
+		return BuildOutputs{}, err
+	}
+	br.Duration = duration
+	br.EnvChanges = envChanges
+	br.Warnings = append(br.Warnings, strayWarnings...)
+	if err = d.checkRequiredBOM(br, inputs); err != nil {
+		return BuildOutputs{}, err
+	}
+	if inputs.PostBuild != nil {
+		if err = inputs.PostBuild(br); err != nil {
+			return BuildOutputs{}, &PostBuildError{Buildpack: d.Buildpack.ID, Err: err}
+		}
+	}
+	return br, nil
+}
+
+func (d BpDescriptor) checkRequiredBOM(br BuildOutputs, inputs BuildInputs) error {
+	if !inputs.RequireBOM || len(br.MetRequires) == 0 {
+		return nil
+	}
+	for _, exemptID := range inputs.RequireBOMExempt {
+		if exemptID == d.Buildpack.ID {
+			return nil
+		}
+	}
+	if len(br.LaunchBOM) == 0 && len(br.BuildBOM) == 0 && len(br.BOMFiles) == 0 {
+		return fmt.Errorf("buildpack %s satisfied requires %v but did not declare a BOM", d.Buildpack.ID, br.MetRequires)
+	}
+	return nil
 }
 
-func prepareInputPaths(bpID string, plan Plan, layersDir, parentPlanDir string) (string, string, error) {
-	bpDirName := launch.EscapeID(bpID) // FIXME: this logic should eventually move to the platform package
+// ReservedLabelKeyPrefix is the label namespace reserved for the lifecycle and platforms.
+const ReservedLabelKeyPrefix = "io.buildpacks."
+
+var labelKeyRegexp = regexp.MustCompile(`^[a-z0-9]+([.-][a-z0-9]+)*$`)
+
+// ValidateLabelKey returns an error if the provided key does not follow the reverse-DNS convention for OCI and Docker
+// label keys: lowercase alphanumeric components separated by single periods or hyphens, e.g., com.example.some-key.
+func ValidateLabelKey(key string) error {
+	if !labelKeyRegexp.MatchString(key) {
+		return errors.New("must consist of lowercase alphanumeric characters separated by single periods or hyphens")
+	}
+	return nil
+}
 
+func (d BpDescriptor) validateLabelKeys(labels []Label, inputs BuildInputs) error {
+	reservedAllowed := false
+	for _, exemptID := range inputs.ReservedLabelKeysExempt {
+		if exemptID == d.Buildpack.ID {
+			reservedAllowed = true
+			break
+		}
+	}
+	for _, label := range labels {
+		if err := ValidateLabelKey(label.Key); err != nil {
+			return fmt.Errorf("buildpack %s declared invalid label key '%s': %w", d.Buildpack.ID, label.Key, err)
+		}
+		if !reservedAllowed && strings.HasPrefix(label.Key, ReservedLabelKeyPrefix) {
+			return fmt.Errorf("buildpack %s declared label key '%s' in the reserved '%s' namespace", d.Buildpack.ID, label.Key, ReservedLabelKeyPrefix)
+		}
+	}
+	return nil
+}
+
+// LayersDirFor returns the layers directory of the buildpack with the provided ID, e.g., <layers>/<buildpack-id>.
+func LayersDirFor(layersDir, bpID string) string {
+	return filepath.Join(layersDir, launch.EscapeID(bpID)) // FIXME: this logic should eventually move to the platform package
+}
+
+// PlanPathFor returns the path of the buildpack plan provided to the buildpack with the provided ID, e.g., <plan>/<buildpack-id>/plan.toml.
+func PlanPathFor(planDir, bpID string) string {
+	// FIXME: it's unclear if the child directory is necessary; consider removing
+	return filepath.Join(planDir, launch.EscapeID(bpID), "plan.toml")
+}
+
+// prepareInputPaths creates the layers (or output) directory and the plan file for the buildpack (or extension).
+// If planFile is provided, its contents are copied to the plan file as-is; otherwise the plan is encoded.
+func prepareInputPaths(bpID string, plan Plan, planFile, layersDir, parentPlanDir string, dirMode os.FileMode) (string, string, error) {
 	// Create e.g., <layers>/<buildpack-id> or <output>/<extension-id>
-	bpLayersDir := filepath.Join(layersDir, bpDirName)
-	if err := os.MkdirAll(bpLayersDir, 0777); err != nil {
+	bpLayersDir := LayersDirFor(layersDir, bpID)
+	if err := os.MkdirAll(bpLayersDir, dirMode); err != nil {
 		return "", "", err
 	}
 
 	// Create Buildpack Plan
-	childPlanDir := filepath.Join(parentPlanDir, bpDirName) // FIXME: it's unclear if this child directory is necessary; consider removing
-	if err := os.MkdirAll(childPlanDir, 0777); err != nil {
+	planPath := PlanPathFor(parentPlanDir, bpID)
+	if err := os.MkdirAll(filepath.Dir(planPath), dirMode); err != nil {
 		return "", "", err
 	}
-	planPath := filepath.Join(childPlanDir, "plan.toml")
-	if err := encoding.WriteTOML(planPath, plan); err != nil {
+	if planFile != "" {
+		contents, err := os.ReadFile(planFile)
+		if err != nil {
+			return "", "", err
+		}
+		if err = os.WriteFile(planPath, contents, 0666); err != nil {
+			return "", "", err
+		}
+	} else if err := encoding.WriteTOML(planPath, plan); err != nil {
 		return "", "", err
 	}
 
 	return bpLayersDir, planPath, nil
 }
 
-func runBuildCmd(d BpDescriptor, bpLayersDir, planPath string, inputs BuildInputs, buildEnv BuildEnv) error {
+// verifyPlanFile reads the buildpack plan at planPath and checks that its entries match those of the expected plan
+// in name and in whether they have metadata.
+func verifyPlanFile(planPath string, expected Plan, decoder TOMLDecoder) error {
+	var actual Plan
+	if err := decoder.DecodeTOMLFile(planPath, &actual); err != nil {
+		return fmt.Errorf("verifying buildpack plan: reading '%s': %w", planPath, err)
+	}
+	if len(actual.Entries) != len(expected.Entries) {
+		return fmt.Errorf("verifying buildpack plan: expected %d entries, found %d", len(expected.Entries), len(actual.Entries))
+	}
+	for i, entry := range expected.Entries {
+		if actual.Entries[i].Name != entry.Name {
+			return fmt.Errorf("verifying buildpack plan: expected entry %d to be '%s', found '%s'", i, entry.Name, actual.Entries[i].Name)
+		}
+		if (len(actual.Entries[i].Metadata) > 0) != (len(entry.Metadata) > 0) {
+			return fmt.Errorf("verifying buildpack plan: metadata for entry '%s' did not survive encoding", entry.Name)
+		}
+	}
+	return nil
+}
+
+// ResolveBuildEnv returns the environment that the buildpack's bin/build would be run with,
+// given the environment accumulated so far in inputs.Env (including contributions from the layers of previous buildpacks),
+// without running the buildpack.
+// The provided planPath is the value of CNB_BP_PLAN_PATH for buildpacks that receive it.
+func ResolveBuildEnv(d BpDescriptor, inputs BuildInputs, planPath string) ([]string, error) {
+	dirs := Dirs{BuildpackDir: d.WithRootDir}
+	if api.MustParse(d.WithAPI).AtLeast("0.8") {
+		dirs.PlatformDir = inputs.PlatformDir
+		dirs.PlanPath = planPath
+		dirs.LayersDir = LayersDirFor(inputs.LayersDir, d.Buildpack.ID)
+	}
+	cnbVars := CNBVars(PhaseBuild, dirs)
+	if inputs.PlanOnly {
+		cnbVars = append(cnbVars, EnvBuildPlanOnly+"=true")
+	}
+	if inputs.StackID != "" {
+		cnbVars = append(cnbVars, EnvStackID+"="+inputs.StackID)
+	}
+	environ, err := prepareEnv(inputs.Env, d.Buildpack.ClearEnv, inputs.PlatformDir, inputs.BuildConfigDir, inputs.GlobalEnvFile, cnbVars...)
+	if err != nil {
+		return nil, err
+	}
+	if inputs.StackID != "" {
+		environ = withoutEarlierValues(environ, EnvStackID)
+	}
+	return environ, nil
+}
+
+// withoutEarlierValues removes all but the last value of the named variable from environ.
+func withoutEarlierValues(environ []string, name string) []string {
+	last := -1
+	for idx, kv := range environ {
+		if strings.HasPrefix(kv, name+"=") {
+			last = idx
+		}
+	}
+	var ret []string
+	for idx, kv := range environ {
+		if idx != last && strings.HasPrefix(kv, name+"=") {
+			continue
+		}
+		ret = append(ret, kv)
+	}
+	return ret
+}
+
+func runBuildCmd(d BpDescriptor, bpLayersDir, planPath string, inputs BuildInputs) error {
 	cmd := exec.Command(
 		filepath.Join(d.WithRootDir, "bin", "build"),
 		bpLayersDir,
@@ -134,29 +530,24 @@ func runBuildCmd(d BpDescriptor, bpLayersDir, planPath string, inputs BuildInput
 		planPath,
 	) // #nosec G204
 	cmd.Dir = inputs.AppDir
+	cmd.Stdin = inputs.In
 	cmd.Stdout = inputs.Out
 	cmd.Stderr = inputs.Err
 
 	var err error
-	if d.Buildpack.ClearEnv {
-		cmd.Env, err = buildEnv.WithOverrides("", inputs.BuildConfigDir)
-	} else {
-		cmd.Env, err = buildEnv.WithOverrides(inputs.PlatformDir, inputs.BuildConfigDir)
-	}
+	cmd.Env, err = ResolveBuildEnv(d, inputs, planPath)
 	if err != nil {
 		return err
 	}
-	cmd.Env = append(cmd.Env, EnvBuildpackDir+"="+d.WithRootDir)
-	if api.MustParse(d.WithAPI).AtLeast("0.8") {
-		cmd.Env = append(cmd.Env,
-			EnvPlatformDir+"="+inputs.PlatformDir,
-			EnvBpPlanPath+"="+planPath,
-			EnvLayersDir+"="+bpLayersDir,
-		)
+
+	cleanup, err := applyResourceLimits(cmd, d.Buildpack.ID, inputs.ResourceLimits)
+	if err != nil {
+		return fmt.Errorf("applying resource limits for buildpack %s: %w", d.Buildpack.ID, err)
 	}
+	defer cleanup()
 
 	if err = cmd.Run(); err != nil {
-		return NewError(err, ErrTypeBuildpack)
+		return newExecError(err, ErrTypeBuildpack)
 	}
 	return nil
 }
@@ -215,6 +606,8 @@ func renameLayerDirIfNeeded(layerMetadataFile LayerMetadataFile, layerDir string
 	return nil
 }
 
+// setupEnv adds the env of each of the buildpack's build layers to buildEnv;
+// the env of layers that are not build layers (e.g., launch-only layers) is not applied during the build.
 func (d BpDescriptor) setupEnv(createdLayers map[string]LayerMetadataFile, buildEnv BuildEnv) error {
 	bpAPI := api.MustParse(d.WithAPI)
 	for path, layerMetadataFile := range createdLayers {
@@ -234,26 +627,34 @@ func (d BpDescriptor) setupEnv(createdLayers map[string]LayerMetadataFile, build
 	return nil
 }
 
-func (d BpDescriptor) readOutputFilesBp(bpLayersDir, bpPlanPath string, bpPlanIn Plan, bpLayers map[string]LayerMetadataFile, logger log.Logger) (BuildOutputs, error) {
-	br := BuildOutputs{}
+func (d BpDescriptor) readOutputFilesBp(bpLayersDir, bpPlanPath string, inputs BuildInputs, bpLayers map[string]LayerMetadataFile, decoder TOMLDecoder, logger log.Logger) (BuildOutputs, error) {
+	br := BuildOutputs{SchemaVersion: BuildOutputsSchemaVersion}
 	bpFromBpInfo := GroupElement{ID: d.Buildpack.ID, Version: d.Buildpack.Version}
 
 	// setup launch.toml
 	var launchTOML LaunchTOML
-	launchPath := filepath.Join(bpLayersDir, "launch.toml")
+	launchPath := filepath.Join(bpLayersDir, inputs.launchTOMLName())
 
 	bomValidator := NewBOMValidator(d.WithAPI, bpLayersDir, logger)
 
 	var err error
+	br.CachedDependencies, err = readCachedDependencies(bpLayersDir, bpLayers, decoder)
+	if err != nil {
+		return BuildOutputs{}, err
+	}
+	br.Warnings, err = readWarnings(bpLayersDir, decoder)
+	if err != nil {
+		return BuildOutputs{}, err
+	}
 	if api.MustParse(d.WithAPI).LessThan("0.5") {
 		// read buildpack plan
 		var bpPlanOut Plan
-		if _, err := toml.DecodeFile(bpPlanPath, &bpPlanOut); err != nil {
+		if err := decoder.DecodeTOMLFile(bpPlanPath, &bpPlanOut); err != nil {
 			return BuildOutputs{}, err
 		}
 
 		// set BOM and MetRequires
-		br.LaunchBOM, err = bomValidator.ValidateBOM(bpFromBpInfo, bpPlanOut.toBOM())
+		br.LaunchBOM, err = bomValidator.ValidateBOM(bpFromBpInfo, bpPlanOut.toBOM(inputs.Plan))
 		if err != nil {
 			return BuildOutputs{}, err
 		}
@@ -264,6 +665,7 @@ func (d BpDescriptor) readOutputFilesBp(bpLayersDir, bpPlanPath string, bpPlanIn
 		if err != nil {
 			return BuildOutputs{}, err
 		}
+		br.LaunchSBOM = launchSBOMFiles(br.BOMFiles)
 
 		// read launch.toml, return if not exists
 		if err := DecodeLaunchTOML(launchPath, d.WithAPI, &launchTOML); os.IsNotExist(err) {
@@ -274,29 +676,33 @@ func (d BpDescriptor) readOutputFilesBp(bpLayersDir, bpPlanPath string, bpPlanIn
 	} else {
 		// read build.toml
 		var buildTOML BuildTOML
-		buildPath := filepath.Join(bpLayersDir, "build.toml")
-		if _, err := toml.DecodeFile(buildPath, &buildTOML); err != nil && !os.IsNotExist(err) {
+		buildPath := filepath.Join(bpLayersDir, inputs.buildTOMLName())
+		if err := decoder.DecodeTOMLFile(buildPath, &buildTOML); err != nil && !os.IsNotExist(err) {
 			return BuildOutputs{}, err
 		}
 		if _, err := bomValidator.ValidateBOM(bpFromBpInfo, buildTOML.BOM); err != nil {
 			return BuildOutputs{}, err
 		}
-		br.BuildBOM, err = bomValidator.ValidateBOM(bpFromBpInfo, buildTOML.BOM)
+		br.BuildBOM, err = bomValidator.ValidateBOM(bpFromBpInfo, withSource(buildTOML.BOM, BOMSourceBuildTOML))
 		if err != nil {
 			return BuildOutputs{}, err
 		}
 
 		// set MetRequires
-		if err := validateUnmet(buildTOML.Unmet, bpPlanIn); err != nil {
+		if err := validateUnmet(buildTOML.Unmet, inputs.Plan); err != nil {
 			return BuildOutputs{}, err
 		}
-		br.MetRequires = names(bpPlanIn.filter(buildTOML.Unmet).Entries)
+		if inputs.FailOnUnmet && len(buildTOML.Unmet) > 0 {
+			return BuildOutputs{}, fmt.Errorf("buildpack %s did not meet requires %v", d.Buildpack.ID, unmetNames(buildTOML.Unmet))
+		}
+		br.MetRequires = names(inputs.Plan.filter(buildTOML.Unmet).Entries)
 
 		// set BOM files
 		br.BOMFiles, err = d.processSBOMFiles(bpLayersDir, bpFromBpInfo, bpLayers, logger)
 		if err != nil {
 			return BuildOutputs{}, err
 		}
+		br.LaunchSBOM = launchSBOMFiles(br.BOMFiles)
 
 		// read launch.toml, return if not exists
 		if err := DecodeLaunchTOML(launchPath, d.WithAPI, &launchTOML); os.IsNotExist(err) {
@@ -306,7 +712,7 @@ func (d BpDescriptor) readOutputFilesBp(bpLayersDir, bpPlanPath string, bpPlanIn
 		}
 
 		// set BOM
-		br.LaunchBOM, err = bomValidator.ValidateBOM(bpFromBpInfo, launchTOML.BOM)
+		br.LaunchBOM, err = bomValidator.ValidateBOM(bpFromBpInfo, withSource(launchTOML.BOM, BOMSourceLaunchTOML))
 		if err != nil {
 			return BuildOutputs{}, err
 		}
@@ -321,6 +727,11 @@ func (d BpDescriptor) readOutputFilesBp(bpLayersDir, bpPlanPath string, bpPlanIn
 	}
 
 	// set data from launch.toml
+	if inputs.StrictLabelKeys {
+		if err := d.validateLabelKeys(launchTOML.Labels, inputs); err != nil {
+			return BuildOutputs{}, err
+		}
+	}
 	br.Labels = append([]Label{}, launchTOML.Labels...)
 	for i := range launchTOML.Processes {
 		if api.MustParse(d.WithAPI).LessThan("0.8") {
@@ -330,12 +741,85 @@ func (d BpDescriptor) readOutputFilesBp(bpLayersDir, bpPlanPath string, bpPlanIn
 			}
 		}
 	}
-	br.Processes = append([]launch.Process{}, launchTOML.ToLaunchProcessesForBuildpack(d.Buildpack.ID)...)
+	br.Processes = launchTOML.ToLaunchProcessesForBuildpack(d.Buildpack.ID)
 	br.Slices = append([]layers.Slice{}, launchTOML.Slices...)
 
 	return br, nil
 }
 
+// readPlanOutputBp reads the buildpack plan entries met by the buildpack,
+// from the output buildpack plan for Buildpack API < 0.5, or from the unmet entries in build.toml otherwise.
+func (d BpDescriptor) readPlanOutputBp(bpLayersDir, bpPlanPath string, inputs BuildInputs, decoder TOMLDecoder) (BuildOutputs, error) {
+	if api.MustParse(d.WithAPI).LessThan("0.5") {
+		var bpPlanOut Plan
+		if err := decoder.DecodeTOMLFile(bpPlanPath, &bpPlanOut); err != nil {
+			return BuildOutputs{}, err
+		}
+		return BuildOutputs{SchemaVersion: BuildOutputsSchemaVersion, MetRequires: names(bpPlanOut.Entries)}, nil
+	}
+	var buildTOML BuildTOML
+	if err := decoder.DecodeTOMLFile(filepath.Join(bpLayersDir, inputs.buildTOMLName()), &buildTOML); err != nil && !os.IsNotExist(err) {
+		return BuildOutputs{}, err
+	}
+	if err := validateUnmet(buildTOML.Unmet, inputs.Plan); err != nil {
+		return BuildOutputs{}, err
+	}
+	return BuildOutputs{SchemaVersion: BuildOutputsSchemaVersion, MetRequires: names(inputs.Plan.filter(buildTOML.Unmet).Entries)}, nil
+}
+
+// readWarnings reads the warnings described in warnings.toml, if present.
+func readWarnings(bpLayersDir string, decoder TOMLDecoder) ([]string, error) {
+	var warningsTOML WarningsTOML
+	if err := decoder.DecodeTOMLFile(filepath.Join(bpLayersDir, "warnings.toml"), &warningsTOML); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading warnings.toml: %w", err)
+	}
+	return warningsTOML.Warnings, nil
+}
+
+// readCachedDependencies reads the dependencies described in cache.toml,
+// and adds a dependency for each cached layer that cache.toml does not mention, using the name and version from the layer metadata if present.
+func readCachedDependencies(bpLayersDir string, bpLayers map[string]LayerMetadataFile, decoder TOMLDecoder) ([]CachedDep, error) {
+	var cacheTOML CacheTOML
+	if err := decoder.DecodeTOMLFile(filepath.Join(bpLayersDir, "cache.toml"), &cacheTOML); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	described := map[string]bool{}
+	for _, dep := range cacheTOML.Dependencies {
+		if dep.Name == "" {
+			return nil, errors.New("cache.toml: dependency name is required")
+		}
+		described[dep.Layer] = true
+	}
+
+	var layerNames []string
+	for path, layerMetadataFile := range bpLayers {
+		layerName := filepath.Base(path)
+		if !layerMetadataFile.Cache || described[layerName] {
+			continue
+		}
+		layerNames = append(layerNames, layerName)
+	}
+	sort.Strings(layerNames)
+
+	deps := cacheTOML.Dependencies
+	for _, layerName := range layerNames {
+		dep := CachedDep{Name: layerName, Layer: layerName}
+		if metadata, ok := bpLayers[filepath.Join(bpLayersDir, layerName)].Data.(map[string]interface{}); ok {
+			if name, ok := metadata["name"].(string); ok && name != "" {
+				dep.Name = name
+			}
+			if version, ok := metadata["version"]; ok {
+				dep.Version = fmt.Sprintf("%v", version)
+			}
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
 func names(requires []Require) []string {
 	var out []string
 	for _, req := range requires {
@@ -344,6 +828,14 @@ func names(requires []Require) []string {
 	return out
 }
 
+func unmetNames(unmet []Unmet) []string {
+	var out []string
+	for _, u := range unmet {
+		out = append(out, u.Name)
+	}
+	return out
+}
+
 func validateUnmet(unmet []Unmet, bpPlan Plan) error {
 	for _, unmet := range unmet {
 		if unmet.Name == "" {
@@ -2,12 +2,15 @@ package buildpack_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/apex/log"
@@ -21,6 +24,7 @@ import (
 	"github.com/buildpacks/lifecycle/api"
 	"github.com/buildpacks/lifecycle/buildpack"
 	"github.com/buildpacks/lifecycle/env"
+	"github.com/buildpacks/lifecycle/internal/encoding"
 	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/layers"
 	llog "github.com/buildpacks/lifecycle/log"
@@ -234,6 +238,43 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return(append(os.Environ(), "TEST_ENV=Av1"), nil).AnyTimes()
 				})
 
+				when("tracking env changes", func() {
+					it.Before(func() {
+						buildEnv := env.NewBuildEnv([]string{"PATH=" + os.Getenv("PATH"), "CPATH=/some-cpath"})
+						buildEnv.Vars.Set("TEST_ENV", "Av1") // required by the test buildpack
+						inputs.Env = buildEnv
+						inputs.TrackEnvChanges = true
+					})
+
+					it("reports the changes made by the buildpack's layers", func() {
+						h.Mkdir(t,
+							filepath.Join(appDir, "layers-A-v1", "layer1", "bin"),
+							filepath.Join(appDir, "layers-A-v1", "layer1", "env.build"),
+						)
+						h.Mkfile(t, "[types]\n  build = true", filepath.Join(appDir, "layers-A-v1", "layer1.toml"))
+						h.Mkfile(t, "some-value", filepath.Join(appDir, "layers-A-v1", "layer1", "env.build", "SOME_VAR.override"))
+
+						br, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, br.EnvChanges, []buildpack.EnvChange{
+							{
+								Key:    "PATH",
+								Before: os.Getenv("PATH"),
+								After:  filepath.Join(layersDir, "A", "layer1", "bin") + string(os.PathListSeparator) + os.Getenv("PATH"),
+							},
+							{Key: "SOME_VAR", After: "some-value"},
+						})
+					})
+
+					it("reports no changes when the buildpack's layers do not change the environment", func() {
+						br, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, len(br.EnvChanges), 0)
+					})
+				})
+
 				it("ensures the buildpack's layers dir exists and processes build layers", func() {
 					h.Mkdir(t,
 						filepath.Join(layersDir, "A"),
@@ -264,6 +305,21 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					)
 				})
 
+				it("does not apply the env of layers that are not build layers", func() {
+					h.Mkdir(t,
+						filepath.Join(appDir, "layers-A-v1", "launch-layer", "env"),
+						filepath.Join(appDir, "layers-A-v1", "launch-layer", "env.build"),
+					)
+					h.Mkfile(t, "some-value", filepath.Join(appDir, "layers-A-v1", "launch-layer", "env", "SOME_VAR"))
+					h.Mkfile(t, "[types]\n  build = false\n  launch = true",
+						filepath.Join(appDir, "layers-A-v1", "launch-layer.toml"),
+					)
+					// no calls to AddRootDir or AddEnvDir are expected for launch-layer
+					if _, err := executor.Build(descriptor, inputs, logger); err != nil {
+						t.Fatalf("Unexpected error:\n%s\n", err)
+					}
+				})
+
 				it("errors when the buildpack's layers dir cannot be created", func() {
 					h.Mkfile(t, "some-data", filepath.Join(layersDir, "A"))
 					_, err := executor.Build(descriptor, inputs, logger)
@@ -272,6 +328,36 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					}
 				})
 
+				when("verifying the plan", func() {
+					it.Before(func() {
+						inputs.VerifyPlan = true
+					})
+
+					it("succeeds when the plan survives encoding", func() {
+						inputs.Plan = buildpack.Plan{
+							Entries: []buildpack.Require{
+								{Name: "some-dep", Metadata: map[string]interface{}{"a": map[string]interface{}{"b": int64(1)}}},
+								{Name: "some-other-dep"},
+							},
+						}
+
+						_, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+					})
+
+					it("errors when metadata is lost in encoding", func() {
+						inputs.Plan = buildpack.Plan{
+							Entries: []buildpack.Require{
+								{Name: "some-dep", Metadata: map[string]interface{}{"a": nil}},
+							},
+						}
+
+						_, err := executor.Build(descriptor, inputs, logger)
+						h.AssertError(t, err, "verifying buildpack plan: metadata for entry 'some-dep' did not survive encoding")
+						h.AssertPathDoesNotExist(t, filepath.Join(appDir, "build-plan-in-A-v1.toml"))
+					})
+				})
+
 				it("errors when the provided buildpack plan is invalid", func() {
 					inputs.Plan = buildpack.Plan{
 						Entries: []buildpack.Require{
@@ -299,6 +385,19 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					}
 				})
 
+				when("stdin is provided", func() {
+					it("connects it to the buildpack", func() {
+						h.SkipIf(t, runtime.GOOS == "windows", "the Windows test buildpack does not read stdin")
+						h.Mkfile(t, "", filepath.Join(appDir, "build-read-stdin"))
+						inputs.In = strings.NewReader("some-input")
+
+						if _, err := executor.Build(descriptor, inputs, logger); err != nil {
+							t.Fatalf("Unexpected error:\n%s\n", err)
+						}
+						h.AssertEq(t, h.MustReadFile(t, filepath.Join(appDir, "build-stdin-A-v1")), []byte("some-input"))
+					})
+				})
+
 				when("modifying the env fails", func() {
 					var appendErr error
 
@@ -354,6 +453,16 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					}
 				})
 
+				it("records the exit code when the command fails", func() {
+					h.Mkfile(t, "3", filepath.Join(appDir, "build-status-A-v1"))
+					_, err := executor.Build(descriptor, inputs, logger)
+					bpErr, ok := err.(*buildpack.Error)
+					if !ok || bpErr.Type != buildpack.ErrTypeBuildpack {
+						t.Fatalf("Incorrect error: %s\n", err)
+					}
+					h.AssertEq(t, bpErr.ExitCode, 3)
+				})
+
 				when("<layer>.toml", func() {
 					when("the launch, cache and build flags are false", func() {
 						when("the flags are specified in <layer>.toml", func() {
@@ -408,7 +517,195 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 					})
 				})
 
+				when("dir mode", func() {
+					it.Before(func() {
+						h.SkipIf(t, runtime.GOOS == "windows", "permission modes are not applied on Windows")
+					})
+
+					it("creates the buildpack layers directory with the provided mode", func() {
+						inputs.DirMode = 0750
+
+						_, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						fi, err := os.Stat(filepath.Join(layersDir, "A"))
+						h.AssertNil(t, err)
+						h.AssertEq(t, fi.Mode().Perm(), os.FileMode(0750))
+					})
+				})
+
+				when("plan only", func() {
+					it.Before(func() {
+						inputs.PlanOnly = true
+						inputs.Plan = buildpack.Plan{Entries: []buildpack.Require{{Name: "some-dep"}, {Name: "some-unmet-dep"}}}
+					})
+
+					it("only reads the met requires and does not process layers", func() {
+						h.Mkdir(t, filepath.Join(appDir, "layers-A-v1", "some-layer"))
+						h.Mkfile(t, "[types]\n  build = true",
+							filepath.Join(appDir, "layers-A-v1", "some-layer.toml"),
+						)
+						h.Mkfile(t,
+							`[[processes]]`+"\n"+
+								`type = "web"`+"\n"+
+								`command = ["some-cmd"]`+"\n",
+							filepath.Join(appDir, "launch-A-v1.toml"),
+						)
+						h.Mkfile(t,
+							"[[unmet]]\n"+
+								`name = "some-unmet-dep"`+"\n",
+							filepath.Join(appDir, "build-A-v1.toml"),
+						)
+
+						inputs.Clock = &fakeClock{step: time.Second}
+
+						br, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, br, buildpack.BuildOutputs{SchemaVersion: buildpack.BuildOutputsSchemaVersion, MetRequires: []string{"some-dep"}, Duration: time.Second})
+					})
+				})
+
+				when("buildpack declares an order", func() {
+					it("errors without running bin/build", func() {
+						bpDir := filepath.Join(tmpDir, "meta-buildpack")
+						h.Mkdir(t, filepath.Join(bpDir, "bin"))
+						h.Mkfile(t, "#!/usr/bin/env bash\nexit 0\n", filepath.Join(bpDir, "bin", "build"))
+						h.Mkfile(t, `api = "0.10"
+
+[buildpack]
+  id = "some-meta-buildpack"
+  version = "v1"
+
+[[order]]
+  [[order.group]]
+    id = "A"
+    version = "v1"
+`, filepath.Join(bpDir, "buildpack.toml"))
+						metaDescriptor, err := buildpack.ReadBpDescriptor(filepath.Join(bpDir, "buildpack.toml"))
+						h.AssertNil(t, err)
+
+						_, err = executor.Build(*metaDescriptor, inputs, logger)
+						h.AssertError(t, err, "buildpack some-meta-buildpack declares an order: meta-buildpack cannot be built directly")
+					})
+				})
+
+				when("buildpack skips build if the plan is empty", func() {
+					it.Before(func() {
+						descriptor.Buildpack.SkipBuildIfPlanEmpty = true
+					})
+
+					it("does not run bin/build when the plan is empty", func() {
+						br, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, br, buildpack.BuildOutputs{SchemaVersion: buildpack.BuildOutputsSchemaVersion, NoOp: true})
+						h.AssertPathDoesNotExist(t, filepath.Join(appDir, "build-info-A-v1"))
+					})
+
+					it("runs bin/build when the plan has entries", func() {
+						inputs.Plan = buildpack.Plan{Entries: []buildpack.Require{{Name: "some-dep"}}}
+
+						br, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, br.NoOp, false)
+						h.AssertPathExists(t, filepath.Join(appDir, "build-info-A-v1"))
+					})
+				})
+
+				it("runs bin/build when the plan is empty by default", func() {
+					br, err := executor.Build(descriptor, inputs, logger)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, br.NoOp, false)
+					h.AssertPathExists(t, filepath.Join(appDir, "build-info-A-v1"))
+				})
+
 				when("build result", func() {
+					it("records the duration of the command using the provided clock", func() {
+						inputs.Clock = &fakeClock{step: 5 * time.Second}
+
+						br, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, br.Duration, 5*time.Second)
+					})
+
+					it("records the schema version", func() {
+						br, err := executor.Build(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, br.SchemaVersion, buildpack.BuildOutputsSchemaVersion)
+						h.AssertEq(t, br.Schema(), buildpack.BuildOutputsSchemaVersion)
+					})
+
+					it("treats a missing schema version as the earliest schema", func() {
+						var br buildpack.BuildOutputs
+						h.AssertNil(t, json.Unmarshal([]byte(`{"MetRequires": ["some-dep"]}`), &br))
+
+						h.AssertEq(t, br.Schema(), buildpack.BuildOutputsSchemaVersionUnversioned)
+					})
+
+					when("cached dependencies", func() {
+						it.Before(func() {
+							h.Mkdir(t,
+								filepath.Join(appDir, "layers-A-v1", "cached-layer"),
+								filepath.Join(appDir, "layers-A-v1", "described-layer"),
+								filepath.Join(appDir, "layers-A-v1", "launch-layer"),
+							)
+							h.Mkfile(t,
+								"[types]\n  cache = true\n[metadata]\n  name = \"some-cached-dep\"\n  version = \"v1\"",
+								filepath.Join(appDir, "layers-A-v1", "cached-layer.toml"),
+							)
+							h.Mkfile(t,
+								"[types]\n  cache = true",
+								filepath.Join(appDir, "layers-A-v1", "described-layer.toml"),
+							)
+							h.Mkfile(t,
+								"[types]\n  launch = true\n[metadata]\n  name = \"some-launch-dep\"",
+								filepath.Join(appDir, "layers-A-v1", "launch-layer.toml"),
+							)
+						})
+
+						it("includes entries from cache.toml followed by undescribed cached layers", func() {
+							h.Mkfile(t,
+								"[[dependencies]]\n  name = \"some-described-dep\"\n  version = \"v2\"\n  layer = \"described-layer\"",
+								filepath.Join(appDir, "layers-A-v1", "cache.toml"),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, br.CachedDependencies, []buildpack.CachedDep{
+								{Name: "some-described-dep", Version: "v2", Layer: "described-layer"},
+								{Name: "some-cached-dep", Version: "v1", Layer: "cached-layer"},
+							})
+						})
+
+						when("there is no cache.toml", func() {
+							it("uses the layer name when the layer metadata has no name", func() {
+								br, err := executor.Build(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.CachedDependencies, []buildpack.CachedDep{
+									{Name: "some-cached-dep", Version: "v1", Layer: "cached-layer"},
+									{Name: "described-layer", Layer: "described-layer"},
+								})
+							})
+						})
+
+						it("errors when a cache.toml dependency has no name", func() {
+							h.Mkfile(t,
+								"[[dependencies]]\n  layer = \"described-layer\"",
+								filepath.Join(appDir, "layers-A-v1", "cache.toml"),
+							)
+
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertError(t, err, "cache.toml: dependency name is required")
+						})
+					})
+
 					when("build bom", func() {
 						when("there is a bom in build.toml", func() {
 							it("warns and includes the bom", func() {
@@ -430,6 +727,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 											Metadata: map[string]interface{}{"version": "some-version"},
 										},
 										Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"}, // no api, no homepage
+										Source:    buildpack.BOMSourceBuildTOML,
 									},
 								})
 								assertLogEntry(t, logHandler, "BOM table is deprecated in this buildpack api version, though it remains supported for backwards compatibility. Buildpack authors should write BOM information to <layer>.sbom.<ext>, launch.sbom.<ext>, or build.sbom.<ext>.")
@@ -464,6 +762,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 											Metadata: map[string]interface{}{"version": "some-version"},
 										},
 										Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"}, // no api, no homepage
+										Source:    buildpack.BOMSourceBuildTOML,
 									},
 								})
 								h.AssertEq(t, br.BOMFiles, []buildpack.BOMFile{
@@ -500,6 +799,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 											Metadata: map[string]interface{}{"version": "some-version"},
 										},
 										Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"}, // no api, no homepage
+										Source:    buildpack.BOMSourceLaunchTOML,
 									},
 								})
 								assertLogEntry(t, logHandler, "BOM table is deprecated in this buildpack api version, though it remains supported for backwards compatibility. Buildpack authors should write BOM information to <layer>.sbom.<ext>, launch.sbom.<ext>, or build.sbom.<ext>.")
@@ -534,6 +834,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 											Metadata: map[string]interface{}{"version": "some-version"},
 										},
 										Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"}, // no api, no homepage
+										Source:    buildpack.BOMSourceLaunchTOML,
 									},
 								})
 								h.AssertEq(t, br.BOMFiles, []buildpack.BOMFile{
@@ -548,133 +849,519 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 							})
 						})
 
-						it("errors when there is a bom in launch.toml with a top-level version", func() {
+						it("errors when there is a bom in launch.toml with a top-level version", func() {
+							h.Mkfile(t,
+								"[[bom]]\n"+
+									`name = "some-dep"`+"\n"+
+									`version = "some-version"`+"\n",
+								filepath.Join(appDir, "launch-A-v1.toml"),
+							)
+
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertError(t, err, "bom entry 'some-dep' has a top level version which is not allowed. The buildpack should instead set metadata.version")
+						})
+					})
+
+					when("SBOM files", func() {
+						it("includes any SBOM files", func() {
+							buildpackID := descriptor.Buildpack.ID
+							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json;version=1.3"}
+							layerName := "some-layer"
+							otherLayerName := "some-launch-true-cache-false-layer"
+
+							h.Mkdir(t,
+								filepath.Join(layersDir, buildpackID))
+							h.Mkfile(t, `{"key": "some-bom-content"}`,
+								filepath.Join(layersDir, buildpackID, "launch.sbom.cdx.json"),
+								filepath.Join(layersDir, buildpackID, "build.sbom.cdx.json"),
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.cdx.json", layerName)),
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.cdx.json", otherLayerName)), // layer directory does not exist
+							)
+
+							h.Mkdir(t,
+								filepath.Join(layersDir, buildpackID, layerName))
+							h.Mkfile(t, "[types]\n  cache = true",
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.toml", layerName)))
+							h.Mkfile(t, "[types]\n  launch = true\n  cache = false",
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.toml", otherLayerName)))
+							inputs.Clock = &fakeClock{step: time.Second}
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, buildpack.BuildOutputs{
+								SchemaVersion: buildpack.BuildOutputsSchemaVersion,
+								Duration:      time.Second,
+								BOMFiles: []buildpack.BOMFile{
+									{
+										BuildpackID: buildpackID,
+										LayerName:   "",
+										LayerType:   buildpack.LayerTypeBuild,
+										Path:        filepath.Join(layersDir, buildpackID, "build.sbom.cdx.json"),
+									},
+									{
+										BuildpackID: buildpackID,
+										LayerName:   "",
+										LayerType:   buildpack.LayerTypeLaunch,
+										Path:        filepath.Join(layersDir, buildpackID, "launch.sbom.cdx.json"),
+									},
+									{
+										BuildpackID: buildpackID,
+										LayerName:   otherLayerName,
+										LayerType:   buildpack.LayerTypeLaunch,
+										Path:        filepath.Join(layersDir, buildpackID, "some-launch-true-cache-false-layer.sbom.cdx.json"),
+									},
+									{
+										BuildpackID: buildpackID,
+										LayerName:   layerName,
+										LayerType:   buildpack.LayerTypeBuild,
+										Path:        filepath.Join(layersDir, buildpackID, "some-layer.sbom.cdx.json"),
+									},
+									{
+										BuildpackID: buildpackID,
+										LayerName:   layerName,
+										LayerType:   buildpack.LayerTypeCache,
+										Path:        filepath.Join(layersDir, buildpackID, "some-layer.sbom.cdx.json"),
+									},
+								},
+								CachedDependencies: []buildpack.CachedDep{
+									{Name: layerName, Layer: layerName},
+								},
+								LaunchSBOM: []buildpack.BOMFile{
+									{
+										BuildpackID: buildpackID,
+										LayerName:   "",
+										LayerType:   buildpack.LayerTypeLaunch,
+										Path:        filepath.Join(layersDir, buildpackID, "launch.sbom.cdx.json"),
+									},
+								},
+							}, br)
+						})
+
+						it("records the top-level launch SBOM files separately from layer SBOM files", func() {
+							buildpackID := descriptor.Buildpack.ID
+							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json", "application/spdx+json"}
+							layerName := "some-launch-layer"
+
+							h.Mkdir(t,
+								filepath.Join(layersDir, buildpackID, layerName))
+							h.Mkfile(t, "[types]\n  launch = true",
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.toml", layerName)))
+							h.Mkfile(t, `{"key": "some-bom-content"}`,
+								filepath.Join(layersDir, buildpackID, "launch.sbom.cdx.json"),
+								filepath.Join(layersDir, buildpackID, "launch.sbom.spdx.json"),
+								filepath.Join(layersDir, buildpackID, "build.sbom.cdx.json"),
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.cdx.json", layerName)),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, br.LaunchSBOM, []buildpack.BOMFile{
+								{
+									BuildpackID: buildpackID,
+									LayerType:   buildpack.LayerTypeLaunch,
+									Path:        filepath.Join(layersDir, buildpackID, "launch.sbom.cdx.json"),
+								},
+								{
+									BuildpackID: buildpackID,
+									LayerType:   buildpack.LayerTypeLaunch,
+									Path:        filepath.Join(layersDir, buildpackID, "launch.sbom.spdx.json"),
+								},
+							})
+							h.AssertEq(t, len(br.BOMFiles), 4)
+						})
+
+						it("errors if a top-level launch SBOM file has an unsupported extension", func() {
+							buildpackID := descriptor.Buildpack.ID
+							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json"}
+
+							h.Mkdir(t,
+								filepath.Join(layersDir, buildpackID))
+							h.Mkfile(t, `{"key": "some-bom-content"}`,
+								filepath.Join(layersDir, buildpackID, "launch.sbom.some-unknown-format.json"))
+
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertError(t, err, fmt.Sprintf("unsupported SBOM file format: '%s'", filepath.Join(layersDir, buildpackID, "launch.sbom.some-unknown-format.json")))
+						})
+
+						it("errors if there are unsupported extensions", func() {
+							buildpackID := descriptor.Buildpack.ID
+							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"}
+							layerName := "some-layer"
+
+							h.Mkdir(t,
+								filepath.Join(layersDir, buildpackID, layerName))
+							h.Mkfile(t, "[types]\n  launch = true\n  cache = false",
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.toml", layerName)))
+							h.Mkfile(t, `{"key": "some-bom-content"}`,
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.cdx.json", layerName)),
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.spdx.json", layerName)),
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.syft.json", layerName)),
+								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.some-unknown-format.json", layerName)))
+
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertError(t, err, fmt.Sprintf("unsupported SBOM file format: '%s'", filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.some-unknown-format.json", layerName))))
+						})
+
+						it("errors if there are undeclared media types", func() {
+							buildpackID := descriptor.Buildpack.ID
+							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json"}
+
+							h.Mkdir(t,
+								filepath.Join(layersDir, buildpackID))
+							h.Mkfile(t, `{"key": "some-bom-content"}`,
+								filepath.Join(layersDir, buildpackID, "launch.sbom.spdx.json"))
+
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertError(t, err, fmt.Sprintf("validating SBOM file '%s' for buildpack: 'A@v1': undeclared SBOM media type: 'application/spdx+json'", filepath.Join(layersDir, buildpackID, "launch.sbom.spdx.json")))
+						})
+					})
+
+					when("labels", func() {
+						it("includes labels", func() {
+							h.Mkfile(t,
+								"[[labels]]\n"+
+									`key = "some-key"`+"\n"+
+									`value = "some-value"`+"\n"+
+									"[[labels]]\n"+
+									`key = "some-other-key"`+"\n"+
+									`value = "some-other-value"`+"\n",
+								filepath.Join(appDir, "launch-A-v1.toml"),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, br.Labels, []buildpack.Label{
+								{Key: "some-key", Value: "some-value"},
+								{Key: "some-other-key", Value: "some-other-value"},
+							})
+						})
+
+						it("allows loose label keys by default", func() {
+							h.Mkfile(t,
+								"[[labels]]\n"+
+									`key = "Some Key"`+"\n"+
+									`value = "some-value"`+"\n",
+								filepath.Join(appDir, "launch-A-v1.toml"),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, br.Labels, []buildpack.Label{{Key: "Some Key", Value: "some-value"}})
+						})
+
+						when("strict label keys", func() {
+							it.Before(func() {
+								inputs.StrictLabelKeys = true
+							})
+
+							it("includes valid labels", func() {
+								h.Mkfile(t,
+									"[[labels]]\n"+
+										`key = "com.example.some-key"`+"\n"+
+										`value = "some-value"`+"\n",
+									filepath.Join(appDir, "launch-A-v1.toml"),
+								)
+
+								br, err := executor.Build(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.Labels, []buildpack.Label{{Key: "com.example.some-key", Value: "some-value"}})
+							})
+
+							it("errors when a label key is invalid", func() {
+								for _, key := range []string{"Some Key", "com.Example.key", "com..example", "-some-key", ""} {
+									h.Mkfile(t,
+										"[[labels]]\n"+
+											fmt.Sprintf("key = %q", key)+"\n"+
+											`value = "some-value"`+"\n",
+										filepath.Join(appDir, "launch-A-v1.toml"),
+									)
+
+									_, err := executor.Build(descriptor, inputs, logger)
+									h.AssertError(t, err, fmt.Sprintf("buildpack A declared invalid label key '%s'", key))
+								}
+							})
+
+							it("errors when a label key is reserved", func() {
+								h.Mkfile(t,
+									"[[labels]]\n"+
+										`key = "io.buildpacks.some-key"`+"\n"+
+										`value = "some-value"`+"\n",
+									filepath.Join(appDir, "launch-A-v1.toml"),
+								)
+
+								_, err := executor.Build(descriptor, inputs, logger)
+								h.AssertError(t, err, "buildpack A declared label key 'io.buildpacks.some-key' in the reserved 'io.buildpacks.' namespace")
+							})
+
+							it("allows reserved label keys for exempt buildpacks", func() {
+								inputs.ReservedLabelKeysExempt = []string{"A"}
+								h.Mkfile(t,
+									"[[labels]]\n"+
+										`key = "io.buildpacks.some-key"`+"\n"+
+										`value = "some-value"`+"\n",
+									filepath.Join(appDir, "launch-A-v1.toml"),
+								)
+
+								br, err := executor.Build(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.Labels, []buildpack.Label{{Key: "io.buildpacks.some-key", Value: "some-value"}})
+							})
+						})
+					})
+
+					when("warnings", func() {
+						it("includes warnings from warnings.toml", func() {
+							h.Mkdir(t, filepath.Join(appDir, "layers-A-v1"))
+							h.Mkfile(t,
+								`warnings = ["some-warning", "some-other-warning"]`+"\n",
+								filepath.Join(appDir, "layers-A-v1", "warnings.toml"),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, br.Warnings, []string{"some-warning", "some-other-warning"})
+						})
+
+						it("has no warnings when warnings.toml is missing", func() {
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.Warnings), 0)
+						})
+
+						it("errors when warnings.toml is malformed", func() {
+							h.Mkdir(t, filepath.Join(appDir, "layers-A-v1"))
+							h.Mkfile(t,
+								`warnings = "some-warning"`+"\n",
+								filepath.Join(appDir, "layers-A-v1", "warnings.toml"),
+							)
+
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertError(t, err, "reading warnings.toml")
+						})
+					})
+
+					when("detecting stray directories", func() {
+						it.Before(func() {
+							h.SkipIf(t, runtime.GOOS == "windows", "the Windows test buildpack does not create stray directories")
+							h.Mkdir(t, filepath.Join(layersDir, "some-other-buildpack"))
+							h.Mkfile(t, "some-other-buildpack/some-dir/some-nested-dir", filepath.Join(appDir, "build-stray-dir"))
+						})
+
+						it("warns about directories created outside of the buildpack's layers directory", func() {
+							inputs.DetectStray = true
+							h.Mkdir(t, filepath.Join(appDir, "layers-A-v1", "some-layer"))
+							h.Mkfile(t,
+								`warnings = ["some-warning"]`+"\n",
+								filepath.Join(appDir, "layers-A-v1", "warnings.toml"),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, br.Warnings, []string{
+								"some-warning",
+								"created directory '" + filepath.Join("some-other-buildpack", "some-dir") + "' outside of its layers directory",
+							})
+							assertLogEntry(t, logHandler, "Buildpack A created directory")
+						})
+
+						it("does not check by default", func() {
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.Warnings), 0)
+						})
+					})
+
+					when("combined bom", func() {
+						it("includes launch entries followed by build entries", func() {
+							h.Mkfile(t,
+								"[[bom]]\n"+
+									`name = "some-build-dep"`+"\n"+
+									"[bom.metadata]\n"+
+									`version = "v1"`+"\n",
+								filepath.Join(appDir, "build-A-v1.toml"),
+							)
+							h.Mkfile(t,
+								"[[bom]]\n"+
+									`name = "some-launch-dep"`+"\n"+
+									"[bom.metadata]\n"+
+									`version = "v1"`+"\n",
+								filepath.Join(appDir, "launch-A-v1.toml"),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.BOM()), 2)
+							h.AssertEq(t, br.BOM()[0].Name, "some-launch-dep")
+							h.AssertEq(t, br.BOM()[1].Name, "some-build-dep")
+							h.AssertEq(t, len(br.LaunchBOM), 1)
+							h.AssertEq(t, len(br.BuildBOM), 1)
+						})
+					})
+
+					when("bom is required", func() {
+						it.Before(func() {
+							inputs.RequireBOM = true
+							inputs.Plan = buildpack.Plan{Entries: []buildpack.Require{{Name: "some-dep"}}}
+						})
+
+						it("errors when a buildpack that satisfied requires has no bom", func() {
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertError(t, err, "buildpack A satisfied requires [some-dep] but did not declare a BOM")
+						})
+
+						it("succeeds when the buildpack declares a bom", func() {
+							h.Mkfile(t,
+								"[[bom]]\n"+
+									`name = "some-dep"`+"\n"+
+									"[bom.metadata]\n"+
+									`version = "some-version"`+"\n",
+								filepath.Join(appDir, "build-A-v1.toml"),
+							)
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+						})
+
+						it("succeeds when the buildpack satisfied no requires", func() {
+							h.Mkfile(t,
+								"[[unmet]]\n"+
+									`name = "some-dep"`+"\n",
+								filepath.Join(appDir, "build-A-v1.toml"),
+							)
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+						})
+
+						it("succeeds when the buildpack is exempt", func() {
+							inputs.RequireBOMExempt = []string{"A"}
+							_, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+						})
+					})
+
+					when("output file names are overridden", func() {
+						it.Before(func() {
+							inputs.LaunchTOMLName = "custom-launch.toml"
+							inputs.BuildTOMLName = "custom-build.toml"
+							inputs.Plan = buildpack.Plan{Entries: []buildpack.Require{{Name: "some-dep"}, {Name: "some-unmet-dep"}}}
+							h.Mkdir(t, filepath.Join(appDir, "layers-A-v1"))
+						})
+
+						it("reads the provided files", func() {
+							h.Mkfile(t,
+								`[[processes]]`+"\n"+
+									`type = "web"`+"\n"+
+									`command = ["some-cmd"]`+"\n",
+								filepath.Join(appDir, "layers-A-v1", "custom-launch.toml"),
+							)
+							h.Mkfile(t,
+								"[[unmet]]\n"+
+									`name = "some-unmet-dep"`+"\n",
+								filepath.Join(appDir, "layers-A-v1", "custom-build.toml"),
+							)
+
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.Processes), 1)
+							h.AssertEq(t, br.Processes[0].Type, "web")
+							h.AssertEq(t, br.MetRequires, []string{"some-dep"})
+						})
+
+						it("ignores files with the default names", func() {
 							h.Mkfile(t,
-								"[[bom]]\n"+
-									`name = "some-dep"`+"\n"+
-									`version = "some-version"`+"\n",
+								`[[processes]]`+"\n"+
+									`type = "web"`+"\n"+
+									`command = ["some-cmd"]`+"\n",
 								filepath.Join(appDir, "launch-A-v1.toml"),
 							)
 
-							_, err := executor.Build(descriptor, inputs, logger)
-							h.AssertError(t, err, "bom entry 'some-dep' has a top level version which is not allowed. The buildpack should instead set metadata.version")
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.Processes), 0)
 						})
 					})
 
-					when("SBOM files", func() {
-						it("includes any SBOM files", func() {
-							buildpackID := descriptor.Buildpack.ID
-							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json;version=1.3"}
-							layerName := "some-layer"
-							otherLayerName := "some-launch-true-cache-false-layer"
+					when("a TOML decoder is provided", func() {
+						var decoder *fakeTOMLDecoder
 
-							h.Mkdir(t,
-								filepath.Join(layersDir, buildpackID))
-							h.Mkfile(t, `{"key": "some-bom-content"}`,
-								filepath.Join(layersDir, buildpackID, "launch.sbom.cdx.json"),
-								filepath.Join(layersDir, buildpackID, "build.sbom.cdx.json"),
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.cdx.json", layerName)),
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.cdx.json", otherLayerName)), // layer directory does not exist
-							)
+						it.Before(func() {
+							decoder = &fakeTOMLDecoder{errs: map[string]error{}}
+							executor.TOMLDecoder = decoder
+						})
 
-							h.Mkdir(t,
-								filepath.Join(layersDir, buildpackID, layerName))
-							h.Mkfile(t, "[types]\n  cache = true",
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.toml", layerName)))
-							h.Mkfile(t, "[types]\n  launch = true\n  cache = false",
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.toml", otherLayerName)))
+						it("reads output files with the decoder", func() {
+							h.Mkfile(t,
+								"[[unmet]]\n"+
+									`name = "some-unmet-dep"`+"\n",
+								filepath.Join(appDir, "build-A-v1.toml"),
+							)
+							inputs.Plan = buildpack.Plan{Entries: []buildpack.Require{{Name: "some-dep"}, {Name: "some-unmet-dep"}}}
 
 							br, err := executor.Build(descriptor, inputs, logger)
 							h.AssertNil(t, err)
 
-							h.AssertEq(t, buildpack.BuildOutputs{
-								BOMFiles: []buildpack.BOMFile{
-									{
-										BuildpackID: buildpackID,
-										LayerName:   "",
-										LayerType:   buildpack.LayerTypeBuild,
-										Path:        filepath.Join(layersDir, buildpackID, "build.sbom.cdx.json"),
-									},
-									{
-										BuildpackID: buildpackID,
-										LayerName:   "",
-										LayerType:   buildpack.LayerTypeLaunch,
-										Path:        filepath.Join(layersDir, buildpackID, "launch.sbom.cdx.json"),
-									},
-									{
-										BuildpackID: buildpackID,
-										LayerName:   otherLayerName,
-										LayerType:   buildpack.LayerTypeLaunch,
-										Path:        filepath.Join(layersDir, buildpackID, "some-launch-true-cache-false-layer.sbom.cdx.json"),
-									},
-									{
-										BuildpackID: buildpackID,
-										LayerName:   layerName,
-										LayerType:   buildpack.LayerTypeBuild,
-										Path:        filepath.Join(layersDir, buildpackID, "some-layer.sbom.cdx.json"),
-									},
-									{
-										BuildpackID: buildpackID,
-										LayerName:   layerName,
-										LayerType:   buildpack.LayerTypeCache,
-										Path:        filepath.Join(layersDir, buildpackID, "some-layer.sbom.cdx.json"),
-									},
-								},
-							}, br)
+							h.AssertEq(t, br.MetRequires, []string{"some-dep"})
+							h.AssertContains(t, decoder.decoded, filepath.Join(layersDir, "A", "build.toml"))
 						})
 
-						it("errors if there are unsupported extensions", func() {
-							buildpackID := descriptor.Buildpack.ID
-							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json", "application/spdx+json", "application/vnd.syft+json"}
-							layerName := "some-layer"
-
-							h.Mkdir(t,
-								filepath.Join(layersDir, buildpackID, layerName))
-							h.Mkfile(t, "[types]\n  launch = true\n  cache = false",
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.toml", layerName)))
-							h.Mkfile(t, `{"key": "some-bom-content"}`,
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.cdx.json", layerName)),
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.spdx.json", layerName)),
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.syft.json", layerName)),
-								filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.some-unknown-format.json", layerName)))
+						it("errors when the decoder fails", func() {
+							decoder.errs["build.toml"] = errors.New("some-decode-error")
 
 							_, err := executor.Build(descriptor, inputs, logger)
-							h.AssertError(t, err, fmt.Sprintf("unsupported SBOM file format: '%s'", filepath.Join(layersDir, buildpackID, fmt.Sprintf("%s.sbom.some-unknown-format.json", layerName))))
+							h.AssertError(t, err, "some-decode-error")
 						})
+					})
 
-						it("errors if there are undeclared media types", func() {
-							buildpackID := descriptor.Buildpack.ID
-							descriptor.Buildpack.SBOM = []string{"application/vnd.cyclonedx+json"}
+					when("post-build hook", func() {
+						it.Before(func() {
+							inputs.PostBuild = func(br buildpack.BuildOutputs) error {
+								for _, proc := range br.Processes {
+									if proc.Type == "web" {
+										return nil
+									}
+								}
+								return errors.New("missing required process type 'web'")
+							}
+						})
 
-							h.Mkdir(t,
-								filepath.Join(layersDir, buildpackID))
-							h.Mkfile(t, `{"key": "some-bom-content"}`,
-								filepath.Join(layersDir, buildpackID, "launch.sbom.spdx.json"))
+						it("errors when the hook rejects the outputs", func() {
+							h.Mkfile(t,
+								`[[processes]]`+"\n"+
+									`type = "worker"`+"\n"+
+									`command = ["some-cmd"]`+"\n",
+								filepath.Join(appDir, "launch-A-v1.toml"),
+							)
 
 							_, err := executor.Build(descriptor, inputs, logger)
-							h.AssertError(t, err, fmt.Sprintf("validating SBOM file '%s' for buildpack: 'A@v1': undeclared SBOM media type: 'application/spdx+json'", filepath.Join(layersDir, buildpackID, "launch.sbom.spdx.json")))
+							h.AssertError(t, err, "post-build check rejected buildpack A: missing required process type 'web'")
+							var postBuildErr *buildpack.PostBuildError
+							h.AssertEq(t, errors.As(err, &postBuildErr), true)
+							h.AssertEq(t, postBuildErr.Buildpack, "A")
 						})
-					})
 
-					when("labels", func() {
-						it("includes labels", func() {
+						it("succeeds when the hook accepts the outputs", func() {
 							h.Mkfile(t,
-								"[[labels]]\n"+
-									`key = "some-key"`+"\n"+
-									`value = "some-value"`+"\n"+
-									"[[labels]]\n"+
-									`key = "some-other-key"`+"\n"+
-									`value = "some-other-value"`+"\n",
+								`[[processes]]`+"\n"+
+									`type = "web"`+"\n"+
+									`command = ["some-cmd"]`+"\n",
 								filepath.Join(appDir, "launch-A-v1.toml"),
 							)
 
 							br, err := executor.Build(descriptor, inputs, logger)
 							h.AssertNil(t, err)
-
-							h.AssertEq(t, br.Labels, []buildpack.Label{
-								{Key: "some-key", Value: "some-value"},
-								{Key: "some-other-key", Value: "some-other-value"},
-							})
+							h.AssertEq(t, len(br.Processes), 1)
 						})
 					})
 
@@ -699,6 +1386,39 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 							h.AssertEq(t, br.MetRequires, []string{"some-dep", "some-other-dep"})
 						})
 
+						when("fail on unmet", func() {
+							it.Before(func() {
+								inputs.FailOnUnmet = true
+								inputs.Plan = buildpack.Plan{
+									Entries: []buildpack.Require{
+										{Name: "some-dep"},
+										{Name: "some-unmet-dep"},
+										{Name: "some-other-unmet-dep"},
+									},
+								}
+							})
+
+							it("errors listing the unmet entries", func() {
+								h.Mkfile(t,
+									"[[unmet]]\n"+
+										`name = "some-unmet-dep"`+"\n"+
+										"[[unmet]]\n"+
+										`name = "some-other-unmet-dep"`+"\n",
+									filepath.Join(appDir, "build-A-v1.toml"),
+								)
+
+								_, err := executor.Build(descriptor, inputs, logger)
+								h.AssertError(t, err, "buildpack A did not meet requires [some-unmet-dep some-other-unmet-dep]")
+							})
+
+							it("succeeds when all entries are met", func() {
+								br, err := executor.Build(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.MetRequires, []string{"some-dep", "some-unmet-dep", "some-other-unmet-dep"})
+							})
+						})
+
 						when("there are invalid unmet entries", func() {
 							it("errors when name is missing", func() {
 								h.Mkfile(t,
@@ -793,6 +1513,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 							)
 							_, err := executor.Build(descriptor, inputs, logger)
 							h.AssertError(t, err, "toml: line 2 (last key \"processes.command\"): incompatible types: TOML value has type string; destination has type slice")
+							h.AssertError(t, err, "process type '': command must be a list of strings for buildpack API "+descriptor.WithAPI)
 						})
 
 						it("preserves command args", func() {
@@ -809,6 +1530,20 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 							h.AssertEq(t, br.Processes[0].Args[0], "first-arg")
 						})
 
+						it("preserves args alongside a single-entry command", func() {
+							h.Mkfile(t,
+								"[[processes]]\n"+
+									`command = ["some-cmd"]`+"\n"+
+									`args = ["serve", "--port", "8080"]`,
+								filepath.Join(appDir, "launch-A-v1.toml"),
+							)
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+							h.AssertEq(t, len(br.Processes), 1)
+							h.AssertEq(t, br.Processes[0].Command.Entries, []string{"some-cmd"})
+							h.AssertEq(t, br.Processes[0].Args, []string{"serve", "--port", "8080"})
+						})
+
 						it("returns direct=true for processes", func() {
 							h.Mkfile(t,
 								"[[processes]]\n"+
@@ -985,6 +1720,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 										Metadata: map[string]interface{}{"version": "v1"},
 									},
 									Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+									Source:    buildpack.BOMSourceInputPlan,
 								},
 								{
 									Require: buildpack.Require{
@@ -992,6 +1728,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 										Metadata: map[string]interface{}{"version": "some-version-new"},
 									},
 									Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+									Source:    buildpack.BOMSourceOutputPlan,
 								},
 								{
 									Require: buildpack.Require{
@@ -999,6 +1736,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 										Metadata: map[string]interface{}{"version": "v1"},
 									},
 									Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+									Source:    buildpack.BOMSourceInputPlan,
 								},
 								{
 									Require: buildpack.Require{
@@ -1006,6 +1744,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 										Metadata: map[string]interface{}{"version": "some-version-new"},
 									},
 									Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+									Source:    buildpack.BOMSourceOutputPlan,
 								},
 							})
 							h.AssertEq(t, br.MetRequires, []string{
@@ -1052,6 +1791,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 										Metadata: map[string]interface{}{"version": "v1"},
 									},
 									Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+									Source:    buildpack.BOMSourceOutputPlan,
 								},
 								{
 									Require: buildpack.Require{
@@ -1059,6 +1799,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 										Metadata: map[string]interface{}{"version": "v2"},
 									},
 									Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+									Source:    buildpack.BOMSourceOutputPlan,
 								},
 								{
 									Require: buildpack.Require{
@@ -1066,6 +1807,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 										Metadata: map[string]interface{}{"version": "v3"},
 									},
 									Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+									Source:    buildpack.BOMSourceOutputPlan,
 								},
 							})
 						})
@@ -1085,6 +1827,25 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 							h.AssertStringContains(t, err.Error(), expected)
 						})
 					})
+
+					when("processes", func() {
+						it("preserves args", func() {
+							h.Mkfile(t,
+								"[[processes]]\n"+
+									`type = "web"`+"\n"+
+									`command = "some-cmd"`+"\n"+
+									`args = ["serve", "--port", "8080"]`,
+								filepath.Join(appDir, "launch-A-v1.toml"),
+							)
+							br, err := executor.Build(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.Processes), 1)
+							h.AssertEq(t, br.Processes[0].Type, "web")
+							h.AssertEq(t, br.Processes[0].Command.Entries, []string{"some-cmd"})
+							h.AssertEq(t, br.Processes[0].Args, []string{"serve", "--port", "8080"})
+						})
+					})
 				})
 
 				when("buildpack api < 0.6", func() {
@@ -1196,6 +1957,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 									Metadata: map[string]interface{}{"version": "some-version-new"},
 								},
 								Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"}, // no api, no homepage
+								Source:    buildpack.BOMSourceLaunchTOML,
 							},
 							{
 								Require: buildpack.Require{
@@ -1203,6 +1965,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 									Metadata: map[string]interface{}{"version": "v1"},
 								},
 								Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"}, // no api, no homepage
+								Source:    buildpack.BOMSourceLaunchTOML,
 							},
 							{
 								Require: buildpack.Require{
@@ -1210,6 +1973,7 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 									Metadata: map[string]interface{}{"version": "some-version-new"},
 								},
 								Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"}, // no api, no homepage
+								Source:    buildpack.BOMSourceLaunchTOML,
 							},
 						})
 						h.AssertEq(t, br.MetRequires, []string{"some-deprecated-bp-replace-version-dep", "some-dep", "some-replace-version-dep"})
@@ -1351,11 +2115,283 @@ func testBuild(t *testing.T, when spec.G, it spec.S) {
 						)
 						_, err := executor.Build(descriptor, inputs, logger)
 						h.AssertError(t, err, "toml: line 2 (last key \"processes.command\"): incompatible types: TOML value has type []interface {}; destination has type string")
+						h.AssertError(t, err, "process type '': command must be a string for buildpack API 0.8")
 					})
 				})
 			})
 		})
 	})
+
+	when("#BuildFromPlanFile", func() {
+		var planFile string
+
+		it.Before(func() {
+			mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return(append(os.Environ(), "TEST_ENV=Av1"), nil).AnyTimes()
+			planFile = filepath.Join(tmpDir, "plan.toml")
+		})
+
+		it("provides the plan file to the buildpack as-is", func() {
+			planContents := "# written by detect\n" +
+				"[[entries]]\n" +
+				"  name = \"some-dep\"\n" +
+				"  [entries.metadata]\n" +
+				"    some-key = 1.50\n" +
+				"[[entries]]\n" +
+				"  name = \"some-unmet-dep\"\n"
+			h.Mkfile(t, planContents, planFile)
+			h.Mkfile(t,
+				"[[unmet]]\n"+
+					`name = "some-unmet-dep"`+"\n",
+				filepath.Join(appDir, "build-A-v1.toml"),
+			)
+
+			br, err := executor.BuildFromPlanFile(descriptor, planFile, inputs, logger)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, h.Rdfile(t, filepath.Join(appDir, "build-plan-in-A-v1.toml")), planContents)
+			h.AssertEq(t, br.MetRequires, []string{"some-dep"})
+		})
+
+		it("errors when the plan file cannot be read", func() {
+			_, err := executor.BuildFromPlanFile(descriptor, planFile, inputs, logger)
+			h.AssertError(t, err, "reading buildpack plan for buildpack A")
+		})
+	})
+
+	when("BuildOutputs#WriteFile", func() {
+		it("writes outputs that can be read back without loss", func() {
+			outputs := buildpack.BuildOutputs{
+				SchemaVersion: buildpack.BuildOutputsSchemaVersion,
+				BOMFiles: []buildpack.BOMFile{
+					{BuildpackID: "A", LayerName: "some-layer", LayerType: buildpack.LayerTypeLaunch, Path: "/some/path.sbom.cdx.json"},
+				},
+				BuildBOM: []buildpack.BOMEntry{
+					{
+						Require:   buildpack.Require{Name: "some-build-dep", Metadata: map[string]interface{}{"version": "v1"}},
+						Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+					},
+				},
+				CachedDependencies: []buildpack.CachedDep{{Name: "some-dep", Version: "v1", Layer: "some-layer"}},
+				Labels:             []buildpack.Label{{Key: "some-key", Value: "some-value"}},
+				LaunchBOM: []buildpack.BOMEntry{
+					{
+						Require:   buildpack.Require{Name: "some-launch-dep", Version: "v2"},
+						Buildpack: buildpack.GroupElement{ID: "A", Version: "v1"},
+					},
+				},
+				MetRequires: []string{"some-dep"},
+				Warnings:    []string{"some-warning"},
+				Duration:    3 * time.Second,
+				EnvChanges:  []buildpack.EnvChange{{Key: "SOME_VAR", After: "some-value"}},
+				Processes: []launch.Process{
+					{
+						Type:             "web",
+						Command:          launch.NewRawCommand([]string{"some-command", "some-command-arg"}),
+						Args:             []string{"some-arg"},
+						Direct:           true,
+						Default:          true,
+						BuildpackID:      "A",
+						WorkingDirectory: "/some-dir",
+					},
+				},
+				Slices: []layers.Slice{{Paths: []string{"some-path", "some-other-path"}}},
+			}
+			path := filepath.Join(tmpDir, "outputs", "build-outputs.toml")
+
+			h.AssertNil(t, outputs.WriteFile(path))
+			read, err := buildpack.ReadBuildOutputs(path)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, len(read.Processes), 1)
+			h.AssertEq(t, read.Processes[0].Type, "web")
+			h.AssertEq(t, read.Processes[0].Command.Entries, []string{"some-command", "some-command-arg"})
+			h.AssertEq(t, read.Processes[0].Args, []string{"some-arg"})
+			h.AssertEq(t, read.Processes[0].Direct, true)
+			h.AssertEq(t, read.Processes[0].Default, true)
+			h.AssertEq(t, read.Processes[0].BuildpackID, "A")
+			h.AssertEq(t, read.Processes[0].WorkingDirectory, "/some-dir")
+			h.AssertEq(t, outputs.Processes[0].Command.PlatformAPI == nil, true) // the outputs are not modified
+
+			read.Processes, outputs.Processes = nil, nil
+			h.AssertEq(t, read, outputs)
+		})
+
+		it("errors when the file cannot be read", func() {
+			_, err := buildpack.ReadBuildOutputs(filepath.Join(tmpDir, "missing.toml"))
+			h.AssertError(t, err, "reading build outputs from")
+		})
+	})
+
+	when("#ResolveBuildEnv", func() {
+		it("returns the environment with CNB_* variables", func() {
+			mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return([]string{"SOME_VAR=some-val"}, nil)
+
+			environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, environ, []string{
+				"SOME_VAR=some-val",
+				"CNB_BUILDPACK_DIR=" + descriptor.WithRootDir,
+				"CNB_PLATFORM_DIR=" + platformDir,
+				"CNB_BP_PLAN_PATH=some-plan-path",
+				"CNB_LAYERS_DIR=" + filepath.Join(layersDir, "A"),
+			})
+		})
+
+		when("plan only", func() {
+			it("sets CNB_BUILD_PLAN_ONLY", func() {
+				inputs.PlanOnly = true
+				mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return([]string{"SOME_VAR=some-val"}, nil)
+
+				environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, environ[len(environ)-1], "CNB_BUILD_PLAN_ONLY=true")
+			})
+		})
+
+		when("clear env", func() {
+			it.Before(func() {
+				descriptor.Buildpack.ClearEnv = true
+			})
+
+			it("does not apply user-provided env vars from the platform directory", func() {
+				mockEnv.EXPECT().WithOverrides("", buildConfigDir).Return([]string{"SOME_VAR=some-val"}, nil)
+
+				_, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+			})
+
+			it("sets CNB_STACK_ID when a stack ID is provided", func() {
+				inputs.StackID = "some-stack-id"
+				mockEnv.EXPECT().WithOverrides("", buildConfigDir).Return([]string{"SOME_VAR=some-val"}, nil)
+
+				environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+
+				h.AssertContains(t, environ, "CNB_STACK_ID=some-stack-id")
+			})
+		})
+
+		when("global env file", func() {
+			it.Before(func() {
+				inputs.GlobalEnvFile = filepath.Join(tmpDir, "global.env")
+				h.Mkfile(t, "# some comment\nSOME_VAR=some-global-val\n\nOTHER_VAR=other-global-val\nNEW_VAR=new-global-val\n", inputs.GlobalEnvFile)
+				h.Mkfile(t, "other-platform-val", filepath.Join(platformDir, "env", "OTHER_VAR"))
+			})
+
+			it("adds the variables the build environment does not set, giving precedence to the platform directory", func() {
+				mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return([]string{"SOME_VAR=some-val", "OTHER_VAR=other-platform-val"}, nil)
+
+				environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, environ[:3], []string{
+					"SOME_VAR=some-val",
+					"OTHER_VAR=other-platform-val",
+					"NEW_VAR=new-global-val",
+				})
+			})
+
+			when("the build environment filters the lifecycle's environment", func() {
+				var layerDir string
+
+				it.Before(func() {
+					buildEnv := env.NewBuildEnv([]string{"PATH=/some/bin", "HOME=/some/home"})
+					// a previous buildpack contributed a layer with a bin directory
+					layerDir = filepath.Join(tmpDir, "some-layer")
+					h.Mkdir(t, filepath.Join(layerDir, "bin"))
+					h.AssertNil(t, buildEnv.AddRootDir(layerDir))
+					inputs.Env = buildEnv
+
+					h.Mkfile(t, "PATH=/global/bin\nHOME=/global/home\nHTTP_PROXY=some-proxy\nSOME_VAR=some-global-val\n", inputs.GlobalEnvFile)
+				})
+
+				it("keeps layer contributions to root dir variables and drops filtered variables", func() {
+					environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+					h.AssertNil(t, err)
+
+					sep := string(os.PathListSeparator)
+					h.AssertContains(t, environ,
+						"PATH=/global/bin"+sep+filepath.Join(layerDir, "bin")+sep+"/some/bin",
+						"HOME=/some/home",
+						"HTTP_PROXY=some-proxy",
+					)
+					for _, kv := range environ {
+						if strings.HasPrefix(kv, "SOME_VAR=") {
+							t.Fatalf("Expected SOME_VAR to be filtered out, found: %s", kv)
+						}
+					}
+				})
+			})
+
+			it("does not apply the file when the buildpack clears its environment", func() {
+				descriptor.Buildpack.ClearEnv = true
+				mockEnv.EXPECT().WithOverrides("", buildConfigDir).Return([]string{"SOME_VAR=some-val"}, nil)
+
+				environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, environ[0], "SOME_VAR=some-val")
+				h.AssertDoesNotContain(t, environ, "NEW_VAR=new-global-val")
+			})
+
+			it("errors when the file is malformed", func() {
+				h.Mkfile(t, "not-a-var\n", inputs.GlobalEnvFile)
+				mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return([]string{"SOME_VAR=some-val"}, nil)
+
+				_, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertError(t, err, "invalid line 1 in env file")
+			})
+		})
+
+		when("stack ID", func() {
+			it("overrides CNB_STACK_ID from the environment", func() {
+				inputs.StackID = "some-stack-id"
+				mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return([]string{"CNB_STACK_ID=some-env-stack-id", "SOME_VAR=some-val"}, nil)
+
+				environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+
+				h.AssertContains(t, environ, "CNB_STACK_ID=some-stack-id")
+				h.AssertDoesNotContain(t, environ, "CNB_STACK_ID=some-env-stack-id")
+			})
+
+			it("passes through CNB_STACK_ID from the environment when not provided", func() {
+				mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return([]string{"CNB_STACK_ID=some-env-stack-id"}, nil)
+
+				environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+
+				h.AssertContains(t, environ, "CNB_STACK_ID=some-env-stack-id")
+			})
+		})
+
+		when("buildpack api < 0.8", func() {
+			it.Before(func() {
+				descriptor.WithAPI = "0.7"
+			})
+
+			it("only provides CNB_BUILDPACK_DIR", func() {
+				mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return([]string{"SOME_VAR=some-val"}, nil)
+
+				environ, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, environ, []string{
+					"SOME_VAR=some-val",
+					"CNB_BUILDPACK_DIR=" + descriptor.WithRootDir,
+				})
+			})
+		})
+
+		it("errors when the environment cannot be computed", func() {
+			mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return(nil, errors.New("some error"))
+
+			_, err := buildpack.ResolveBuildEnv(descriptor, inputs, "some-plan-path")
+			h.AssertError(t, err, "some error")
+		})
+	})
 }
 
 func testExists(t *testing.T, paths ...string) {
@@ -1413,3 +2449,29 @@ func assertLogEntryNotContains(t *testing.T, logHandler *memory.Handler, expecte
 		}
 	}
 }
+
+// fakeClock is a Clock that advances by step each time it is read.
+type fakeClock struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// fakeTOMLDecoder records the files it decodes with encoding.BurntSushiTOML, returning the configured error for a file name instead if present.
+type fakeTOMLDecoder struct {
+	errs    map[string]error
+	decoded []string
+}
+
+func (d *fakeTOMLDecoder) DecodeTOMLFile(path string, v interface{}) error {
+	if err, ok := d.errs[filepath.Base(path)]; ok {
+		return err
+	}
+	d.decoded = append(d.decoded, path)
+	return encoding.BurntSushiTOML{}.DecodeTOMLFile(path, v)
+}
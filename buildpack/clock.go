@@ -0,0 +1,23 @@
+package buildpack
+
+import "time"
+
+// Clock provides the current time used to measure how long buildpacks and extensions take to run.
+// It may be substituted to measure durations deterministically (e.g., in tests) or with a different time source.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock that reads the system time.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func clockOrDefault(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock{}
+	}
+	return clock
+}
@@ -29,7 +29,11 @@ const (
 	errMissingRequiredStage             = "%s should have at least one stage"
 	errMultiStageNotPermitted           = "%s is not permitted to use multistage build"
 	errRunOtherInstructionsNotPermitted = "run.Dockerfile is not permitted to have instructions other than FROM"
+	errRunHardCodedBase                 = "run.Dockerfile for extension %s hard-codes base image '%s'; the FROM instruction must use " + baseImageArgRef
 	warnCommandNotRecommended           = "%s command %s on line %d is not recommended"
+
+	// MaxDockerfileContentsSize is the largest Dockerfile, in bytes, whose contents are loaded into DockerfileInfo.Contents.
+	MaxDockerfileContentsSize = 1024 * 1024
 )
 
 var recommendedCommands = []string{"FROM", "ADD", "ARG", "COPY", "ENV", "LABEL", "RUN", "SHELL", "USER", "WORKDIR"}
@@ -48,6 +52,22 @@ type DockerfileInfo struct {
 	// However if Extend is true, WithBase may be empty or non-empty.
 	Extend bool
 	Ignore bool
+	// DeclaredBase if populated is the base image the Dockerfile declares statically,
+	// either as the default value of the base_image build argument or as a literal image reference in the FROM instruction.
+	DeclaredBase string
+	// Contents if populated holds the contents of the Dockerfile; it is only loaded when requested by GenerateInputs.LoadDockerfileContents.
+	Contents []byte
+}
+
+func readDockerfileContents(path string) ([]byte, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() > MaxDockerfileContentsSize {
+		return nil, fmt.Errorf("file size %d exceeds the maximum of %d bytes", fi.Size(), MaxDockerfileContentsSize)
+	}
+	return os.ReadFile(path)
 }
 
 type ExtendConfig struct {
@@ -82,14 +102,20 @@ func parseDockerfile(dockerfile string) ([]instructions.Stage, []instructions.Ar
 }
 
 func ValidateBuildDockerfile(dockerfile string, logger log.Logger) error {
+	_, err := validateBuildDockerfile(dockerfile, logger)
+	return err
+}
+
+// validateBuildDockerfile validates the build.Dockerfile and returns the default value of the base_image build argument, if any.
+func validateBuildDockerfile(dockerfile string, logger log.Logger) (string, error) {
 	stages, margs, err := parseDockerfile(dockerfile)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// validate only 1 FROM
 	if len(stages) > 1 {
-		return fmt.Errorf(errMultiStageNotPermitted, buildDockerfileName)
+		return "", fmt.Errorf(errMultiStageNotPermitted, buildDockerfileName)
 	}
 
 	// validate only permitted Commands
@@ -110,25 +136,25 @@ func ValidateBuildDockerfile(dockerfile string, logger log.Logger) error {
 
 	// validate build.Dockerfile preamble
 	if len(margs) != 1 {
-		return errors.New(errBuildMissingRequiredARGCommand)
+		return "", errors.New(errBuildMissingRequiredARGCommand)
 	}
 	if margs[0].Args[0].Key != baseImageArgName {
-		return errors.New(errBuildMissingRequiredARGCommand)
+		return "", errors.New(errBuildMissingRequiredARGCommand)
 	}
 	// sanity check to prevent panic
 	if len(stages) == 0 {
-		return fmt.Errorf(errMissingRequiredStage, buildDockerfileName)
+		return "", fmt.Errorf(errMissingRequiredStage, buildDockerfileName)
 	}
 
 	if stages[0].BaseName != baseImageArgRef {
-		return errors.New(errBuildMissingRequiredFROMCommand)
+		return "", errors.New(errBuildMissingRequiredFROMCommand)
 	}
 
-	return nil
+	return baseImageArgDefault(margs), nil
 }
 
 func ValidateRunDockerfile(dInfo *DockerfileInfo, logger log.Logger) error {
-	stages, _, err := parseDockerfile(dInfo.Path)
+	stages, margs, err := parseDockerfile(dInfo.Path)
 	if err != nil {
 		return err
 	}
@@ -169,5 +195,21 @@ func ValidateRunDockerfile(dInfo *DockerfileInfo, logger log.Logger) error {
 
 	dInfo.WithBase = newBase
 	dInfo.Extend = extend
+	dInfo.DeclaredBase = newBase
+	if dInfo.DeclaredBase == "" {
+		dInfo.DeclaredBase = baseImageArgDefault(margs)
+	}
 	return nil
 }
+
+// baseImageArgDefault returns the default value of the base_image build argument, or an empty string if none is provided.
+func baseImageArgDefault(metaArgs []instructions.ArgCommand) string {
+	for _, metaArg := range metaArgs {
+		for _, arg := range metaArg.Args {
+			if arg.Key == baseImageArgName && arg.Value != nil {
+				return *arg.Value
+			}
+		}
+	}
+	return ""
+}
@@ -234,6 +234,19 @@ FROM ${base_image}
 						h.AssertNil(t, err)
 						h.AssertEq(t, dInfo.WithBase, "some-base-image")
 						h.AssertEq(t, dInfo.Extend, false)
+						h.AssertEq(t, dInfo.DeclaredBase, "some-base-image")
+					})
+				})
+
+				when("the base_image argument has a default value", func() {
+					it("returns the declared base image", func() {
+						dockerfilePath := filepath.Join(tmpDir, "run.Dockerfile")
+						h.AssertNil(t, os.WriteFile(dockerfilePath, []byte("ARG base_image=some-declared-image\nFROM ${base_image}\nRUN echo hello"), 0600))
+						dInfo := &buildpack.DockerfileInfo{Path: dockerfilePath}
+						err := buildpack.ValidateRunDockerfile(dInfo, logger)
+						h.AssertNil(t, err)
+						h.AssertEq(t, dInfo.WithBase, "")
+						h.AssertEq(t, dInfo.DeclaredBase, "some-declared-image")
 					})
 				})
 			})
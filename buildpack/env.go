@@ -0,0 +1,196 @@
+package buildpack
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/buildpacks/lifecycle/env"
+)
+
+// prepareEnv returns the environment for a buildpack or extension executable.
+// If clearEnv is true, user-provided environment variables from <platform>/env and globalEnvFile are not loaded.
+// Variables from globalEnvFile, if provided, are merged into the result as described by mergeGlobalEnv.
+// Any provided CNB_* variables (in KEY=VALUE form) are appended to the result.
+func prepareEnv(buildEnv BuildEnv, clearEnv bool, platformDir, buildConfigDir, globalEnvFile string, cnbVars ...string) ([]string, error) {
+	var (
+		environ []string
+		err     error
+	)
+	if clearEnv {
+		environ, err = buildEnv.WithOverrides("", buildConfigDir)
+	} else {
+		environ, err = buildEnv.WithOverrides(platformDir, buildConfigDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !clearEnv && globalEnvFile != "" {
+		if environ, err = mergeGlobalEnv(environ, globalEnvFile, platformDir, buildEnv); err != nil {
+			return nil, err
+		}
+	}
+	return append(environ, cnbVars...), nil
+}
+
+// Phase is the executable a buildpack or extension is run as.
+type Phase string
+
+const (
+	PhaseBuild    Phase = "build"
+	PhaseGenerate Phase = "generate"
+)
+
+// Dirs holds the paths provided to a buildpack or extension in CNB_* variables.
+type Dirs struct {
+	BuildpackDir string // the buildpack root directory
+	ExtensionDir string // the extension root directory
+	LayersDir    string // the buildpack's own layers directory, e.g., <layers>/<buildpack-id>
+	OutputDir    string // the extension's output directory
+	PlatformDir  string
+	PlanPath     string // the buildpack plan
+}
+
+// CNBVars returns the CNB_* variables (in KEY=VALUE form) that provide dirs to a buildpack or extension in the given phase.
+// Only the dirs relevant to the phase are included, and empty dirs are omitted.
+func CNBVars(phase Phase, dirs Dirs) []string {
+	var vars []string
+	add := func(name, value string) {
+		if value != "" {
+			vars = append(vars, name+"="+value)
+		}
+	}
+	switch phase {
+	case PhaseBuild:
+		add(EnvBuildpackDir, dirs.BuildpackDir)
+		add(EnvPlatformDir, dirs.PlatformDir)
+		add(EnvBpPlanPath, dirs.PlanPath)
+		add(EnvLayersDir, dirs.LayersDir)
+	case PhaseGenerate:
+		add(EnvBpPlanPath, dirs.PlanPath)
+		add(EnvExtensionDir, dirs.ExtensionDir)
+		add(EnvOutputDir, dirs.OutputDir)
+		add(EnvPlatformDir, dirs.PlatformDir)
+	}
+	return vars
+}
+
+// envFilter is implemented by build environments (e.g., *env.Env) that know which variables
+// are filtered out of the lifecycle's environment, and which variables are root dir variables (e.g., PATH).
+type envFilter interface {
+	IsExcluded(name string) bool
+	IsRootEnv(name string) bool
+}
+
+// mergeGlobalEnv merges the variables in the env file at path into environ.
+// Variables provided in <platformDir>/env, and variables that buildEnv filters out of the lifecycle's environment, are skipped.
+// Other variables are set if environ does not already set them; root dir variables that are already set
+// have the global value prepended, so that the contributions of buildpack layers are kept.
+func mergeGlobalEnv(environ []string, path, platformDir string, buildEnv BuildEnv) ([]string, error) {
+	global, err := readEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+	filter, _ := buildEnv.(envFilter)
+	out := append([]string{}, environ...)
+	for _, kv := range global {
+		k, v := kv[0], kv[1]
+		if platformDir != "" {
+			if _, err := os.Stat(filepath.Join(platformDir, "env", k)); err == nil {
+				continue
+			}
+		}
+		if filter != nil && filter.IsExcluded(k) {
+			continue
+		}
+		idx := indexOfVar(out, k)
+		switch {
+		case idx < 0:
+			out = append(out, k+"="+v)
+		case filter != nil && filter.IsRootEnv(k):
+			out[idx] = k + "=" + v + string(os.PathListSeparator) + strings.SplitN(out[idx], "=", 2)[1]
+		}
+	}
+	return out, nil
+}
+
+// indexOfVar returns the index of the last KEY=VALUE entry in environ for name, or -1 if it is not set.
+func indexOfVar(environ []string, name string) int {
+	for idx := len(environ) - 1; idx >= 0; idx-- {
+		if strings.HasPrefix(environ[idx], name+"=") {
+			return idx
+		}
+	}
+	return -1
+}
+
+// readEnvFile reads the KEY=VALUE pairs in the file at path, in order.
+// Blank lines and lines starting with '#' are ignored.
+func readEnvFile(path string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading env file '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	var pairs [][2]string
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok || !env.IsValidVarName(k) {
+			return nil, fmt.Errorf("invalid line %d in env file '%s': expected KEY=VALUE", lineNum, path)
+		}
+		pairs = append(pairs, [2]string{k, v})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading env file '%s': %w", path, err)
+	}
+	return pairs, nil
+}
+
+// EnvChange describes a change a buildpack's layers made to the build environment.
+type EnvChange struct {
+	Key string
+	// Before is the value of the variable before the change, or empty if the variable was not set.
+	Before string
+	// After is the value of the variable after the change, or empty if the variable was removed.
+	After string
+}
+
+// diffEnv returns the changes between two environments in KEY=VALUE form, sorted by key.
+func diffEnv(before, after []string) []EnvChange {
+	beforeVals, afterVals := envMap(before), envMap(after)
+	var changes []EnvChange
+	for key, afterVal := range afterVals {
+		if beforeVal, ok := beforeVals[key]; !ok || beforeVal != afterVal {
+			changes = append(changes, EnvChange{Key: key, Before: beforeVal, After: afterVal})
+		}
+	}
+	for key, beforeVal := range beforeVals {
+		if _, ok := afterVals[key]; !ok {
+			changes = append(changes, EnvChange{Key: key, Before: beforeVal})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+func envMap(environ []string) map[string]string {
+	vals := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			vals[parts[0]] = parts[1]
+		}
+	}
+	return vals
+}
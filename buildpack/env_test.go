@@ -0,0 +1,64 @@
+package buildpack_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/buildpack"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestEnv(t *testing.T) {
+	spec.Run(t, "unit-env", testEnv, spec.Report(report.Terminal{}))
+}
+
+func testEnv(t *testing.T, when spec.G, it spec.S) {
+	when("#CNBVars", func() {
+		var dirs buildpack.Dirs
+
+		it.Before(func() {
+			dirs = buildpack.Dirs{
+				BuildpackDir: "some-buildpack-dir",
+				ExtensionDir: "some-extension-dir",
+				LayersDir:    "some-layers-dir",
+				OutputDir:    "some-output-dir",
+				PlatformDir:  "some-platform-dir",
+				PlanPath:     "some-plan-path",
+			}
+		})
+
+		when("build", func() {
+			it("returns the variables provided to buildpacks", func() {
+				h.AssertEq(t, buildpack.CNBVars(buildpack.PhaseBuild, dirs), []string{
+					"CNB_BUILDPACK_DIR=some-buildpack-dir",
+					"CNB_PLATFORM_DIR=some-platform-dir",
+					"CNB_BP_PLAN_PATH=some-plan-path",
+					"CNB_LAYERS_DIR=some-layers-dir",
+				})
+			})
+
+			it("omits empty dirs", func() {
+				h.AssertEq(t, buildpack.CNBVars(buildpack.PhaseBuild, buildpack.Dirs{BuildpackDir: "some-buildpack-dir"}), []string{
+					"CNB_BUILDPACK_DIR=some-buildpack-dir",
+				})
+			})
+		})
+
+		when("generate", func() {
+			it("returns the variables provided to extensions", func() {
+				h.AssertEq(t, buildpack.CNBVars(buildpack.PhaseGenerate, dirs), []string{
+					"CNB_BP_PLAN_PATH=some-plan-path",
+					"CNB_EXTENSION_DIR=some-extension-dir",
+					"CNB_OUTPUT_DIR=some-output-dir",
+					"CNB_PLATFORM_DIR=some-platform-dir",
+				})
+			})
+		})
+
+		it("returns no variables for an unknown phase", func() {
+			h.AssertEq(t, len(buildpack.CNBVars("some-phase", dirs)), 0)
+		})
+	})
+}
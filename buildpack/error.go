@@ -1,13 +1,25 @@
 package buildpack
 
+import (
+	"errors"
+	"io/fs"
+	"os/exec"
+)
+
 type ErrorType string
 
 const ErrTypeBuildpack ErrorType = "ERR_BUILDPACK"
 const ErrTypeFailedDetection ErrorType = "ERR_FAILED_DETECTION"
 
+// ErrTypeIO indicates that the lifecycle failed to read or write a file,
+// as opposed to ErrTypeBuildpack which indicates that the buildpack or extension executable failed.
+const ErrTypeIO ErrorType = "ERR_IO"
+
 type Error struct {
 	RootError error
 	Type      ErrorType
+	// ExitCode is the exit code of the failed buildpack or extension executable, if it ran to completion; otherwise it is 0.
+	ExitCode int
 }
 
 func (le *Error) Error() string {
@@ -24,3 +36,23 @@ func (le *Error) Cause() error {
 func NewError(cause error, errType ErrorType) *Error {
 	return &Error{RootError: cause, Type: errType}
 }
+
+// newExecError returns an Error of the given type, recording the exit code if the cause is an *exec.ExitError.
+func newExecError(cause error, errType ErrorType) *Error {
+	bpErr := NewError(cause, errType)
+	var exitErr *exec.ExitError
+	if errors.As(cause, &exitErr) {
+		bpErr.ExitCode = exitErr.ExitCode()
+	}
+	return bpErr
+}
+
+// wrapError returns an Error of type ErrTypeIO if the cause originated from a filesystem operation,
+// or of type ErrTypeBuildpack otherwise (e.g., the buildpack or extension wrote invalid file contents).
+func wrapError(cause error) *Error {
+	var pathErr *fs.PathError
+	if errors.As(cause, &pathErr) {
+		return NewError(cause, ErrTypeIO)
+	}
+	return NewError(cause, ErrTypeBuildpack)
+}
@@ -0,0 +1,8 @@
+//go:build linux
+// +build linux
+
+package buildpack
+
+// ApplyResourceLimits exposes applyResourceLimits to the tests in package buildpack_test,
+// as limits can only be applied by a build when the lifecycle is able to create cgroups.
+var ApplyResourceLimits = applyResourceLimits
@@ -8,6 +8,7 @@ import (
 	"github.com/BurntSushi/toml"
 
 	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/internal/encoding"
 	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/layers"
 )
@@ -48,7 +49,7 @@ func DecodeLaunchTOML(launchPath string, bpAPI string, launchTOML *LaunchTOML) e
 		if commandsAreStrings {
 			var commandString string
 			if err = md.PrimitiveDecode(process.RawCommandValue, &commandString); err != nil {
-				return err
+				return fmt.Errorf("process type '%s': command must be a string for buildpack API %s: %w", process.Type, bpAPI, err)
 			}
 			// legacy Direct defaults to false
 			if process.Direct == nil {
@@ -63,7 +64,7 @@ func DecodeLaunchTOML(launchPath string, bpAPI string, launchTOML *LaunchTOML) e
 			}
 			var command []string
 			if err = md.PrimitiveDecode(process.RawCommandValue, &command); err != nil {
-				return err
+				return fmt.Errorf("process type '%s': command must be a list of strings for buildpack API %s: %w", process.Type, bpAPI, err)
 			}
 			launchTOML.Processes[i].Command = command
 		}
@@ -74,6 +75,12 @@ func DecodeLaunchTOML(launchPath string, bpAPI string, launchTOML *LaunchTOML) e
 
 // ToLaunchProcess converts a buildpack.ProcessEntry to a launch.Process
 func (p *ProcessEntry) ToLaunchProcess(bpID string) launch.Process {
+	process := p.toLaunchProcess()
+	process.BuildpackID = bpID
+	return process
+}
+
+func (p *ProcessEntry) toLaunchProcess() launch.Process {
 	// legacy processes will always have a value
 	// new processes will have a nil value but are always direct processes
 	var direct bool
@@ -89,23 +96,46 @@ func (p *ProcessEntry) ToLaunchProcess(bpID string) launch.Process {
 		Args:             p.Args,
 		Direct:           direct, // launch.Process requires a value
 		Default:          p.Default,
-		BuildpackID:      bpID,
 		WorkingDirectory: p.WorkingDirectory,
 	}
 }
 
 // converts launch.toml processes to launch.Processes
 func (lt LaunchTOML) ToLaunchProcessesForBuildpack(bpID string) []launch.Process {
-	var processes []launch.Process
+	processes := make([]launch.Process, 0, len(lt.Processes))
 	for _, process := range lt.Processes {
-		processes = append(processes, process.ToLaunchProcess(bpID))
+		processes = append(processes, process.toLaunchProcess())
 	}
-	return processes
+	return launch.AttributeProcesses(processes, bpID)
 }
 
 type BOMEntry struct {
 	Require
 	Buildpack GroupElement `toml:"buildpack" json:"buildpack"`
+	// Source records the buildpack output that the entry was read from, for tracing within the lifecycle;
+	// it is empty when unknown, and it is never serialized, so BOM formats are unchanged.
+	Source BOMSource `toml:"-" json:"-"`
+}
+
+// BOMSource identifies where a BOM entry came from.
+type BOMSource string
+
+const (
+	// BOMSourceInputPlan marks entries taken unmodified from the buildpack plan provided to the buildpack (Buildpack API < 0.5).
+	BOMSourceInputPlan BOMSource = "input-plan"
+	// BOMSourceOutputPlan marks entries from the buildpack plan as refined by the buildpack (Buildpack API < 0.5).
+	BOMSourceOutputPlan BOMSource = "output-plan"
+	// BOMSourceLaunchTOML marks entries from launch.toml.
+	BOMSourceLaunchTOML BOMSource = "launch.toml"
+	// BOMSourceBuildTOML marks entries from build.toml.
+	BOMSourceBuildTOML BOMSource = "build.toml"
+)
+
+func withSource(bom []BOMEntry, source BOMSource) []BOMEntry {
+	for i := range bom {
+		bom[i].Source = source
+	}
+	return bom
 }
 
 func (bom *BOMEntry) ConvertMetadataToVersion() {
@@ -173,14 +203,39 @@ type Label struct {
 // build.toml
 
 type BuildTOML struct {
-	BOM   []BOMEntry `toml:"bom"`
-	Unmet []Unmet    `toml:"unmet"`
+	BOM      []BOMEntry `toml:"bom"`
+	Unmet    []Unmet    `toml:"unmet"`
+	Provides []Provide  `toml:"provides"` // extensions only: dependencies satisfied beyond the input plan
+	Labels   []Label    `toml:"labels"`   // extensions only: labels to apply to the extended run image
 }
 
 type Unmet struct {
 	Name string `toml:"name"`
 }
 
+// cache.toml
+
+// CacheTOML is the optional <layers>/<buildpack-id>/cache.toml file
+// in which a buildpack may describe the dependencies it stored in cached layers.
+type CacheTOML struct {
+	Dependencies []CachedDep `toml:"dependencies"`
+}
+
+// CachedDep is a dependency stored in a cached layer.
+type CachedDep struct {
+	Name    string `toml:"name" json:"name"`
+	Version string `toml:"version,omitempty" json:"version,omitempty"`
+	Layer   string `toml:"layer" json:"layer"`
+}
+
+// warnings.toml
+
+// WarningsTOML is the optional <layers>/<buildpack-id>/warnings.toml file
+// in which a buildpack may record non-fatal warnings for the platform to relay to the user.
+type WarningsTOML struct {
+	Warnings []string `toml:"warnings"`
+}
+
 // store.toml
 
 type StoreTOML struct {
@@ -288,14 +343,29 @@ func (p Plan) filter(unmet []Unmet) Plan {
 	return Plan{Entries: out}
 }
 
-func (p Plan) toBOM() []BOMEntry {
+// toBOM converts the plan entries to BOM entries,
+// marking each entry as coming from the input plan if the buildpack left it unchanged, or from the output plan otherwise.
+func (p Plan) toBOM(in Plan) []BOMEntry {
 	var bom []BOMEntry
 	for _, entry := range p.Entries {
-		bom = append(bom, BOMEntry{Require: entry})
+		source := BOMSourceOutputPlan
+		if in.contains(entry) {
+			source = BOMSourceInputPlan
+		}
+		bom = append(bom, BOMEntry{Require: entry, Source: source})
 	}
 	return bom
 }
 
+func (p Plan) contains(entry Require) bool {
+	for _, e := range p.Entries {
+		if encoding.ToJSONMaybe(e) == encoding.ToJSONMaybe(entry) {
+			return true
+		}
+	}
+	return false
+}
+
 func containsName(unmet []Unmet, name string) bool {
 	for _, u := range unmet {
 		if u.Name == name {
@@ -6,8 +6,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
 
 	"github.com/buildpacks/lifecycle/internal/extend"
+	"github.com/buildpacks/lifecycle/internal/fsutil"
 	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/log"
 )
@@ -27,11 +31,46 @@ type GenerateInputs struct {
 	Env            BuildEnv
 	Out, Err       io.Writer
 	Plan           Plan
+	CleanOutput    bool // if true, any files left in the extension output directory from a previous run are removed before generating
+	// LoadDockerfileContents if true causes the contents of each generated Dockerfile to be read into DockerfileInfo.Contents;
+	// Dockerfiles larger than MaxDockerfileContentsSize cause an error.
+	LoadDockerfileContents bool
+	// Clock, if provided, is used to measure GenerateOutputs.Duration; it defaults to SystemClock.
+	Clock Clock
+	// StrictDockerfiles if true causes Generate to fail when a run.Dockerfile hard-codes its base image in the FROM instruction
+	// instead of using ${base_image}; this precludes switching the run image with a literal FROM reference.
+	// build.Dockerfiles are not affected.
+	StrictDockerfiles bool
 }
 
 type GenerateOutputs struct {
-	Dockerfiles []DockerfileInfo
+	Dockerfiles []DockerfileInfo // the build.Dockerfile (if any) followed by the run.Dockerfile (if any)
+	Labels      []Label
 	MetRequires []string
+	// Duration is the time taken by the extension's generate command, as measured by GenerateInputs.Clock; it is zero if no command was run.
+	Duration time.Duration
+}
+
+// Clone returns a deep copy of the GenerateOutputs, so that the copy can be modified or appended to
+// without affecting the original.
+func (g GenerateOutputs) Clone() GenerateOutputs {
+	clone := g
+	if g.Dockerfiles != nil {
+		clone.Dockerfiles = make([]DockerfileInfo, len(g.Dockerfiles))
+		for i, dockerfile := range g.Dockerfiles {
+			if dockerfile.Contents != nil {
+				dockerfile.Contents = append([]byte{}, dockerfile.Contents...)
+			}
+			clone.Dockerfiles[i] = dockerfile
+		}
+	}
+	if g.Labels != nil {
+		clone.Labels = append([]Label{}, g.Labels...)
+	}
+	if g.MetRequires != nil {
+		clone.MetRequires = append([]string{}, g.MetRequires...)
+	}
+	return clone
 }
 
 //go:generate mockgen -package testmock -destination ../testmock/generate_executor.go github.com/buildpacks/lifecycle/buildpack GenerateExecutor
@@ -42,33 +81,166 @@ type GenerateExecutor interface {
 type DefaultGenerateExecutor struct{}
 
 func (e *DefaultGenerateExecutor) Generate(d ExtDescriptor, inputs GenerateInputs, logger log.Logger) (GenerateOutputs, error) {
+	var err error
+	// the generate command runs in the app directory, so a relative root directory would not resolve correctly
+	if d.WithRootDir != "" && !filepath.IsAbs(d.WithRootDir) {
+		if d.WithRootDir, err = filepath.Abs(d.WithRootDir); err != nil {
+			return GenerateOutputs{}, NewError(fmt.Errorf("failed to resolve root directory for extension %s: %w", d.Extension.ID, err), ErrTypeIO)
+		}
+	}
+
 	logger.Debug("Creating plan directory")
 	planDir, err := os.MkdirTemp("", launch.EscapeID(d.Extension.ID)+"-")
 	if err != nil {
-		return GenerateOutputs{}, err
+		return GenerateOutputs{}, NewError(err, ErrTypeIO)
 	}
 	defer os.RemoveAll(planDir)
 
 	logger.Debug("Preparing paths")
-	extOutputDir, planPath, err := prepareInputPaths(d.Extension.ID, inputs.Plan, inputs.OutputDir, planDir)
+	extOutputDir, planPath, err := prepareInputPaths(d.Extension.ID, inputs.Plan, "", inputs.OutputDir, planDir, 0777)
 	if err != nil {
-		return GenerateOutputs{}, err
+		return GenerateOutputs{}, NewError(err, ErrTypeIO)
+	}
+	if inputs.CleanOutput {
+		logger.Debug("Cleaning output directory")
+		if err = cleanDir(extOutputDir, logger); err != nil {
+			return GenerateOutputs{}, NewError(fmt.Errorf("failed to clean output directory for extension %s: %w", d.Extension.ID, err), ErrTypeIO)
+		}
 	}
 
 	logger.Debug("Running generate command")
 	if _, err = os.Stat(filepath.Join(d.WithRootDir, "bin", "generate")); err != nil {
 		if os.IsNotExist(err) {
-			// treat extension root directory as pre-populated output directory
-			return readOutputFilesExt(d, filepath.Join(d.WithRootDir, "generate"), inputs.Plan, logger)
+			// treat extension root directory as pre-populated output directory;
+			// outputs are copied so that the extension root is never referenced (or modified) in place
+			prePopulatedDir := filepath.Join(d.WithRootDir, "generate")
+			if err = checkMisplacedDockerfiles(d, prePopulatedDir, d.WithRootDir); err != nil {
+				return GenerateOutputs{}, NewError(err, ErrTypeBuildpack)
+			}
+			if err = fsutil.Copy(prePopulatedDir, extOutputDir); err != nil && !os.IsNotExist(err) {
+				return GenerateOutputs{}, NewError(fmt.Errorf("failed to copy pre-populated output for extension %s: %w", d.Extension.ID, err), ErrTypeIO)
+			}
+			return readOutputFilesExt(d, extOutputDir, inputs, logger)
 		}
-		return GenerateOutputs{}, err
+		return GenerateOutputs{}, NewError(err, ErrTypeIO)
 	}
+	clock := clockOrDefault(inputs.Clock)
+	start := clock.Now()
 	if err = runGenerateCmd(d, extOutputDir, planPath, inputs); err != nil {
 		return GenerateOutputs{}, err
 	}
+	duration := clock.Now().Sub(start)
+	logger.Debugf("Generate command for extension %s completed in %s", d.Extension.ID, duration)
 
 	logger.Debug("Reading output files")
-	return readOutputFilesExt(d, extOutputDir, inputs.Plan, logger)
+	if err = checkMisplacedDockerfiles(d, extOutputDir, filepath.Join(extOutputDir, "generate")); err != nil {
+		return GenerateOutputs{}, NewError(err, ErrTypeBuildpack)
+	}
+	gr, err := readOutputFilesExt(d, extOutputDir, inputs, logger)
+	if err != nil {
+		return GenerateOutputs{}, err
+	}
+	gr.Duration = duration
+	return gr, nil
+}
+
+// GenerateGroupOutputs holds the aggregated outputs of running generate for an ordered group of extensions.
+type GenerateGroupOutputs struct {
+	Dockerfiles []DockerfileInfo // in extension order
+	Labels      []Label          // in extension order; when extensions provide the same key, the last value wins
+	MetRequires []string         // requires met by any extension in the group
+	// RunImage is the run image selected by the last run.Dockerfile that switches the run image; it is empty if no extension switched it.
+	RunImage string
+}
+
+// GenerateGroup runs generate for each of the provided extensions in order.
+// Each extension receives inputs.Plan without the entries already met by extensions before it in the group.
+// If more than one extension switches the run image or provides the same label, the last one wins and a warning is logged.
+func GenerateGroup(executor GenerateExecutor, exts []ExtDescriptor, inputs GenerateInputs, logger log.Logger) (GenerateGroupOutputs, error) {
+	var (
+		outputs     GenerateGroupOutputs
+		runImageExt string
+		labelExts   = make(map[string]string)
+	)
+	filteredPlan := inputs.Plan
+	for _, ext := range exts {
+		logger.Debugf("Running generate for extension %s", ext.Extension.ID)
+		extInputs := inputs
+		extInputs.Plan = filteredPlan
+		result, err := executor.Generate(ext, extInputs, logger)
+		if err != nil {
+			return GenerateGroupOutputs{}, err
+		}
+
+		for _, dockerfile := range result.Dockerfiles {
+			if dockerfile.Kind == DockerfileKindRun && dockerfile.WithBase != "" {
+				if outputs.RunImage != "" && outputs.RunImage != dockerfile.WithBase {
+					logger.Warnf("Extension %s switches the run image to '%s', overriding '%s' from extension %s", ext.Extension.ID, dockerfile.WithBase, outputs.RunImage, runImageExt)
+				}
+				outputs.RunImage = dockerfile.WithBase
+				runImageExt = ext.Extension.ID
+			}
+		}
+		for _, label := range result.Labels {
+			if prevExt, ok := labelExts[label.Key]; ok {
+				logger.Warnf("Extension %s provides label '%s', overriding the value from extension %s", ext.Extension.ID, label.Key, prevExt)
+				outputs.Labels = removeLabel(outputs.Labels, label.Key)
+			}
+			labelExts[label.Key] = ext.Extension.ID
+			outputs.Labels = append(outputs.Labels, label)
+		}
+		outputs.Dockerfiles = append(outputs.Dockerfiles, result.Dockerfiles...)
+		outputs.MetRequires = appendMissing(outputs.MetRequires, result.MetRequires...)
+		filteredPlan = filteredPlan.filter(toUnmet(result.MetRequires))
+	}
+	return outputs, nil
+}
+
+func removeLabel(labels []Label, key string) []Label {
+	var out []Label
+	for _, label := range labels {
+		if label.Key != key {
+			out = append(out, label)
+		}
+	}
+	return out
+}
+
+func appendMissing(names []string, toAdd ...string) []string {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, name := range toAdd {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func toUnmet(names []string) []Unmet {
+	var unmet []Unmet
+	for _, name := range names {
+		unmet = append(unmet, Unmet{Name: name})
+	}
+	return unmet
+}
+
+func cleanDir(dir string, logger log.Logger) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		logger.Infof("Removing stale output '%s'", path)
+		if err = os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func runGenerateCmd(d ExtDescriptor, extOutputDir, planPath string, inputs GenerateInputs) error {
@@ -83,59 +255,130 @@ func runGenerateCmd(d ExtDescriptor, extOutputDir, planPath string, inputs Gener
 	cmd.Stderr = inputs.Err
 
 	var err error
-	if d.Extension.ClearEnv {
-		cmd.Env, err = inputs.Env.WithOverrides("", inputs.BuildConfigDir)
-	} else {
-		cmd.Env, err = inputs.Env.WithOverrides(inputs.PlatformDir, inputs.BuildConfigDir)
-	}
+	cmd.Env, err = prepareEnv(inputs.Env, d.Extension.ClearEnv, inputs.PlatformDir, inputs.BuildConfigDir, "",
+		CNBVars(PhaseGenerate, Dirs{
+			ExtensionDir: d.WithRootDir,
+			OutputDir:    extOutputDir,
+			PlatformDir:  inputs.PlatformDir,
+			PlanPath:     planPath,
+		})...,
+	)
 	if err != nil {
-		return err
+		return NewError(err, ErrTypeIO)
 	}
-	cmd.Env = append(cmd.Env,
-		EnvBpPlanPath+"="+planPath,
-		EnvExtensionDir+"="+d.WithRootDir,
-		EnvOutputDir+"="+extOutputDir,
-		EnvPlatformDir+"="+inputs.PlatformDir,
-	)
 
 	if err := cmd.Run(); err != nil {
-		return NewError(err, ErrTypeBuildpack)
+		return newExecError(err, ErrTypeBuildpack)
 	}
 	return nil
 }
 
-func readOutputFilesExt(d ExtDescriptor, extOutputDir string, extPlanIn Plan, logger log.Logger) (GenerateOutputs, error) {
+func readOutputFilesExt(d ExtDescriptor, extOutputDir string, inputs GenerateInputs, logger log.Logger) (GenerateOutputs, error) {
 	gr := GenerateOutputs{}
 	var err error
 	var dfInfo DockerfileInfo
 	var found bool
 
-	// set MetRequires
-	gr.MetRequires = names(extPlanIn.Entries)
+	// read build.toml
+	var buildTOML BuildTOML
+	buildPath := filepath.Join(extOutputDir, "build.toml")
+	if _, err = toml.DecodeFile(buildPath, &buildTOML); err != nil && !os.IsNotExist(err) {
+		return GenerateOutputs{}, wrapError(err)
+	}
+
+	// set MetRequires and Labels
+	if err = validateUnmet(buildTOML.Unmet, inputs.Plan); err != nil {
+		return GenerateOutputs{}, NewError(fmt.Errorf("invalid build.toml for extension %s: %w", d.Extension.ID, err), ErrTypeBuildpack)
+	}
+	gr.MetRequires = metRequiresExt(inputs.Plan, buildTOML)
+	gr.Labels = append([]Label{}, buildTOML.Labels...)
 
 	// validate extend config
 	if err = extend.ValidateConfig(filepath.Join(extOutputDir, "extend-config.toml")); err != nil {
-		return GenerateOutputs{}, err
+		return GenerateOutputs{}, wrapError(err)
 	}
 
-	// set Dockerfiles
-	if dfInfo, found, err = findDockerfileFor(d, extOutputDir, DockerfileKindRun, logger); err != nil {
-		return GenerateOutputs{}, err
-	} else if found {
-		gr.Dockerfiles = append(gr.Dockerfiles, dfInfo)
+	// set Dockerfiles; build Dockerfiles are always ordered before run Dockerfiles
+	for _, kind := range []string{DockerfileKindBuild, DockerfileKindRun} {
+		if dfInfo, found, err = findDockerfileFor(d, extOutputDir, kind, logger); err != nil {
+			return GenerateOutputs{}, err
+		} else if found {
+			if inputs.StrictDockerfiles && kind == DockerfileKindRun && dfInfo.WithBase != "" {
+				return GenerateOutputs{}, NewError(fmt.Errorf(errRunHardCodedBase, d.Extension.ID, dfInfo.WithBase), ErrTypeBuildpack)
+			}
+			if inputs.LoadDockerfileContents {
+				if dfInfo.Contents, err = readDockerfileContents(dfInfo.Path); err != nil {
+					return GenerateOutputs{}, wrapError(fmt.Errorf("failed to read %s.Dockerfile for extension %s: %w", kind, d.Extension.ID, err))
+				}
+			}
+			gr.Dockerfiles = append(gr.Dockerfiles, dfInfo)
+		}
 	}
 
-	if dfInfo, found, err = findDockerfileFor(d, extOutputDir, DockerfileKindBuild, logger); err != nil {
-		return GenerateOutputs{}, err
-	} else if found {
-		gr.Dockerfiles = append(gr.Dockerfiles, dfInfo)
-	}
+	warnOnConflictingBases(d, gr.Dockerfiles, logger)
 
 	logger.Debugf("Found '%d' Dockerfiles for processing", len(gr.Dockerfiles))
 
 	return gr, nil
 }
 
+// metRequiresExt returns the names of the plan entries that were not declared unmet,
+// followed by any additional dependencies the extension declared as provided.
+func metRequiresExt(extPlanIn Plan, buildTOML BuildTOML) []string {
+	metRequires := names(extPlanIn.filter(buildTOML.Unmet).Entries)
+	seen := make(map[string]bool)
+	for _, name := range metRequires {
+		seen[name] = true
+	}
+	for _, provide := range buildTOML.Provides {
+		if provide.Name == "" || seen[provide.Name] || containsName(buildTOML.Unmet, provide.Name) {
+			continue
+		}
+		seen[provide.Name] = true
+		metRequires = append(metRequires, provide.Name)
+	}
+	return metRequires
+}
+
+// warnOnConflictingBases warns if the run.Dockerfile and build.Dockerfile for an extension statically declare different base images.
+func warnOnConflictingBases(d ExtDescriptor, dockerfiles []DockerfileInfo, logger log.Logger) {
+	var runBase, buildBase string
+	for _, dockerfile := range dockerfiles {
+		switch dockerfile.Kind {
+		case DockerfileKindRun:
+			runBase = dockerfile.DeclaredBase
+		case DockerfileKindBuild:
+			buildBase = dockerfile.DeclaredBase
+		}
+	}
+	if runBase != "" && buildBase != "" && runBase != buildBase {
+		logger.Warnf("run.Dockerfile and build.Dockerfile for extension %s declare conflicting base images: '%s' and '%s'", d.Extension.ID, runBase, buildBase)
+	}
+}
+
+// checkMisplacedDockerfiles returns an error if a Dockerfile is missing from expectedDir but present in one of misplacedDirs.
+// Dockerfiles are expected at:
+//   - <output>/<extension-id>/<kind>.Dockerfile when the extension provides a bin/generate executable
+//   - <extension-root>/generate/<kind>.Dockerfile when the extension root is treated as a pre-populated output directory
+func checkMisplacedDockerfiles(d ExtDescriptor, expectedDir string, misplacedDirs ...string) error {
+	for _, kind := range []string{DockerfileKindRun, DockerfileKindBuild} {
+		dockerfileName := fmt.Sprintf("%s.Dockerfile", kind)
+		if _, err := os.Stat(filepath.Join(expectedDir, dockerfileName)); err == nil {
+			continue
+		}
+		for _, dir := range misplacedDirs {
+			misplacedPath := filepath.Join(dir, dockerfileName)
+			if _, err := os.Stat(misplacedPath); err == nil {
+				return fmt.Errorf(
+					"found %s for extension %s at unexpected location '%s'; it should be written to '%s'",
+					dockerfileName, d.Extension.ID, misplacedPath, filepath.Join(expectedDir, dockerfileName),
+				)
+			}
+		}
+	}
+	return nil
+}
+
 func findDockerfileFor(d ExtDescriptor, extOutputDir string, kind string, logger log.Logger) (DockerfileInfo, bool, error) {
 	var err error
 	dockerfilePath := filepath.Join(extOutputDir, fmt.Sprintf("%s.Dockerfile", kind))
@@ -143,14 +386,14 @@ func findDockerfileFor(d ExtDescriptor, extOutputDir string, kind string, logger
 		// ignore file not found, no Dockerfile to add.
 		if !os.IsNotExist(err) {
 			// any other errors are critical.
-			return DockerfileInfo{}, true, err
+			return DockerfileInfo{}, true, NewError(err, ErrTypeIO)
 		}
 		return DockerfileInfo{}, false, nil
 	}
 
 	dInfo := DockerfileInfo{ExtensionID: d.Extension.ID, Kind: kind, Path: dockerfilePath}
 	if err = validateDockerfileFor(&dInfo, kind, logger); err != nil {
-		return DockerfileInfo{}, true, fmt.Errorf("failed to parse %s.Dockerfile for extension %s: %w", kind, d.Extension.ID, err)
+		return DockerfileInfo{}, true, wrapError(fmt.Errorf("failed to parse %s.Dockerfile for extension %s: %w", kind, d.Extension.ID, err))
 	}
 	return dInfo, true, nil
 }
@@ -158,7 +401,9 @@ func findDockerfileFor(d ExtDescriptor, extOutputDir string, kind string, logger
 func validateDockerfileFor(dInfo *DockerfileInfo, kind string, logger log.Logger) error {
 	switch kind {
 	case DockerfileKindBuild:
-		return ValidateBuildDockerfile(dInfo.Path, logger)
+		var err error
+		dInfo.DeclaredBase, err = validateBuildDockerfile(dInfo.Path, logger)
+		return err
 	case DockerfileKindRun:
 		return ValidateRunDockerfile(dInfo, logger)
 	default:
@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/memory"
@@ -205,6 +206,19 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 					h.AssertEq(t, isUnset(actual), true)
 				})
 
+				it("sets CNB_EXTENSION_DIR to an absolute path when the root directory is relative", func() {
+					absRootDir := descriptor.WithRootDir
+					wd, err := os.Getwd()
+					h.AssertNil(t, err)
+					descriptor.WithRootDir, err = filepath.Rel(wd, absRootDir)
+					h.AssertNil(t, err)
+
+					_, err = executor.Generate(descriptor, inputs, logger)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, h.Rdfile(t, filepath.Join(appDir, "build-env-cnb-extension-dir-A-v1")), absRootDir)
+				})
+
 				it("loads env vars from <platform>/env", func() {
 					h.Mkfile(t, "some-data",
 						filepath.Join(platformDir, "env", "SOME_VAR"),
@@ -220,11 +234,15 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 
 			it("errors when <platform>/env cannot be loaded", func() {
 				mockEnv.EXPECT().WithOverrides(platformDir, buildConfigDir).Return(nil, errors.New("some error"))
-				if _, err := executor.Generate(descriptor, inputs, logger); err == nil {
+				_, err := executor.Generate(descriptor, inputs, logger)
+				if err == nil {
 					t.Fatal("Expected error.\n")
 				} else if !strings.Contains(err.Error(), "some error") {
 					t.Fatalf("Incorrect error: %s\n", err)
 				}
+				if err, ok := err.(*buildpack.Error); !ok || err.Type != buildpack.ErrTypeIO {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
 			})
 
 			when("any", func() {
@@ -269,6 +287,136 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 					}
 				})
 
+				it("records the exit code when the command fails", func() {
+					h.Mkfile(t, "3", filepath.Join(appDir, "build-status-A-v1"))
+					_, err := executor.Generate(descriptor, inputs, logger)
+					bpErr, ok := err.(*buildpack.Error)
+					if !ok || bpErr.Type != buildpack.ErrTypeBuildpack {
+						t.Fatalf("Incorrect error: %s\n", err)
+					}
+					h.AssertEq(t, bpErr.ExitCode, 3)
+				})
+
+				when("the lifecycle cannot read or write a file", func() {
+					it("errors with an IO error when the output directory cannot be created", func() {
+						h.Mkfile(t, "some-file", filepath.Join(tmpDir, "some-file"))
+						inputs.OutputDir = filepath.Join(tmpDir, "some-file")
+
+						_, err := executor.Generate(descriptor, inputs, logger)
+						bpErr, ok := err.(*buildpack.Error)
+						if !ok || bpErr.Type != buildpack.ErrTypeIO {
+							t.Fatalf("Incorrect error: %s\n", err)
+						}
+					})
+
+					it("errors with an IO error when build.toml cannot be read", func() {
+						h.Mkdir(t, filepath.Join(outputDir, "A", "build.toml"))
+
+						_, err := executor.Generate(descriptor, inputs, logger)
+						bpErr, ok := err.(*buildpack.Error)
+						if !ok || bpErr.Type != buildpack.ErrTypeIO {
+							t.Fatalf("Incorrect error: %s\n", err)
+						}
+					})
+
+					it("errors with an IO error when extend-config.toml cannot be read", func() {
+						h.Mkdir(t, filepath.Join(outputDir, "A", "extend-config.toml"))
+
+						_, err := executor.Generate(descriptor, inputs, logger)
+						bpErr, ok := err.(*buildpack.Error)
+						if !ok || bpErr.Type != buildpack.ErrTypeIO {
+							t.Fatalf("Incorrect error: %s\n", err)
+						}
+					})
+
+					it("errors with an IO error when a Dockerfile cannot be read", func() {
+						h.Mkdir(t, filepath.Join(outputDir, "A", "run.Dockerfile"))
+
+						_, err := executor.Generate(descriptor, inputs, logger)
+						bpErr, ok := err.(*buildpack.Error)
+						if !ok || bpErr.Type != buildpack.ErrTypeIO {
+							t.Fatalf("Incorrect error: %s\n", err)
+						}
+					})
+				})
+
+				when("the extension writes invalid output", func() {
+					it("errors with a buildpack error when build.toml is invalid", func() {
+						h.Mkfile(t, "bad-key", filepath.Join(appDir, "build-A-v1.toml"))
+
+						_, err := executor.Generate(descriptor, inputs, logger)
+						bpErr, ok := err.(*buildpack.Error)
+						if !ok || bpErr.Type != buildpack.ErrTypeBuildpack {
+							t.Fatalf("Incorrect error: %s\n", err)
+						}
+					})
+
+					it("errors with a buildpack error when extend-config.toml is invalid", func() {
+						h.Mkfile(t,
+							"[[build.args]]\n"+
+								`name = "build_id"`+"\n",
+							filepath.Join(appDir, "extend-config-A-v1.toml"),
+						)
+
+						_, err := executor.Generate(descriptor, inputs, logger)
+						h.AssertError(t, err, "validating extend config: invalid content")
+						bpErr, ok := err.(*buildpack.Error)
+						if !ok || bpErr.Type != buildpack.ErrTypeBuildpack {
+							t.Fatalf("Incorrect error: %s\n", err)
+						}
+					})
+				})
+
+				it("records the duration of the command", func() {
+					br, err := executor.Generate(descriptor, inputs, logger)
+					h.AssertNil(t, err)
+					if br.Duration <= 0 {
+						t.Fatalf("Expected a positive duration, got %s", br.Duration)
+					}
+				})
+
+				it("measures the duration using the provided clock", func() {
+					inputs.Clock = &fakeClock{step: 5 * time.Second}
+
+					br, err := executor.Generate(descriptor, inputs, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, br.Duration, 5*time.Second)
+				})
+
+				when("the output directory contains stale files", func() {
+					it.Before(func() {
+						h.Mkdir(t, filepath.Join(outputDir, "A"))
+						h.Mkfile(t,
+							"ARG base_image\n"+
+								"FROM ${base_image}",
+							filepath.Join(outputDir, "A", "build.Dockerfile"),
+						)
+					})
+
+					it("includes them by default", func() {
+						br, err := executor.Generate(descriptor, inputs, logger)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, len(br.Dockerfiles), 1)
+						h.AssertEq(t, br.Dockerfiles[0].Kind, buildpack.DockerfileKindBuild)
+					})
+
+					when("clean output is requested", func() {
+						it.Before(func() {
+							inputs.CleanOutput = true
+						})
+
+						it("removes them before running the command", func() {
+							br, err := executor.Generate(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.Dockerfiles), 0)
+							h.AssertPathDoesNotExist(t, filepath.Join(outputDir, "A", "build.Dockerfile"))
+							assertLogEntry(t, logHandler, "Removing stale output '"+filepath.Join(outputDir, "A", "build.Dockerfile")+"'")
+						})
+					})
+				})
+
 				when("build result", func() {
 					when("dockerfiles", func() {
 						when("run.Dockerfile", func() {
@@ -288,6 +436,22 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 								h.AssertEq(t, br.Dockerfiles[0].WithBase, "")
 							})
 
+							it("errors when it is written to a nested generate directory", func() {
+								h.Mkdir(t, filepath.Join(outputDir, "A", "generate"))
+								h.Mkfile(t,
+									"FROM some-new-base-image",
+									filepath.Join(outputDir, "A", "generate", "run.Dockerfile"),
+								)
+
+								_, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertError(t, err, "found run.Dockerfile for extension A at unexpected location '"+
+									filepath.Join(outputDir, "A", "generate", "run.Dockerfile")+"'; it should be written to '"+
+									filepath.Join(outputDir, "A", "run.Dockerfile")+"'")
+								var bpErr *buildpack.Error
+								h.AssertEq(t, errors.As(err, &bpErr), true)
+								h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
+							})
+
 							it("is validated", func() {
 								h.Mkfile(t,
 									"SOME-INVALID-CONTENT",
@@ -295,6 +459,9 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 								)
 								_, err := executor.Generate(descriptor, inputs, logger)
 								h.AssertError(t, err, "failed to parse run.Dockerfile for extension A: dockerfile parse error on line 1: unknown instruction: SOME-INVALID-CONTENT")
+								var bpErr *buildpack.Error
+								h.AssertEq(t, errors.As(err, &bpErr), true)
+								h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
 							})
 
 							when("switching the runtime base image", func() {
@@ -313,6 +480,47 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 									h.AssertEq(t, br.Dockerfiles[0].WithBase, "some-new-base-image")
 								})
 							})
+
+							when("strict Dockerfiles are requested", func() {
+								it.Before(func() {
+									inputs.StrictDockerfiles = true
+								})
+
+								it("errors when the base image is hard-coded", func() {
+									h.Mkfile(t,
+										"FROM some-new-base-image",
+										filepath.Join(appDir, "run.Dockerfile-A-v1"),
+									)
+
+									_, err := executor.Generate(descriptor, inputs, logger)
+									h.AssertError(t, err, "run.Dockerfile for extension A hard-codes base image 'some-new-base-image'; the FROM instruction must use ${base_image}")
+									var bpErr *buildpack.Error
+									h.AssertEq(t, errors.As(err, &bpErr), true)
+									h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
+								})
+
+								it("allows the base image to be set by the base_image build argument", func() {
+									h.Mkfile(t,
+										"ARG base_image\n"+
+											"FROM ${base_image}",
+										filepath.Join(appDir, "run.Dockerfile-A-v1"),
+									)
+
+									_, err := executor.Generate(descriptor, inputs, logger)
+									h.AssertNil(t, err)
+								})
+
+								it("does not check build.Dockerfile", func() {
+									h.Mkfile(t,
+										"ARG base_image=some-build-base\n"+
+											"FROM ${base_image}",
+										filepath.Join(appDir, "build.Dockerfile-A-v1"),
+									)
+
+									_, err := executor.Generate(descriptor, inputs, logger)
+									h.AssertNil(t, err)
+								})
+							})
 						})
 
 						when("build.Dockerfile", func() {
@@ -339,6 +547,118 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 
 								_, err := executor.Generate(descriptor, inputs, logger)
 								h.AssertError(t, err, "failed to parse build.Dockerfile for extension A: dockerfile parse error on line 1: unknown instruction: SOME-INVALID-CONTENT")
+								var bpErr *buildpack.Error
+								h.AssertEq(t, errors.As(err, &bpErr), true)
+								h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
+							})
+						})
+
+						when("loading contents", func() {
+							it.Before(func() {
+								h.Mkfile(t,
+									"ARG base_image\n"+
+										"FROM ${base_image}",
+									filepath.Join(appDir, "build.Dockerfile-A-v1"),
+								)
+							})
+
+							it("does not load contents by default", func() {
+								br, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, len(br.Dockerfiles[0].Contents), 0)
+							})
+
+							when("requested", func() {
+								it.Before(func() {
+									inputs.LoadDockerfileContents = true
+								})
+
+								it("includes the contents", func() {
+									br, err := executor.Generate(descriptor, inputs, logger)
+									h.AssertNil(t, err)
+
+									h.AssertEq(t, string(br.Dockerfiles[0].Contents), "ARG base_image\nFROM ${base_image}")
+								})
+
+								it("errors when the Dockerfile is too large", func() {
+									h.Mkfile(t,
+										"ARG base_image\n"+
+											"FROM ${base_image}\n"+
+											strings.Repeat("# some-comment\n", buildpack.MaxDockerfileContentsSize/15),
+										filepath.Join(appDir, "build.Dockerfile-A-v1"),
+									)
+
+									_, err := executor.Generate(descriptor, inputs, logger)
+									h.AssertError(t, err, "failed to read build.Dockerfile for extension A: file size")
+									var bpErr *buildpack.Error
+									h.AssertEq(t, errors.As(err, &bpErr), true)
+									h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
+								})
+							})
+						})
+
+						when("both run.Dockerfile and build.Dockerfile declare a base image", func() {
+							it.Before(func() {
+								h.Mkfile(t,
+									"ARG base_image=some-build-base\n"+
+										"FROM ${base_image}",
+									filepath.Join(appDir, "build.Dockerfile-A-v1"),
+								)
+							})
+
+							it("includes the declared bases", func() {
+								h.Mkfile(t,
+									"FROM some-run-base",
+									filepath.Join(appDir, "run.Dockerfile-A-v1"),
+								)
+
+								br, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.Dockerfiles[0].DeclaredBase, "some-build-base")
+								h.AssertEq(t, br.Dockerfiles[1].DeclaredBase, "some-run-base")
+							})
+
+							it("returns the build.Dockerfile before the run.Dockerfile", func() {
+								h.Mkfile(t,
+									"FROM some-run-base",
+									filepath.Join(appDir, "run.Dockerfile-A-v1"),
+								)
+
+								br, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, len(br.Dockerfiles), 2)
+								h.AssertEq(t, br.Dockerfiles[0].Kind, buildpack.DockerfileKindBuild)
+								h.AssertEq(t, br.Dockerfiles[0].Path, filepath.Join(outputDir, "A", "build.Dockerfile"))
+								h.AssertEq(t, br.Dockerfiles[1].Kind, buildpack.DockerfileKindRun)
+								h.AssertEq(t, br.Dockerfiles[1].Path, filepath.Join(outputDir, "A", "run.Dockerfile"))
+							})
+
+							it("warns when the bases conflict", func() {
+								h.Mkfile(t,
+									"FROM some-run-base",
+									filepath.Join(appDir, "run.Dockerfile-A-v1"),
+								)
+
+								_, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								assertLogEntry(t, logHandler, "run.Dockerfile and build.Dockerfile for extension A declare conflicting base images: 'some-run-base' and 'some-build-base'")
+							})
+
+							it("does not warn when the bases match", func() {
+								h.Mkfile(t,
+									"ARG base_image=some-build-base\n"+
+										"FROM ${base_image}",
+									filepath.Join(appDir, "run.Dockerfile-A-v1"),
+								)
+
+								_, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								assertLogEntryNotContains(t, logHandler, "conflicting base images")
 							})
 						})
 					})
@@ -363,6 +683,100 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 
 							h.AssertEq(t, br.MetRequires, []string{"some-dep", "some-other-dep"})
 						})
+
+						when("build.toml is written to the output directory", func() {
+							it.Before(func() {
+								inputs.Plan = buildpack.Plan{
+									Entries: []buildpack.Require{
+										{Name: "some-dep"},
+										{Name: "some-other-dep"},
+										{Name: "some-unmet-dep"},
+									},
+								}
+							})
+
+							it("excludes unmet entries and treats the remaining plan entries as met", func() {
+								h.Mkfile(t,
+									"[[unmet]]\n"+
+										`name = "some-unmet-dep"`+"\n",
+									filepath.Join(appDir, "build-A-v1.toml"),
+								)
+
+								br, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.MetRequires, []string{"some-dep", "some-other-dep"})
+							})
+
+							it("includes provided entries that are not in the plan", func() {
+								h.Mkfile(t,
+									"[[unmet]]\n"+
+										`name = "some-unmet-dep"`+"\n"+
+										"[[provides]]\n"+
+										`name = "some-provided-dep"`+"\n"+
+										"[[provides]]\n"+
+										`name = "some-dep"`+"\n"+
+										"[[provides]]\n"+
+										`name = "some-unmet-dep"`+"\n",
+									filepath.Join(appDir, "build-A-v1.toml"),
+								)
+
+								br, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.MetRequires, []string{"some-dep", "some-other-dep", "some-provided-dep"})
+							})
+
+							it("errors when an unmet entry has no name", func() {
+								h.Mkfile(t,
+									"[[unmet]]\n",
+									filepath.Join(appDir, "build-A-v1.toml"),
+								)
+
+								_, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertError(t, err, "invalid build.toml for extension A: unmet.name is required")
+								var bpErr *buildpack.Error
+								h.AssertEq(t, errors.As(err, &bpErr), true)
+								h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
+							})
+
+							it("errors when an unmet entry does not match a requested dependency", func() {
+								h.Mkfile(t,
+									"[[unmet]]\n"+
+										`name = "some-unknown-dep"`+"\n",
+									filepath.Join(appDir, "build-A-v1.toml"),
+								)
+
+								_, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertError(t, err, "invalid build.toml for extension A: unmet.name 'some-unknown-dep' must match a requested dependency")
+								var bpErr *buildpack.Error
+								h.AssertEq(t, errors.As(err, &bpErr), true)
+								h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
+							})
+						})
+
+						when("labels", func() {
+							it("includes labels from build.toml", func() {
+								h.Mkfile(t,
+									"[[labels]]\n"+
+										`key = "some-key"`+"\n"+
+										`value = "some-value"`+"\n",
+									filepath.Join(appDir, "build-A-v1.toml"),
+								)
+
+								br, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, br.Labels, []buildpack.Label{{Key: "some-key", Value: "some-value"}})
+							})
+
+							it("returns no labels when none are provided", func() {
+								br, err := executor.Generate(descriptor, inputs, logger)
+								h.AssertNil(t, err)
+
+								h.AssertEq(t, len(br.Labels), 0)
+							})
+						})
 					})
 
 					when("/bin/build is missing", func() {
@@ -387,11 +801,177 @@ func testGenerate(t *testing.T, when spec.G, it spec.S) {
 							t.Log("processes run.Dockerfile")
 							h.AssertEq(t, br.Dockerfiles[0].ExtensionID, "B")
 							h.AssertEq(t, br.Dockerfiles[0].Kind, buildpack.DockerfileKindRun)
-							h.AssertEq(t, br.Dockerfiles[0].Path, filepath.Join(descriptor.WithRootDir, "generate", "run.Dockerfile"))
+							h.AssertEq(t, br.Dockerfiles[0].Path, filepath.Join(outputDir, "B", "run.Dockerfile"))
+							t.Log("does not record a duration")
+							h.AssertEq(t, br.Duration, time.Duration(0))
+						})
+
+						it("copies the pre-populated outputs to the output directory", func() {
+							br, err := executor.Generate(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+
+							h.AssertEq(t, len(br.Dockerfiles), 1)
+							h.AssertStringContains(t, br.Dockerfiles[0].Path, outputDir+string(filepath.Separator))
+							h.AssertEq(t,
+								h.Rdfile(t, br.Dockerfiles[0].Path),
+								h.Rdfile(t, filepath.Join(descriptor.WithRootDir, "generate", "run.Dockerfile")),
+							)
+						})
+
+						it("succeeds when there are no pre-populated outputs", func() {
+							descriptor.WithRootDir = filepath.Join(tmpDir, "extension-root")
+							h.Mkdir(t, descriptor.WithRootDir)
+
+							br, err := executor.Generate(descriptor, inputs, logger)
+							h.AssertNil(t, err)
+							h.AssertEq(t, len(br.Dockerfiles), 0)
+						})
+
+						it("errors when a Dockerfile is at the extension root instead of the generate directory", func() {
+							descriptor.WithRootDir = filepath.Join(tmpDir, "extension-root")
+							h.Mkdir(t, descriptor.WithRootDir)
+							h.Mkfile(t,
+								"FROM some-run-image",
+								filepath.Join(descriptor.WithRootDir, "run.Dockerfile"),
+							)
+
+							_, err := executor.Generate(descriptor, inputs, logger)
+							h.AssertError(t, err, "found run.Dockerfile for extension B at unexpected location '"+
+								filepath.Join(descriptor.WithRootDir, "run.Dockerfile")+"'; it should be written to '"+
+								filepath.Join(descriptor.WithRootDir, "generate", "run.Dockerfile")+"'")
+							var bpErr *buildpack.Error
+							h.AssertEq(t, errors.As(err, &bpErr), true)
+							h.AssertEq(t, bpErr.Type, buildpack.ErrTypeBuildpack)
 						})
 					})
 				})
 			})
 		})
 	})
+
+	when(".GenerateGroup", func() {
+		var (
+			mockCtrl     *gomock.Controller
+			mockExecutor *testmock.MockGenerateExecutor
+			extA, extB   buildpack.ExtDescriptor
+			inputs       buildpack.GenerateInputs
+			logger       llog.Logger
+			logHandler   *memory.Handler
+		)
+
+		it.Before(func() {
+			mockCtrl = gomock.NewController(t)
+			mockExecutor = testmock.NewMockGenerateExecutor(mockCtrl)
+			extA = buildpack.ExtDescriptor{Extension: buildpack.ExtInfo{BaseInfo: buildpack.BaseInfo{ID: "A", Version: "v1"}}}
+			extB = buildpack.ExtDescriptor{Extension: buildpack.ExtInfo{BaseInfo: buildpack.BaseInfo{ID: "B", Version: "v1"}}}
+			inputs = buildpack.GenerateInputs{
+				AppDir: "some-app-dir",
+				Plan: buildpack.Plan{Entries: []buildpack.Require{
+					{Name: "some-dep"},
+					{Name: "some-other-dep"},
+				}},
+			}
+			logHandler = memory.New()
+			logger = &log.Logger{Handler: logHandler}
+		})
+
+		it.After(func() {
+			mockCtrl.Finish()
+		})
+
+		it("runs each extension in order and aggregates the outputs", func() {
+			expectedInputsA := inputs
+			mockExecutor.EXPECT().Generate(extA, expectedInputsA, logger).Return(buildpack.GenerateOutputs{
+				Dockerfiles: []buildpack.DockerfileInfo{{ExtensionID: "A", Kind: buildpack.DockerfileKindBuild, Path: "some-build.Dockerfile"}},
+				MetRequires: []string{"some-dep"},
+			}, nil)
+			expectedInputsB := inputs
+			expectedInputsB.Plan = buildpack.Plan{Entries: []buildpack.Require{{Name: "some-other-dep"}}}
+			mockExecutor.EXPECT().Generate(extB, expectedInputsB, logger).Return(buildpack.GenerateOutputs{
+				Dockerfiles: []buildpack.DockerfileInfo{{ExtensionID: "B", Kind: buildpack.DockerfileKindRun, Path: "some-run.Dockerfile", WithBase: "some-run-image"}},
+				MetRequires: []string{"some-other-dep", "some-dep"},
+			}, nil)
+
+			outputs, err := buildpack.GenerateGroup(mockExecutor, []buildpack.ExtDescriptor{extA, extB}, inputs, logger)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, outputs.Dockerfiles, []buildpack.DockerfileInfo{
+				{ExtensionID: "A", Kind: buildpack.DockerfileKindBuild, Path: "some-build.Dockerfile"},
+				{ExtensionID: "B", Kind: buildpack.DockerfileKindRun, Path: "some-run.Dockerfile", WithBase: "some-run-image"},
+			})
+			h.AssertEq(t, outputs.MetRequires, []string{"some-dep", "some-other-dep"})
+			h.AssertEq(t, outputs.RunImage, "some-run-image")
+		})
+
+		it("warns when a later extension switches the run image again", func() {
+			mockExecutor.EXPECT().Generate(extA, gomock.Any(), logger).Return(buildpack.GenerateOutputs{
+				Dockerfiles: []buildpack.DockerfileInfo{{ExtensionID: "A", Kind: buildpack.DockerfileKindRun, WithBase: "some-run-image"}},
+			}, nil)
+			mockExecutor.EXPECT().Generate(extB, gomock.Any(), logger).Return(buildpack.GenerateOutputs{
+				Dockerfiles: []buildpack.DockerfileInfo{{ExtensionID: "B", Kind: buildpack.DockerfileKindRun, WithBase: "some-other-run-image"}},
+			}, nil)
+
+			outputs, err := buildpack.GenerateGroup(mockExecutor, []buildpack.ExtDescriptor{extA, extB}, inputs, logger)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, outputs.RunImage, "some-other-run-image")
+			assertLogEntry(t, logHandler, "Extension B switches the run image to 'some-other-run-image', overriding 'some-run-image' from extension A")
+		})
+
+		it("warns when extensions provide the same label", func() {
+			mockExecutor.EXPECT().Generate(extA, gomock.Any(), logger).Return(buildpack.GenerateOutputs{
+				Labels: []buildpack.Label{{Key: "some-key", Value: "some-value"}, {Key: "some-other-key", Value: "some-other-value"}},
+			}, nil)
+			mockExecutor.EXPECT().Generate(extB, gomock.Any(), logger).Return(buildpack.GenerateOutputs{
+				Labels: []buildpack.Label{{Key: "some-key", Value: "some-new-value"}},
+			}, nil)
+
+			outputs, err := buildpack.GenerateGroup(mockExecutor, []buildpack.ExtDescriptor{extA, extB}, inputs, logger)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, outputs.Labels, []buildpack.Label{
+				{Key: "some-other-key", Value: "some-other-value"},
+				{Key: "some-key", Value: "some-new-value"},
+			})
+			assertLogEntry(t, logHandler, "Extension B provides label 'some-key', overriding the value from extension A")
+		})
+
+		it("errors when an extension fails", func() {
+			mockExecutor.EXPECT().Generate(extA, gomock.Any(), logger).Return(buildpack.GenerateOutputs{}, errors.New("some-error"))
+
+			_, err := buildpack.GenerateGroup(mockExecutor, []buildpack.ExtDescriptor{extA, extB}, inputs, logger)
+			h.AssertError(t, err, "some-error")
+		})
+	})
+	when("GenerateOutputs#Clone", func() {
+		it("returns a copy that does not share slices with the original", func() {
+			original := buildpack.GenerateOutputs{
+				Dockerfiles: []buildpack.DockerfileInfo{{ExtensionID: "A", Kind: buildpack.DockerfileKindRun, Contents: []byte("FROM some-image")}},
+				Labels:      []buildpack.Label{{Key: "some-key", Value: "some-value"}},
+				MetRequires: []string{"some-dep"},
+				Duration:    time.Second,
+			}
+
+			clone := original.Clone()
+			h.AssertEq(t, clone, original)
+
+			clone.Dockerfiles[0].ExtensionID = "B"
+			clone.Dockerfiles[0].Contents[0] = 'X'
+			clone.Labels[0].Value = "some-other-value"
+			clone.MetRequires[0] = "some-other-dep"
+			clone.MetRequires = append(clone.MetRequires, "another-dep")
+
+			h.AssertEq(t, original, buildpack.GenerateOutputs{
+				Dockerfiles: []buildpack.DockerfileInfo{{ExtensionID: "A", Kind: buildpack.DockerfileKindRun, Contents: []byte("FROM some-image")}},
+				Labels:      []buildpack.Label{{Key: "some-key", Value: "some-value"}},
+				MetRequires: []string{"some-dep"},
+				Duration:    time.Second,
+			})
+		})
+
+		it("keeps nil slices nil", func() {
+			clone := buildpack.GenerateOutputs{}.Clone()
+			h.AssertEq(t, clone, buildpack.GenerateOutputs{})
+		})
+	})
 }
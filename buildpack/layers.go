@@ -11,7 +11,6 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/lifecycle/api"
-	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/log"
 )
 
@@ -24,7 +23,7 @@ type LayersDir struct {
 }
 
 func ReadLayersDir(layersDir string, bp GroupElement, logger log.Logger) (LayersDir, error) {
-	path := filepath.Join(layersDir, launch.EscapeID(bp.ID))
+	path := LayersDirFor(layersDir, bp.ID)
 	logger.Debugf("Reading buildpack directory: %s", path)
 	bpDir := LayersDir{
 		name:      bp.ID,
@@ -0,0 +1,42 @@
+package buildpack
+
+import "fmt"
+
+// DefaultCgroupParent is the cgroup v2 directory under which transient cgroups for buildpack processes are created
+// when ResourceLimits.CgroupParent is not provided.
+const DefaultCgroupParent = "/sys/fs/cgroup"
+
+// ResourceLimits constrain the resources available to a buildpack's bin/build process.
+// On Linux, a non-zero ResourceLimits causes the process to be started in a transient cgroup (v2) with the given limits,
+// which is removed once the process exits. On other platforms, ResourceLimits are ignored.
+type ResourceLimits struct {
+	// MemoryBytes is the maximum amount of memory the process may use; zero means no limit.
+	MemoryBytes int64
+	// CPUQuota is the number of CPUs worth of time the process may use (for example, 1.5); zero means no limit.
+	CPUQuota float64
+	// CgroupParent is the cgroup v2 directory under which the transient cgroup is created; it defaults to DefaultCgroupParent.
+	// The memory and cpu controllers must be enabled in its cgroup.subtree_control.
+	CgroupParent string
+}
+
+// IsZero returns true if no limits are set.
+func (l ResourceLimits) IsZero() bool {
+	return l.MemoryBytes == 0 && l.CPUQuota == 0
+}
+
+func (l ResourceLimits) validate() error {
+	if l.MemoryBytes < 0 {
+		return fmt.Errorf("memory limit must not be negative, got %d", l.MemoryBytes)
+	}
+	if l.CPUQuota < 0 {
+		return fmt.Errorf("CPU quota must not be negative, got %g", l.CPUQuota)
+	}
+	return nil
+}
+
+func (l ResourceLimits) cgroupParent() string {
+	if l.CgroupParent == "" {
+		return DefaultCgroupParent
+	}
+	return l.CgroupParent
+}
@@ -0,0 +1,74 @@
+//go:build linux
+// +build linux
+
+package buildpack
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"github.com/buildpacks/lifecycle/launch"
+)
+
+// cpuPeriod is the period, in microseconds, written to cpu.max along with the quota.
+const cpuPeriod = 100000
+
+// applyResourceLimits creates a transient cgroup with the given limits and configures cmd to be started in it.
+// The returned cleanup function must be called once cmd has exited; it removes the cgroup on a best-effort basis,
+// as the cgroup cannot be removed while processes left running by the buildpack remain in it.
+func applyResourceLimits(cmd *exec.Cmd, bpID string, limits ResourceLimits) (func(), error) {
+	if limits.IsZero() {
+		return func() {}, nil
+	}
+	if err := limits.validate(); err != nil {
+		return nil, err
+	}
+	cgroupDir, err := os.MkdirTemp(limits.cgroupParent(), "cnb-"+launch.EscapeID(bpID)+"-")
+	if err != nil {
+		return nil, fmt.Errorf("creating cgroup: %w", err)
+	}
+	removeCgroup := func() { _ = os.Remove(cgroupDir) }
+
+	if limits.MemoryBytes > 0 {
+		if err = writeCgroupFile(cgroupDir, "memory.max", strconv.FormatInt(limits.MemoryBytes, 10)); err != nil {
+			removeCgroup()
+			return nil, err
+		}
+	}
+	if limits.CPUQuota > 0 {
+		quota := int64(limits.CPUQuota * cpuPeriod)
+		if quota < 1 {
+			quota = 1
+		}
+		if err = writeCgroupFile(cgroupDir, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+			removeCgroup()
+			return nil, err
+		}
+	}
+
+	cgroup, err := os.Open(cgroupDir)
+	if err != nil {
+		removeCgroup()
+		return nil, fmt.Errorf("opening cgroup: %w", err)
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cgroup.Fd())
+	return func() {
+		_ = cgroup.Close()
+		removeCgroup()
+	}, nil
+}
+
+func writeCgroupFile(cgroupDir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(cgroupDir, name), []byte(value), 0600); err != nil {
+		return fmt.Errorf("setting %s for cgroup: %w", name, err)
+	}
+	return nil
+}
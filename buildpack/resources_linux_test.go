@@ -0,0 +1,99 @@
+//go:build linux
+// +build linux
+
+package buildpack_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/buildpack"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestResourceLimits(t *testing.T) {
+	spec.Run(t, "ResourceLimits", testResourceLimits, spec.Report(report.Terminal{}))
+}
+
+func testResourceLimits(t *testing.T, when spec.G, it spec.S) {
+	when("#applyResourceLimits", func() {
+		var (
+			cgroupParent string
+			cmd          *exec.Cmd
+		)
+
+		it.Before(func() {
+			cgroupParent = t.TempDir()
+			cmd = exec.Command("true")
+		})
+
+		cgroupDirs := func() []string {
+			matches, err := filepath.Glob(filepath.Join(cgroupParent, "cnb-some_buildpack-*"))
+			h.AssertNil(t, err)
+			return matches
+		}
+
+		it("does nothing when no limits are set", func() {
+			cleanup, err := buildpack.ApplyResourceLimits(cmd, "some/buildpack", buildpack.ResourceLimits{CgroupParent: cgroupParent})
+			h.AssertNil(t, err)
+			cleanup()
+
+			h.AssertEq(t, len(cgroupDirs()), 0)
+			if cmd.SysProcAttr != nil {
+				t.Fatalf("Expected SysProcAttr to be unset")
+			}
+		})
+
+		it("creates a cgroup with the limits and starts the command in it", func() {
+			cleanup, err := buildpack.ApplyResourceLimits(cmd, "some/buildpack", buildpack.ResourceLimits{
+				MemoryBytes:  512 * 1024 * 1024,
+				CPUQuota:     1.5,
+				CgroupParent: cgroupParent,
+			})
+			h.AssertNil(t, err)
+
+			dirs := cgroupDirs()
+			h.AssertEq(t, len(dirs), 1)
+			memoryMax, err := os.ReadFile(filepath.Join(dirs[0], "memory.max"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(memoryMax), "536870912")
+			cpuMax, err := os.ReadFile(filepath.Join(dirs[0], "cpu.max"))
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(cpuMax), "150000 100000")
+			h.AssertEq(t, cmd.SysProcAttr.UseCgroupFD, true)
+
+			// the fake cgroup directory contains the limit files, so clean them up as the kernel would
+			h.AssertNil(t, os.Remove(filepath.Join(dirs[0], "memory.max")))
+			h.AssertNil(t, os.Remove(filepath.Join(dirs[0], "cpu.max")))
+			cleanup()
+			h.AssertEq(t, len(cgroupDirs()), 0)
+		})
+
+		it("only sets the limits that are provided", func() {
+			cleanup, err := buildpack.ApplyResourceLimits(cmd, "some/buildpack", buildpack.ResourceLimits{MemoryBytes: 1024, CgroupParent: cgroupParent})
+			h.AssertNil(t, err)
+			defer cleanup()
+
+			dirs := cgroupDirs()
+			h.AssertEq(t, len(dirs), 1)
+			h.AssertPathExists(t, filepath.Join(dirs[0], "memory.max"))
+			h.AssertPathDoesNotExist(t, filepath.Join(dirs[0], "cpu.max"))
+		})
+
+		it("errors for negative limits", func() {
+			_, err := buildpack.ApplyResourceLimits(cmd, "some/buildpack", buildpack.ResourceLimits{MemoryBytes: -1, CgroupParent: cgroupParent})
+			h.AssertError(t, err, "memory limit must not be negative, got -1")
+			h.AssertEq(t, len(cgroupDirs()), 0)
+		})
+
+		it("errors when the cgroup cannot be created", func() {
+			_, err := buildpack.ApplyResourceLimits(cmd, "some/buildpack", buildpack.ResourceLimits{MemoryBytes: 1024, CgroupParent: filepath.Join(cgroupParent, "missing")})
+			h.AssertError(t, err, "creating cgroup")
+		})
+	})
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package buildpack
+
+import "os/exec"
+
+// applyResourceLimits is a no-op on platforms without cgroups.
+func applyResourceLimits(_ *exec.Cmd, _ string, _ ResourceLimits) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,58 @@
+package buildpack
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// snapshotDirs returns the set of directories under root, excluding root itself and anything under the allowed directories.
+func snapshotDirs(root string, allowed []string) (map[string]struct{}, error) {
+	dirs := map[string]struct{}{}
+	err := walkOtherDirs(root, allowed, func(path string) bool {
+		dirs[path] = struct{}{}
+		return true
+	})
+	return dirs, err
+}
+
+// strayDirs returns the directories under root, other than those under the allowed directories, that are not in before.
+// Only the topmost new directory of each new tree is reported. Paths are relative to root and sorted.
+func strayDirs(root string, allowed []string, before map[string]struct{}) ([]string, error) {
+	var stray []string
+	err := walkOtherDirs(root, allowed, func(path string) bool {
+		if _, ok := before[path]; ok {
+			return true
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		stray = append(stray, rel)
+		return false
+	})
+	sort.Strings(stray)
+	return stray, err
+}
+
+// walkOtherDirs calls visit for each directory under root, skipping root itself and the allowed directories;
+// if visit returns false, the directory's contents are not walked.
+func walkOtherDirs(root string, allowed []string, visit func(path string) bool) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() || path == root {
+			return nil
+		}
+		for _, dir := range allowed {
+			if path == dir {
+				return filepath.SkipDir
+			}
+		}
+		if !visit(path) {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
@@ -77,6 +77,18 @@ func (mr *MockBuildEnvMockRecorder) List() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockBuildEnv)(nil).List))
 }
 
+// Remove mocks base method.
+func (m *MockBuildEnv) Remove(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Remove", arg0)
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockBuildEnvMockRecorder) Remove(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockBuildEnv)(nil).Remove), arg0)
+}
+
 // WithOverrides mocks base method.
 func (m *MockBuildEnv) WithOverrides(arg0, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -54,6 +54,7 @@ func (a *analyzeCmd) DefineFlags() {
 		cli.FlagAnalyzedPath(&a.AnalyzedPath)
 		cli.FlagCacheImage(&a.CacheImageRef)
 		cli.FlagGID(&a.GID)
+		cli.FlagInsecureRegistriesFile(&a.InsecureRegistriesFile)
 		cli.FlagLayersDir(&a.LayersDir)
 		cli.FlagUID(&a.UID)
 		cli.FlagUseDaemon(&a.UseDaemon)
@@ -107,8 +108,8 @@ func (a *analyzeCmd) Exec() error {
 		&cmd.BuildpackAPIVerifier{},
 		NewCacheHandler(a.keychain),
 		lifecycle.NewConfigHandler(),
-		image.NewHandler(a.docker, a.keychain, a.LayoutDir, a.UseLayout),
-		NewRegistryHandler(a.keychain),
+		image.NewHandler(a.docker, a.keychain, a.LayoutDir, a.UseLayout, image.WithInsecureRegistries(a.InsecureRegistries...)),
+		image.NewRegistryHandler(a.keychain, a.InsecureRegistries, image.WithLogger(cmd.DefaultLogger)),
 	)
 	analyzer, err := factory.NewAnalyzer(
 		a.AdditionalTags,
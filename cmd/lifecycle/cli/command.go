@@ -24,6 +24,12 @@ type Command interface {
 	Exec() error
 }
 
+// stdoutWriter is implemented by commands that may write their output to stdout;
+// when WritesToStdout returns true, log messages are written to stderr so that stdout contains only the output.
+type stdoutWriter interface {
+	WritesToStdout() bool
+}
+
 func Run(c Command, withPhaseName string, asSubcommand bool) {
 	var (
 		printVersion bool
@@ -48,6 +54,9 @@ func Run(c Command, withPhaseName string, asSubcommand bool) {
 		}
 	}
 	cmd.DisableColor(noColor)
+	if w, ok := c.(stdoutWriter); ok && w.WritesToStdout() {
+		cmd.DefaultLogger.SetWriter(cmd.Stderr)
+	}
 
 	if printVersion {
 		cmd.ExitWithVersion()
@@ -72,6 +72,10 @@ func FlagGroupPath(groupPath *string) {
 	flagSet.StringVar(groupPath, "group", *groupPath, "path to group.toml")
 }
 
+func FlagInsecureRegistriesFile(path *string) {
+	flagSet.StringVar(path, "insecure-registries-file", *path, "path to file listing registries to access over plain HTTP or without TLS verification")
+}
+
 func FlagKanikoCacheTTL(kanikoCacheTTL *time.Duration) {
 	flagSet.DurationVar(kanikoCacheTTL, "kaniko-cache-ttl", *kanikoCacheTTL, "kaniko cache time-to-live")
 }
@@ -132,6 +136,10 @@ func FlagRunImage(runImage *string) {
 	flagSet.StringVar(runImage, "run-image", *runImage, "reference to run image")
 }
 
+func FlagRunImageDigestFile(path *string) {
+	flagSet.StringVar(path, "run-image-digest-file", *path, "path to file mapping run image references to digests, used to pin the run image (not supported with -daemon)")
+}
+
 func FlagRunPath(runPath *string) {
 	flagSet.StringVar(runPath, "run", *runPath, "path to run.toml")
 }
@@ -168,6 +176,14 @@ func FlagForceRebase(force *bool) {
 	flagSet.BoolVar(force, "force", *force, "execute rebase even if operation is unsafe")
 }
 
+func FlagCheckABI(check *bool) {
+	flagSet.BoolVar(check, "check-abi", *check, "require -force to rebase onto a run image with a different distribution name or major version")
+}
+
+func FlagVerifyLayers(verify *bool) {
+	flagSet.BoolVar(verify, "verify-layers", *verify, "verify that the app layers keep their order and no layers from the previous run image remain after rebase")
+}
+
 // deprecated
 
 func DeprecatedFlagRunImage(deprecatedRunImage *string) {
@@ -44,6 +44,7 @@ func (c *createCmd) DefineFlags() {
 	cli.FlagCacheDir(&c.CacheDir)
 	cli.FlagCacheImage(&c.CacheImageRef)
 	cli.FlagGID(&c.GID)
+	cli.FlagInsecureRegistriesFile(&c.InsecureRegistriesFile)
 	cli.FlagLaunchCacheDir(&c.LaunchCacheDir)
 	cli.FlagLauncherPath(&c.LauncherPath)
 	cli.FlagLayersDir(&c.LayersDir)
@@ -125,8 +126,8 @@ func (c *createCmd) Exec() error {
 			&cmd.BuildpackAPIVerifier{},
 			NewCacheHandler(c.keychain),
 			lifecycle.NewConfigHandler(),
-			image.NewHandler(c.docker, c.keychain, c.LayoutDir, c.UseLayout),
-			NewRegistryHandler(c.keychain),
+			image.NewHandler(c.docker, c.keychain, c.LayoutDir, c.UseLayout, image.WithInsecureRegistries(c.InsecureRegistries...)),
+			image.NewRegistryHandler(c.keychain, c.InsecureRegistries, image.WithLogger(cmd.DefaultLogger)),
 		)
 		analyzer, err := analyzerFactory.NewAnalyzer(
 			c.AdditionalTags,
@@ -188,8 +189,8 @@ func (c *createCmd) Exec() error {
 			&cmd.BuildpackAPIVerifier{},
 			NewCacheHandler(c.keychain),
 			lifecycle.NewConfigHandler(),
-			image.NewHandler(c.docker, c.keychain, c.LayoutDir, c.UseLayout),
-			NewRegistryHandler(c.keychain),
+			image.NewHandler(c.docker, c.keychain, c.LayoutDir, c.UseLayout, image.WithInsecureRegistries(c.InsecureRegistries...)),
+			image.NewRegistryHandler(c.keychain, c.InsecureRegistries, image.WithLogger(cmd.DefaultLogger)),
 		)
 		analyzer, err := analyzerFactory.NewAnalyzer(
 			c.AdditionalTags,
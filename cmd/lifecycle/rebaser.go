@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/local"
@@ -22,6 +23,9 @@ import (
 	"github.com/buildpacks/lifecycle/priv"
 )
 
+// reportPathStdout is the report path that causes the rebase report to be written to stdout instead of a file.
+const reportPathStdout = "-"
+
 type rebaseCmd struct {
 	*platform.Platform
 
@@ -36,6 +40,7 @@ func (r *rebaseCmd) DefineFlags() {
 	cli.FlagGID(&r.GID)
 	cli.FlagReportPath(&r.ReportPath)
 	cli.FlagRunImage(&r.RunImageRef)
+	cli.FlagRunImageDigestFile(&r.RunImageDigestFile)
 	cli.FlagUID(&r.UID)
 	cli.FlagUseDaemon(&r.UseDaemon)
 	cli.DeprecatedFlagRunImage(&r.DeprecatedRunImageRef)
@@ -47,6 +52,13 @@ func (r *rebaseCmd) DefineFlags() {
 	if r.PlatformAPI.AtLeast("0.12") {
 		cli.FlagForceRebase(&r.ForceRebase)
 	}
+	cli.FlagVerifyLayers(&r.VerifyRebaseLayers)
+	cli.FlagCheckABI(&r.CheckRebaseABI)
+}
+
+// WritesToStdout returns true if the rebase report is written to stdout.
+func (r *rebaseCmd) WritesToStdout() bool {
+	return r.ReportPath == reportPathStdout
 }
 
 // Args validates arguments and flags, and fills in default values.
@@ -59,6 +71,13 @@ func (r *rebaseCmd) Args(nargs int, args []string) error {
 	if err := platform.ResolveInputs(platform.Rebase, r.LifecycleInputs, cmd.DefaultLogger); err != nil {
 		return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "resolve inputs")
 	}
+	if r.UseDaemon && r.VerifyRebaseLayers {
+		return cmd.FailErrCode(errors.New("-verify-layers is not supported with -daemon"), cmd.CodeForInvalidArgs, "parse arguments")
+	}
+	if r.UseDaemon && r.RunImageDigestFile != "" {
+		// a digest reference does not match the ID of an image in the daemon
+		return cmd.FailErrCode(errors.New("-run-image-digest-file is not supported with -daemon"), cmd.CodeForInvalidArgs, "parse arguments")
+	}
 	var err error
 	if !r.UseDaemon {
 		// We may need to read the application image in order to know the run image, so
@@ -70,6 +89,15 @@ func (r *rebaseCmd) Args(nargs int, args []string) error {
 		if err = r.setAppImage(); err != nil {
 			return cmd.FailErrCode(errors.New(err.Error()), r.CodeFor(platform.RebaseError), "set app image")
 		}
+		// pin the run image before the keychain is constructed, so that the keychain includes the pinned reference
+		if r.RunImageDigestFile != "" {
+			pinnedRef, err := platform.PinRunImage(r.RunImageRef, r.RunImageDigestFile)
+			if err != nil {
+				return cmd.FailErrCode(err, cmd.CodeForInvalidArgs, "pin run image")
+			}
+			cmd.DefaultLogger.Infof("Using run image %s pinned in %s", pinnedRef, r.RunImageDigestFile)
+			r.RunImageRef = pinnedRef
+		}
 	}
 	return nil
 }
@@ -122,21 +150,36 @@ func (r *rebaseCmd) Exec() error {
 	}
 
 	rebaser := &lifecycle.Rebaser{
-		Logger:      cmd.DefaultLogger,
-		PlatformAPI: r.PlatformAPI,
-		Force:       r.ForceRebase,
+		Logger:                cmd.DefaultLogger,
+		PlatformAPI:           r.PlatformAPI,
+		Force:                 r.ForceRebase,
+		VerifyLayers:          r.VerifyRebaseLayers,
+		CheckABICompatibility: r.CheckRebaseABI,
 	}
 	report, err := rebaser.Rebase(r.appImage, newBaseImage, r.OutputImageRef, r.AdditionalTags)
 	if err != nil {
+		if report.Partial() {
+			// record which tags were saved before the failure
+			if writeErr := r.writeReport(&report); writeErr != nil {
+				cmd.DefaultLogger.Warnf("Failed to write partial rebase report: %s", writeErr)
+			}
+		}
 		return cmd.FailErrCode(err, r.CodeFor(platform.RebaseError), "rebase")
 	}
 
-	if err := encoding.WriteTOML(r.ReportPath, &report); err != nil {
+	if err := r.writeReport(&report); err != nil {
 		return cmd.FailErrCode(err, r.CodeFor(platform.RebaseError), "write rebase report")
 	}
 	return nil
 }
 
+func (r *rebaseCmd) writeReport(report *lifecycle.RebaseReport) error {
+	if r.WritesToStdout() {
+		return encoding.BurntSushiTOML{}.EncodeTOML(os.Stdout, report)
+	}
+	return encoding.WriteTOML(r.ReportPath, report)
+}
+
 func (r *rebaseCmd) setAppImage() error {
 	var targetImageRef string
 	if len(r.PreviousImageRef) > 0 {
@@ -182,6 +225,7 @@ func (r *rebaseCmd) setAppImage() error {
 		if r.PlatformAPI.AtLeast("0.12") {
 			r.RunImageRef = md.RunImage.Reference
 			if r.RunImageRef != "" {
+				cmd.DefaultLogger.Infof(platform.MsgUsingRunImageFrom, r.RunImageRef, "app image metadata")
 				return nil
 			}
 		}
@@ -197,6 +241,7 @@ func (r *rebaseCmd) setAppImage() error {
 		if err != nil {
 			return err
 		}
+		cmd.DefaultLogger.Infof(platform.MsgUsingRunImageFrom, r.RunImageRef, "app image stack metadata")
 	}
 
 	return nil
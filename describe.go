@@ -0,0 +1,51 @@
+package lifecycle
+
+import (
+	"sort"
+
+	"github.com/buildpacks/imgutil"
+
+	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/platform"
+	"github.com/buildpacks/lifecycle/platform/files"
+)
+
+// LayerDescription describes a buildpack-contributed layer recorded in the metadata of an app image.
+type LayerDescription struct {
+	BuildpackID string
+	Name        string
+	DiffID      string // empty for layers that were not exported to the image (e.g., cache-only layers)
+	Launch      bool
+	Build       bool
+	Cache       bool
+}
+
+// DescribeLayers returns the buildpack layers recorded in the lifecycle metadata label of the provided app image,
+// in buildpack order and then ordered by layer name. It does not modify the image.
+// If the image does not exist or does not have the label, the returned error wraps image.ErrLabelNotFound.
+func DescribeLayers(img imgutil.Image) ([]LayerDescription, error) {
+	var md files.LayersMetadata
+	if err := image.DecodeRequiredLabel(img, platform.LifecycleMetadataLabel, &md); err != nil {
+		return nil, err
+	}
+	var descriptions []LayerDescription
+	for _, bp := range md.Buildpacks {
+		names := make([]string, 0, len(bp.Layers))
+		for name := range bp.Layers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			layer := bp.Layers[name]
+			descriptions = append(descriptions, LayerDescription{
+				BuildpackID: bp.ID,
+				Name:        name,
+				DiffID:      layer.SHA,
+				Launch:      layer.Launch,
+				Build:       layer.Build,
+				Cache:       layer.Cache,
+			})
+		}
+	}
+	return descriptions, nil
+}
@@ -0,0 +1,77 @@
+package lifecycle_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	"github.com/buildpacks/lifecycle/image"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestDescribe(t *testing.T) {
+	spec.Run(t, "Describe", testDescribe, spec.Report(report.Terminal{}))
+}
+
+func testDescribe(t *testing.T, when spec.G, it spec.S) {
+	when(".DescribeLayers", func() {
+		var fakeImage *fakes.Image
+
+		it.Before(func() {
+			fakeImage = fakes.NewImage("some-repo/app-image", "", nil)
+		})
+
+		it.After(func() {
+			h.AssertNil(t, fakeImage.Cleanup())
+		})
+
+		it("describes the buildpack layers in buildpack order and then by layer name", func() {
+			h.AssertNil(t, fakeImage.SetLabel(platform.LifecycleMetadataLabel, `{
+  "buildpacks": [
+    {
+      "key": "some/buildpack",
+      "version": "v1",
+      "layers": {
+        "some-layer": {"sha": "sha256:some-layer-sha", "launch": true, "build": true},
+        "other-layer": {"sha": "sha256:other-layer-sha", "launch": true, "cache": true}
+      }
+    },
+    {
+      "key": "other/buildpack",
+      "version": "v2",
+      "layers": {
+        "cache-only-layer": {"cache": true}
+      }
+    }
+  ]
+}`))
+
+			descriptions, err := lifecycle.DescribeLayers(fakeImage)
+			h.AssertNil(t, err)
+
+			h.AssertEq(t, descriptions, []lifecycle.LayerDescription{
+				{BuildpackID: "some/buildpack", Name: "other-layer", DiffID: "sha256:other-layer-sha", Launch: true, Cache: true},
+				{BuildpackID: "some/buildpack", Name: "some-layer", DiffID: "sha256:some-layer-sha", Launch: true, Build: true},
+				{BuildpackID: "other/buildpack", Name: "cache-only-layer", Cache: true},
+			})
+		})
+
+		it("errors when the image does not have the metadata label", func() {
+			_, err := lifecycle.DescribeLayers(fakeImage)
+			h.AssertEq(t, errors.Is(err, image.ErrLabelNotFound), true)
+		})
+
+		it("errors when the image does not exist", func() {
+			missingImage := fakes.NewImage("some-repo/missing-image", "", nil)
+			missingImage.Delete()
+
+			_, err := lifecycle.DescribeLayers(missingImage)
+			h.AssertEq(t, errors.Is(err, image.ErrLabelNotFound), true)
+		})
+	})
+}
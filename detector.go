@@ -285,12 +285,19 @@ func (d *Detector) detectGroup(group buildpack.Group, done []buildpack.GroupElem
 					BuildConfigDir: d.BuildConfigDir,
 					PlatformDir:    d.PlatformDir,
 				}
+				environ := os.Environ()
 				if d.AnalyzeMD.RunImage != nil && d.AnalyzeMD.RunImage.TargetMetadata != nil && d.PlatformAPI.AtLeast("0.12") {
-					inputs.Env = env.NewBuildEnv(append(os.Environ(), platform.EnvVarsFor(*d.AnalyzeMD.RunImage.TargetMetadata)...))
+					environ = append(environ, platform.EnvVarsFor(*d.AnalyzeMD.RunImage.TargetMetadata)...)
+				}
+				buildEnvConfig, err := env.LoadBuildEnvConfig(environ)
+				if err != nil {
+					d.Runs.Store(key, buildpack.DetectOutputs{Code: -1, Err: fmt.Errorf("creating build environment: %w", err)})
 				} else {
-					inputs.Env = env.NewBuildEnv(os.Environ())
+					buildEnv := env.NewBuildEnvFromConfig(environ, buildEnvConfig)
+					buildEnv.Logger = d.Logger
+					inputs.Env = buildEnv
+					d.Runs.Store(key, d.Executor.Detect(descriptor, inputs, d.Logger)) // this is where we finally invoke bin/detect
 				}
-				d.Runs.Store(key, d.Executor.Detect(descriptor, inputs, d.Logger)) // this is where we finally invoke bin/detect
 			}
 			wg.Done()
 		}(key, descriptor)
@@ -1,10 +1,20 @@
 package env
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+
+	"github.com/BurntSushi/toml"
 )
 
+// EnvBuildEnvConfig is the path to an optional TOML (or, with a .json extension, JSON) file
+// that overrides the compiled-in BuildEnvIncludelist, BuildEnvExcludelist and POSIXBuildEnv root dir map
+const EnvBuildEnvConfig = "CNB_BUILD_ENV_CONFIG"
+
 // BuildEnvIncludelist are env vars that, if set in the lifecycle's execution environment - either in a builder or by the platform, are passed-through to buildpack executables
 var BuildEnvIncludelist = []string{
 	"CNB_STACK_ID", // deprecated as of api 0.12.0
@@ -24,18 +34,88 @@ var BuildEnvIncludelist = []string{
 	"no_proxy",
 }
 
+// BuildEnvExcludelist are env vars that are always removed from the build environment,
+// even if they are in the BuildEnvIncludelist or the root dir map; an entry ending in '*' matches any env var with that prefix.
+// It is empty by default.
+var BuildEnvExcludelist []string
+
 var ignoreEnvVarCase = runtime.GOOS == "windows"
 
-// NewBuildEnv returns a build-time Env from the given environment.
+// BuildEnvConfig is the format of the file provided by CNB_BUILD_ENV_CONFIG.
+// Fields that are omitted fall back to the compiled-in defaults.
+type BuildEnvConfig struct {
+	Includelist []string            `toml:"include" json:"include"`
+	Excludelist []string            `toml:"exclude" json:"exclude"`
+	RootDirMap  map[string][]string `toml:"root-dirs" json:"root-dirs"`
+}
+
+// NewBuildEnv returns a build-time Env from the given environment, using the compiled-in include list, exclude list
+// and root dir map.
 //
-// Keys in the BuildEnvIncludelist will be added to the Environment.
+// Keys in the BuildEnvIncludelist will be added to the Environment, unless they match the BuildEnvExcludelist.
+// The returned Env has its own copy of the root dir map, so POSIXBuildEnv is never modified through it
+// and NewBuildEnv is safe to call concurrently.
 func NewBuildEnv(environ []string) *Env {
-	envFilter := isNotMember(BuildEnvIncludelist, flattenMap(POSIXBuildEnv))
+	return NewBuildEnvFromConfig(environ, BuildEnvConfig{})
+}
+
+// NewBuildEnvFromConfig is like NewBuildEnv, but uses the include list, exclude list and root dir map from config
+// in place of the compiled-in defaults wherever they are set.
+func NewBuildEnvFromConfig(environ []string, config BuildEnvConfig) *Env {
+	includelist, excludelist, rootDirMap := BuildEnvIncludelist, BuildEnvExcludelist, POSIXBuildEnv
+	if config.Includelist != nil {
+		includelist = config.Includelist
+	}
+	if config.Excludelist != nil {
+		excludelist = config.Excludelist
+	}
+	if config.RootDirMap != nil {
+		rootDirMap = config.RootDirMap
+	}
+	rootDirMap = copyRootDirMap(rootDirMap)
+	notIncluded := isNotMember(includelist, flattenMap(rootDirMap))
+	excluded := isExcludedBy(excludelist)
+	envFilter := func(key string) bool {
+		return notIncluded(key) || excluded(key)
+	}
 
 	return &Env{
-		RootDirMap: POSIXBuildEnv,
+		RootDirMap: rootDirMap,
 		Vars:       varsFromEnv(environ, ignoreEnvVarCase, envFilter),
+		excluded:   envFilter,
+	}
+}
+
+// LoadBuildEnvConfig reads the file provided by CNB_BUILD_ENV_CONFIG in the given environment.
+// It returns an empty BuildEnvConfig, so that the compiled-in defaults are used, when CNB_BUILD_ENV_CONFIG is unset
+// or the file does not exist, and an error when the file cannot be read or parsed.
+func LoadBuildEnvConfig(environ []string) (BuildEnvConfig, error) {
+	var path string
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && matches(parts[0], EnvBuildEnvConfig) {
+			path = parts[1]
+		}
+	}
+	var config BuildEnvConfig
+	if path == "" {
+		return config, nil
+	}
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return config, fmt.Errorf("read build env config '%s': %w", path, err)
 	}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(contents, &config)
+	} else {
+		err = toml.Unmarshal(contents, &config)
+	}
+	if err != nil {
+		return config, fmt.Errorf("parse build env config '%s': %w", path, err)
+	}
+	return config, nil
 }
 
 func matches(k1, k2 string) bool {
@@ -46,6 +126,7 @@ func matches(k1, k2 string) bool {
 	return k1 == k2
 }
 
+// POSIXBuildEnv is the default root dir map for the build environment; it should be treated as read-only.
 var POSIXBuildEnv = map[string][]string{
 	"bin": {
 		"PATH",
@@ -76,6 +157,27 @@ func isNotMember(lists ...[]string) func(string) bool {
 	}
 }
 
+// isExcludedBy returns a filter that is true for keys matching any entry in the exclude list,
+// where an entry ending in '*' matches any key with that prefix.
+func isExcludedBy(excludelist []string) func(string) bool {
+	return func(key string) bool {
+		for _, entry := range excludelist {
+			if prefix, ok := strings.CutSuffix(entry, "*"); ok {
+				if ignoreEnvVarCase {
+					if strings.HasPrefix(strings.ToUpper(key), strings.ToUpper(prefix)) {
+						return true
+					}
+				} else if strings.HasPrefix(key, prefix) {
+					return true
+				}
+			} else if matches(entry, key) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 func flattenMap(m map[string][]string) []string {
 	result := make([]string, 0)
 	for _, subList := range m {
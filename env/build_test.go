@@ -1,8 +1,11 @@
 package env_test
 
 import (
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
+	"sync"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -102,6 +105,14 @@ func testBuildEnv(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("reports the keys it filters out", func() {
+			benv := env.NewBuildEnv([]string{})
+			h.AssertEq(t, benv.IsExcluded("SOME_VAR"), true)
+			h.AssertEq(t, benv.IsExcluded("HOME"), false)
+			h.AssertEq(t, benv.IsExcluded("PATH"), false)
+			h.AssertEq(t, benv.Clone().IsExcluded("SOME_VAR"), true)
+		})
+
 		it("assign the build time root dir map", func() {
 			benv := env.NewBuildEnv([]string{})
 			if s := cmp.Diff(benv.RootDirMap, env.POSIXBuildEnv); s != "" {
@@ -109,6 +120,67 @@ func testBuildEnv(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("does not share the default root dir map", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					benv := env.NewBuildEnv([]string{"PATH=some-path"})
+					benv.RootDirMap["bin"] = append(benv.RootDirMap["bin"], "SOME_OTHER_PATH")
+					benv.RootDirMap["bin"][0] = "SOME_PATH"
+					benv.RootDirMap["some-dir"] = []string{"SOME_VAR"}
+				}()
+			}
+			wg.Wait()
+
+			h.AssertEq(t, env.POSIXBuildEnv, map[string][]string{
+				"bin":       {"PATH"},
+				"lib":       {"LD_LIBRARY_PATH", "LIBRARY_PATH"},
+				"include":   {"CPATH"},
+				"pkgconfig": {"PKG_CONFIG_PATH"},
+			})
+		})
+
+		when("there is an exclude list", func() {
+			it.Before(func() {
+				env.BuildEnvExcludelist = []string{"HTTP_PROXY", "AWS_*"}
+			})
+
+			it.After(func() {
+				env.BuildEnvExcludelist = nil
+			})
+
+			it("removes excluded keys even if they would otherwise be included", func() {
+				benv := env.NewBuildEnv([]string{
+					"HOME=some-home",
+					"HTTP_PROXY=some-proxy",
+					"AWS_ACCESS_KEY_ID=some-key-id",
+					"AWS_SECRET_ACCESS_KEY=some-secret",
+					"PATH=some-path",
+				})
+
+				out := benv.List()
+				sort.Strings(out)
+				h.AssertEq(t, out, []string{"HOME=some-home", "PATH=some-path"})
+			})
+		})
+
+		it("ignores CNB_BUILD_ENV_CONFIG", func() {
+			tmpDir, err := os.MkdirTemp("", "lifecycle.env")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(tmpDir)
+			configPath := filepath.Join(tmpDir, "config.toml")
+			h.Mkfile(t, `exclude = ["HOME"]`, configPath)
+
+			benv := env.NewBuildEnv([]string{
+				"CNB_BUILD_ENV_CONFIG=" + configPath,
+				"HOME=some-home",
+			})
+
+			h.AssertEq(t, benv.List(), []string{"HOME=some-home"})
+		})
+
 		when("building in Windows", func() {
 			it.Before(func() {
 				if runtime.GOOS != "windows" {
@@ -126,4 +198,97 @@ func testBuildEnv(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when("#LoadBuildEnvConfig", func() {
+		var tmpDir string
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = os.MkdirTemp("", "lifecycle.env")
+			h.AssertNil(t, err)
+		})
+
+		it.After(func() {
+			_ = os.RemoveAll(tmpDir)
+		})
+
+		it("reads the include list and root dir map from a TOML file", func() {
+			configPath := filepath.Join(tmpDir, "config.toml")
+			h.Mkfile(t, `include = ["SOME_VAR"]
+
+[root-dirs]
+  bin = ["SOME_PATH"]
+`, configPath)
+
+			environ := []string{
+				"CNB_BUILD_ENV_CONFIG=" + configPath,
+				"SOME_VAR=some-val",
+				"SOME_PATH=some-path",
+				"HOME=some-home",
+			}
+			config, err := env.LoadBuildEnvConfig(environ)
+			h.AssertNil(t, err)
+			benv := env.NewBuildEnvFromConfig(environ, config)
+
+			out := benv.List()
+			sort.Strings(out)
+			h.AssertEq(t, out, []string{"SOME_PATH=some-path", "SOME_VAR=some-val"})
+			h.AssertEq(t, benv.RootDirMap, map[string][]string{"bin": {"SOME_PATH"}})
+		})
+
+		it("reads the exclude list from a TOML file", func() {
+			configPath := filepath.Join(tmpDir, "config.toml")
+			h.Mkfile(t, `exclude = ["HOME"]`, configPath)
+
+			environ := []string{
+				"CNB_BUILD_ENV_CONFIG=" + configPath,
+				"HOME=some-home",
+				"PATH=some-path",
+			}
+			config, err := env.LoadBuildEnvConfig(environ)
+			h.AssertNil(t, err)
+			benv := env.NewBuildEnvFromConfig(environ, config)
+
+			h.AssertEq(t, benv.List(), []string{"PATH=some-path"})
+		})
+
+		it("reads the include list from a JSON file", func() {
+			configPath := filepath.Join(tmpDir, "config.json")
+			h.Mkfile(t, `{"include": ["SOME_VAR"]}`, configPath)
+
+			environ := []string{
+				"CNB_BUILD_ENV_CONFIG=" + configPath,
+				"SOME_VAR=some-val",
+				"HOME=some-home",
+			}
+			config, err := env.LoadBuildEnvConfig(environ)
+			h.AssertNil(t, err)
+			benv := env.NewBuildEnvFromConfig(environ, config)
+
+			h.AssertEq(t, benv.List(), []string{"SOME_VAR=some-val"})
+			if s := cmp.Diff(benv.RootDirMap, env.POSIXBuildEnv); s != "" {
+				t.Fatalf("Unexpected root dir map\n%s\n", s)
+			}
+		})
+
+		it("uses the defaults when the file does not exist", func() {
+			environ := []string{
+				"CNB_BUILD_ENV_CONFIG=" + filepath.Join(tmpDir, "missing.toml"),
+				"HOME=some-home",
+			}
+			config, err := env.LoadBuildEnvConfig(environ)
+			h.AssertNil(t, err)
+			benv := env.NewBuildEnvFromConfig(environ, config)
+
+			h.AssertEq(t, benv.List(), []string{"HOME=some-home"})
+		})
+
+		it("errors when the file is malformed", func() {
+			configPath := filepath.Join(tmpDir, "config.toml")
+			h.Mkfile(t, `include = "not-a-list`, configPath)
+
+			_, err := env.LoadBuildEnvConfig([]string{"CNB_BUILD_ENV_CONFIG=" + configPath})
+			h.AssertError(t, err, "parse build env config")
+		})
+	})
 }
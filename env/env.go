@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/log"
 )
 
 // Env is used to modify and return environment variables
@@ -15,6 +16,10 @@ type Env struct {
 	// RootDirMap maps directories in a posix root filesystem to a slice of environment variables that
 	RootDirMap map[string][]string
 	Vars       *Vars
+	// Logger, if set, receives warnings about files in <platform>/env that are skipped
+	Logger log.Logger
+	// excluded, if set, reports the variables that NewBuildEnvFromConfig filtered out of the lifecycle's environment
+	excluded func(string) bool
 }
 
 // AddRootDir modifies the environment given a root dir. If the root dir contains a directory that matches a key in
@@ -39,7 +44,8 @@ func (p *Env) AddRootDir(dir string) error {
 	return nil
 }
 
-func (p *Env) isRootEnv(name string) bool {
+// IsRootEnv returns true if name is one of the variables in the Env RootDirMap (e.g., PATH).
+func (p *Env) IsRootEnv(name string) bool {
 	for _, m := range p.RootDirMap {
 		for _, k := range m {
 			if k == name {
@@ -85,7 +91,7 @@ func (p *Env) Set(name, v string) {
 // If platformDir is non-empty, for each file in the platformDir, if the name of the file does not match an environment variable name in the
 // RootDirMap, the given variable will be set to the contents of the file. If the name does match an environment
 // variable name in the RootDirMap, the contents of the file will be prepended to the environment variable value
-// using the OS path list separator as a delimiter.
+// using the OS path list separator as a delimiter. Files whose names are not valid variable names (see IsValidVarName) are skipped.
 // If baseConfigDir is non-empty, for each file in the envDir, if the file has
 // a period delimited suffix, the action matching the given suffix will be performed. If the file has no suffix,
 // the default action will be performed. If the suffix does not match a known type, AddEnvDir will ignore the file.
@@ -94,7 +100,13 @@ func (p *Env) WithOverrides(platformDir string, baseConfigDir string) (output []
 
 	if platformDir != "" {
 		if err := eachEnvFile(filepath.Join(platformDir, "env"), func(k, v string) error {
-			if p.isRootEnv(k) {
+			if !IsValidVarName(k) {
+				if p.Logger != nil {
+					p.Logger.Warnf("Skipping file '%s' in platform env dir '%s': it is not a valid environment variable name", k, filepath.Join(platformDir, "env"))
+				}
+				return nil
+			}
+			if p.IsRootEnv(k) {
 				vars.Set(k, v+prefix(vars.Get(k), os.PathListSeparator))
 				return nil
 			}
@@ -114,6 +126,23 @@ func (p *Env) WithOverrides(platformDir string, baseConfigDir string) (output []
 	return vars.List(), nil
 }
 
+// IsValidVarName returns true if name is usable as an environment variable name in a POSIX shell,
+// i.e., it consists of letters, digits, and underscores, and does not start with a digit.
+func IsValidVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 func addEnvDir(vars *Vars, envDir string, defaultAction ActionType) error {
 	if err := eachEnvFile(envDir, func(k, v string) error {
 		parts := strings.SplitN(k, ".", 2)
@@ -199,6 +228,42 @@ func eachEnvFile(dir string, fn func(k, v string) error) error {
 	return nil
 }
 
+// Remove removes the variable with the given name from the environment.
+// For list-type variables (e.g., PATH), the entire value is removed, including any entries added by previous buildpacks;
+// entries added afterwards (e.g., via AddRootDir or AddEnvDir) will start a new list rather than being combined with the removed value.
+func (p *Env) Remove(name string) {
+	p.Vars.Remove(name)
+}
+
+// Clone returns a deep copy of the environment that does not share any state with the original,
+// so that modifications made to one (e.g., by a buildpack's layers) are not visible in the other.
+func (p *Env) Clone() *Env {
+	clone := &Env{RootDirMap: copyRootDirMap(p.RootDirMap), Logger: p.Logger, excluded: p.excluded}
+	if p.Vars != nil {
+		clone.Vars = NewVars(p.Vars.vals, p.Vars.ignoreCase)
+	}
+	return clone
+}
+
+// IsExcluded returns true if the variable is not passed through to buildpacks from the lifecycle's environment,
+// according to the include list, exclude list and root dir map used by NewBuildEnvFromConfig.
+// It always returns false for an Env that was not created by NewBuildEnv or NewBuildEnvFromConfig.
+func (p *Env) IsExcluded(name string) bool {
+	return p.excluded != nil && p.excluded(name)
+}
+
+// copyRootDirMap returns a deep copy of the given root dir map, or nil if it is nil.
+func copyRootDirMap(m map[string][]string) map[string][]string {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string][]string, len(m))
+	for dir, vars := range m {
+		result[dir] = append([]string{}, vars...)
+	}
+	return result
+}
+
 // List returns the environment
 func (p *Env) List() []string {
 	return p.Vars.List()
@@ -1,6 +1,7 @@
 package env_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,7 @@ import (
 	"github.com/sclevine/spec/report"
 
 	"github.com/buildpacks/lifecycle/env"
+	"github.com/buildpacks/lifecycle/log"
 )
 
 func TestEnv(t *testing.T) {
@@ -152,6 +154,19 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("there is a delimiter file without a matching env file", func() {
+			it("does not set a variable", func() {
+				mkfile(t, "[]", filepath.Join(tmpDir, "VAR_ONLY_DELIM.delim"))
+				envv.Vars = env.NewVars(map[string]string{}, false)
+				if err := envv.AddEnvDir(tmpDir, env.ActionTypeOverride); err != nil {
+					t.Fatalf("Error: %s\n", err)
+				}
+				if out := envv.List(); len(out) != 0 {
+					t.Fatalf("Unexpected env:\n%s\n", out)
+				}
+			})
+		})
+
 		when("env files have no suffix", func() {
 			it.Before(func() {
 				mkdir(t, filepath.Join(tmpDir, "some-dir"))
@@ -210,6 +225,101 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#Remove", func() {
+		it("removes the variable", func() {
+			envv.Vars = env.NewVars(map[string]string{
+				"VAR":       "some-val",
+				"OTHER_VAR": "other-val",
+			}, false)
+			envv.Remove("VAR")
+			out := envv.List()
+			expected := []string{"OTHER_VAR=other-val"}
+			if s := cmp.Diff(out, expected); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+
+		it("starts a new list when a list-type variable is added to after removal", func() {
+			mkdir(t, filepath.Join(tmpDir, "bin"))
+			envv.Vars = env.NewVars(map[string]string{
+				"PATH": "some-path",
+			}, false)
+			envv.Remove("PATH")
+			if err := envv.AddRootDir(tmpDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+			out := envv.List()
+			expected := []string{"PATH=" + filepath.Join(tmpDir, "bin")}
+			if s := cmp.Diff(out, expected); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+
+		it("ignores case on Windows", func() {
+			envv.Vars = env.NewVars(map[string]string{
+				"VAR": "some-val",
+			}, true)
+			envv.Remove("var")
+			if out := envv.List(); len(out) != 0 {
+				t.Fatalf("Unexpected env:\n%s\n", out)
+			}
+		})
+	})
+
+	when("#Clone", func() {
+		it.Before(func() {
+			envv.Vars = env.NewVars(map[string]string{
+				"VAR": "orig-val",
+			}, false)
+		})
+
+		it("returns a copy with the same variables", func() {
+			clone := envv.Clone()
+
+			if s := cmp.Diff(clone.List(), []string{"VAR=orig-val"}); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+			if s := cmp.Diff(clone.RootDirMap, envv.RootDirMap); s != "" {
+				t.Fatalf("Unexpected root dir map:\n%s\n", s)
+			}
+		})
+
+		it("does not share variables with the original", func() {
+			clone := envv.Clone()
+			clone.Set("VAR", "new-val")
+			clone.Set("OTHER_VAR", "other-val")
+
+			if s := cmp.Diff(envv.List(), []string{"VAR=orig-val"}); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+
+		it("does not share the root dir map with the original", func() {
+			clone := envv.Clone()
+			clone.RootDirMap["bin"][0] = "SOME_OTHER_PATH"
+			clone.RootDirMap["some-dir"] = []string{"SOME_VAR"}
+
+			if s := cmp.Diff(envv.RootDirMap["bin"][0], "PATH"); s != "" {
+				t.Fatalf("Unexpected root dir map:\n%s\n", s)
+			}
+			if _, ok := envv.RootDirMap["some-dir"]; ok {
+				t.Fatal("Expected root dir map not to be modified")
+			}
+		})
+
+		it("does not apply root dirs added to the clone to the original", func() {
+			mkdir(t, filepath.Join(tmpDir, "bin"))
+			clone := envv.Clone()
+			if err := clone.AddRootDir(tmpDir); err != nil {
+				t.Fatalf("Error: %s\n", err)
+			}
+
+			if s := cmp.Diff(envv.Get("PATH"), ""); s != "" {
+				t.Fatalf("Unexpected env:\n%s\n", s)
+			}
+		})
+	})
+
 	when("#Set", func() {
 		it("sets the variable", func() {
 			envv.Vars = env.NewVars(map[string]string{
@@ -224,6 +334,24 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when("#IsValidVarName", func() {
+		it("allows letters, digits, and underscores", func() {
+			for _, name := range []string{"MY_VAR", "my_var", "_MY_VAR", "MY_VAR_2"} {
+				if !env.IsValidVarName(name) {
+					t.Fatalf("Expected '%s' to be valid", name)
+				}
+			}
+		})
+
+		it("rejects other names", func() {
+			for _, name := range []string{"", "MY-VAR", "MY.VAR", "2_MY_VAR", "MY VAR", "MY=VAR"} {
+				if env.IsValidVarName(name) {
+					t.Fatalf("Expected '%s' to be invalid", name)
+				}
+			}
+		})
+	})
+
 	when("#WithOverrides", func() {
 		when("it has a platform dir", func() {
 			it("should apply platform env vars as filename=file-contents", func() {
@@ -262,6 +390,32 @@ func testEnv(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 		})
+		when("the platform dir has a file that is not a valid variable name", func() {
+			for _, name := range []string{"MY-VAR", "MY.VAR"} {
+				name := name
+				it(fmt.Sprintf("should skip %s with a warning", name), func() {
+					var logs bytes.Buffer
+					envv.Logger = log.NewDefaultLogger(&logs)
+					envv.Vars = env.NewVars(map[string]string{"VAR": "orig-val"}, false)
+					mkdir(t, filepath.Join(tmpDir, "env"))
+					mkfile(t, "some-value", filepath.Join(tmpDir, "env", name))
+					mkfile(t, "some-other-value", filepath.Join(tmpDir, "env", "OTHER_VAR"))
+
+					out, err := envv.WithOverrides(tmpDir, "")
+					if err != nil {
+						t.Fatalf("Unexpected error: %s\n", err)
+					}
+					expected := []string{"OTHER_VAR=some-other-value", "VAR=orig-val"}
+					sort.Strings(out)
+					if s := cmp.Diff(out, expected); s != "" {
+						t.Fatalf("Unexpected env:\n%s\n", s)
+					}
+					if !strings.Contains(logs.String(), fmt.Sprintf("Skipping file '%s'", name)) {
+						t.Fatalf("Expected warning, got: %s\n", logs.String())
+					}
+				})
+			}
+		})
 		when("it has a base config dir", func() {
 			it("should apply base config env vars appropriately", func() {
 				mkdir(t, filepath.Join(tmpDir, "env", "some-dir"))
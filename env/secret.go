@@ -0,0 +1,27 @@
+package env
+
+import "strings"
+
+// SecretPatterns are the words that mark an environment variable name as likely to hold a secret; see LooksLikeSecret.
+// Matching is case-insensitive. Operators may replace or extend the list to tune which variables are treated as secrets.
+var SecretPatterns = []string{
+	"TOKEN",
+	"SECRET",
+	"PASSWORD",
+	"KEY",
+	"CREDENTIAL",
+	"CREDENTIALS",
+}
+
+// LooksLikeSecret returns true if the provided environment variable name contains any of SecretPatterns as whole
+// underscore-delimited words, e.g., GITHUB_TOKEN or AWS_SECRET_ACCESS_KEY but not MONKEY_PATH or KEYBOARD_LAYOUT.
+// It may be used to decide which values to redact before they are displayed.
+func LooksLikeSecret(key string) bool {
+	key = "_" + strings.ToUpper(key) + "_"
+	for _, pattern := range SecretPatterns {
+		if pattern != "" && strings.Contains(key, "_"+strings.ToUpper(pattern)+"_") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package env_test
+
+import (
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/env"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestSecret(t *testing.T) {
+	spec.Run(t, "Secret", testSecret, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testSecret(t *testing.T, when spec.G, it spec.S) {
+	when("#LooksLikeSecret", func() {
+		it("returns true for names matching a secret pattern", func() {
+			for _, key := range []string{
+				"GITHUB_TOKEN",
+				"AWS_SECRET_ACCESS_KEY",
+				"DB_PASSWORD",
+				"API_KEY",
+				"GOOGLE_APPLICATION_CREDENTIALS",
+				"npm_config_password",
+				"KEY",
+				"KEY_FILE",
+				"SSH_KEY_PATH",
+			} {
+				h.AssertEq(t, env.LooksLikeSecret(key), true)
+			}
+		})
+
+		it("returns false for other names", func() {
+			for _, key := range []string{
+				"PATH",
+				"HOME",
+				"CNB_STACK_ID",
+				"BP_NODE_VERSION",
+				"MONKEY_PATH",
+				"KEYBOARD_LAYOUT",
+				"HOTKEY",
+				"TOKENIZER_MODEL",
+				"SECRETARY_NAME",
+				"",
+			} {
+				h.AssertEq(t, env.LooksLikeSecret(key), false)
+			}
+		})
+
+		when("the patterns are overridden", func() {
+			var original []string
+
+			it.Before(func() {
+				original = env.SecretPatterns
+				env.SecretPatterns = []string{"PRIVATE"}
+			})
+
+			it.After(func() {
+				env.SecretPatterns = original
+			})
+
+			it("uses the provided patterns", func() {
+				h.AssertEq(t, env.LooksLikeSecret("SOME_PRIVATE_VALUE"), true)
+				h.AssertEq(t, env.LooksLikeSecret("GITHUB_TOKEN"), false)
+			})
+		})
+	})
+}
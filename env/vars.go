@@ -43,6 +43,10 @@ func (s *Vars) Set(key, value string) {
 	s.vals[s.key(key)] = value
 }
 
+func (s *Vars) Remove(key string) {
+	delete(s.vals, s.key(key))
+}
+
 func (s *Vars) key(k string) string {
 	if s.ignoreCase {
 		return strings.ToUpper(k)
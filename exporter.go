@@ -721,7 +721,7 @@ func (e *Exporter) makeBuildReport(layersDir string) (files.BuildReport, error)
 			continue
 		}
 		var bpBuildReport files.BuildReport
-		bpBuildTOML := filepath.Join(layersDir, launch.EscapeID(bp.ID), "build.toml")
+		bpBuildTOML := filepath.Join(BuildpackLayersDir(layersDir, bp.ID), "build.toml")
 		if _, err := toml.DecodeFile(bpBuildTOML, &bpBuildReport); err != nil && !os.IsNotExist(err) {
 			return files.BuildReport{}, err
 		}
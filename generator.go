@@ -119,7 +119,11 @@ type GenerateResult struct {
 }
 
 func (g *Generator) Generate() (GenerateResult, error) {
-	inputs := g.getGenerateInputs()
+	buildEnvConfig, err := env.LoadBuildEnvConfig(os.Environ())
+	if err != nil {
+		return GenerateResult{}, fmt.Errorf("creating build environment: %w", err)
+	}
+	inputs := g.getGenerateInputs(buildEnvConfig)
 	extensionOutputParentDir, err := os.MkdirTemp("", "cnb-extensions-generated.")
 	if err != nil {
 		return GenerateResult{}, err
@@ -142,7 +146,9 @@ func (g *Generator) Generate() (GenerateResult, error) {
 		inputs.Plan = filteredPlan.Find(buildpack.KindExtension, ext.ID)
 
 		if g.AnalyzedMD.RunImage != nil && g.AnalyzedMD.RunImage.TargetMetadata != nil && g.PlatformAPI.AtLeast("0.12") {
-			inputs.Env = env.NewBuildEnv(append(inputs.Env.List(), platform.EnvVarsFor(*g.AnalyzedMD.RunImage.TargetMetadata)...))
+			buildEnv := env.NewBuildEnvFromConfig(append(inputs.Env.List(), platform.EnvVarsFor(*g.AnalyzedMD.RunImage.TargetMetadata)...), buildEnvConfig)
+			buildEnv.Logger = g.Logger
+			inputs.Env = buildEnv
 		}
 		g.Logger.Debug("Invoking command")
 		result, err := g.Executor.Generate(*descriptor, inputs, g.Logger)
@@ -204,12 +210,14 @@ func satisfies(images []files.RunImageForExport, imageName string) bool {
 	return false
 }
 
-func (g *Generator) getGenerateInputs() buildpack.GenerateInputs {
+func (g *Generator) getGenerateInputs(buildEnvConfig env.BuildEnvConfig) buildpack.GenerateInputs {
+	buildEnv := env.NewBuildEnvFromConfig(os.Environ(), buildEnvConfig)
+	buildEnv.Logger = g.Logger
 	return buildpack.GenerateInputs{
 		AppDir:         g.AppDir,
 		BuildConfigDir: g.BuildConfigDir,
 		PlatformDir:    g.PlatformDir,
-		Env:            env.NewBuildEnv(os.Environ()),
+		Env:            buildEnv,
 		Out:            g.Out,
 		Err:            g.Err,
 	}
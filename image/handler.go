@@ -1,9 +1,12 @@
 package image
 
 import (
+	"fmt"
+
 	"github.com/buildpacks/imgutil"
 	"github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
 )
 
 type Handler interface {
@@ -11,12 +14,42 @@ type Handler interface {
 	Kind() string
 }
 
+// Retagger is implemented by Handlers that can add tags to an existing image; callers should type-assert a Handler to it.
+type Retagger interface {
+	// Retag adds the provided destination tags to the image at srcRef without re-exporting the image.
+	Retag(srcRef string, dstRefs ...string) error
+}
+
+var (
+	_ Retagger = &LayoutHandler{}
+	_ Retagger = &LocalHandler{}
+	_ Retagger = &RemoteHandler{}
+)
+
+// HandlerOption configures the Handler returned by NewHandler.
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	insecureRegistries []string
+}
+
+// WithInsecureRegistries allows a RemoteHandler to access the provided registries over plain HTTP or without TLS verification.
+func WithInsecureRegistries(registries ...string) HandlerOption {
+	return func(o *handlerOptions) {
+		o.insecureRegistries = append(o.insecureRegistries, registries...)
+	}
+}
+
 // NewHandler creates a new Handler according to the arguments provided, following these rules:
 // - WHEN layoutDir is defined and useLayout is true then it returns a LayoutHandler
 // - WHEN a docker client is provided then it returns a LocalHandler
 // - WHEN an auth.Keychain is provided then it returns a RemoteHandler
 // - Otherwise nil is returned
-func NewHandler(docker client.CommonAPIClient, keychain authn.Keychain, layoutDir string, useLayout bool) Handler {
+func NewHandler(docker client.CommonAPIClient, keychain authn.Keychain, layoutDir string, useLayout bool, opts ...HandlerOption) Handler {
+	var o handlerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 	if layoutDir != "" && useLayout {
 		return &LayoutHandler{
 			layoutDir: layoutDir,
@@ -29,7 +62,51 @@ func NewHandler(docker client.CommonAPIClient, keychain authn.Keychain, layoutDi
 	}
 	if keychain != nil {
 		return &RemoteHandler{
-			keychain: keychain,
+			keychain:           keychain,
+			insecureRegistries: o.insecureRegistries,
+		}
+	}
+	return nil
+}
+
+// TagValidationError is returned by ValidateDestinationTags and identifies the tag that failed validation.
+type TagValidationError struct {
+	Tag    string
+	Reason string
+	Err    error // the underlying error, if any
+}
+
+func (e *TagValidationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid tag '%s': %s: %s", e.Tag, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("invalid tag '%s': %s", e.Tag, e.Reason)
+}
+
+func (e *TagValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateDestinationTags ensures all tags are valid image references.
+// When useDaemon is false (i.e., exporting to a registry), it also ensures all tags are on the same registry as the first tag.
+// Any returned error is a *TagValidationError.
+func ValidateDestinationTags(useDaemon bool, repoNames ...string) error {
+	var firstRegistry string
+	for i, repoName := range repoNames {
+		ref, err := name.ParseReference(repoName, name.WeakValidation)
+		if err != nil {
+			return &TagValidationError{Tag: repoName, Reason: "not a valid image reference", Err: err}
+		}
+		registry := ref.Context().RegistryStr()
+		if i == 0 {
+			firstRegistry = registry
+			continue
+		}
+		if !useDaemon && registry != firstRegistry {
+			return &TagValidationError{
+				Tag:    repoName,
+				Reason: fmt.Sprintf("writing to multiple registries is unsupported (registry '%s' differs from '%s')", registry, firstRegistry),
+			}
 		}
 	}
 	return nil
@@ -0,0 +1,52 @@
+package image_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/image"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestHandler(t *testing.T) {
+	spec.Run(t, "Handler", testHandler, spec.Report(report.Terminal{}))
+}
+
+func testHandler(t *testing.T, when spec.G, it spec.S) {
+	when(".ValidateDestinationTags", func() {
+		it("succeeds when all tags are on the same registry", func() {
+			h.AssertNil(t, image.ValidateDestinationTags(false, "some-registry.io/some-repo:some-tag", "some-registry.io/some-other-repo"))
+		})
+
+		it("errors when a tag is not a valid reference", func() {
+			err := image.ValidateDestinationTags(false, "some-registry.io/some-repo:some-tag", "some-bad-reference::latest")
+			h.AssertNotNil(t, err)
+
+			var tagErr *image.TagValidationError
+			h.AssertEq(t, errors.As(err, &tagErr), true)
+			h.AssertEq(t, tagErr.Tag, "some-bad-reference::latest")
+			h.AssertEq(t, tagErr.Reason, "not a valid image reference")
+			h.AssertNotNil(t, tagErr.Err)
+		})
+
+		when("exporting to a registry", func() {
+			it("errors when tags are on different registries", func() {
+				err := image.ValidateDestinationTags(false, "some-registry.io/some-repo", "some-registry.io/some-other-repo", "some-other-registry.io/some-repo")
+				h.AssertError(t, err, "invalid tag 'some-other-registry.io/some-repo': writing to multiple registries is unsupported (registry 'some-other-registry.io' differs from 'some-registry.io')")
+
+				var tagErr *image.TagValidationError
+				h.AssertEq(t, errors.As(err, &tagErr), true)
+				h.AssertEq(t, tagErr.Tag, "some-other-registry.io/some-repo")
+			})
+		})
+
+		when("exporting to a daemon", func() {
+			it("allows tags on different registries", func() {
+				h.AssertNil(t, image.ValidateDestinationTags(true, "some-registry.io/some-repo", "some-other-registry.io/some-repo"))
+			})
+		})
+	})
+}
@@ -1,12 +1,49 @@
 package image
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
+	"io"
+	"strings"
 
 	"github.com/buildpacks/imgutil"
 	"github.com/pkg/errors"
 )
 
+const (
+	// LabelPrefixBase64 marks a label value that is base64-encoded JSON.
+	LabelPrefixBase64 = "base64:"
+	// LabelPrefixGzip marks a label value that is base64-encoded, gzip-compressed JSON.
+	LabelPrefixGzip = "gzip:"
+	// MaxDecompressedLabelSize is the largest size, in bytes, to which a LabelPrefixGzip label value may decompress;
+	// labels are read from images the lifecycle does not control, so decompression is bounded.
+	MaxDecompressedLabelSize = 16 << 20
+)
+
+// ErrLabelNotFound is returned by DecodeRequiredLabel when the image or the label does not exist.
+var ErrLabelNotFound = errors.New("label not found")
+
+// DecodeRequiredLabel is like DecodeLabel, but returns an error wrapping ErrLabelNotFound
+// when the image does not exist or does not have the label.
+func DecodeRequiredLabel(image imgutil.Image, label string, v interface{}) error {
+	if !image.Found() {
+		return errors.Wrapf(ErrLabelNotFound, "image '%s' not found; cannot read label '%s'", image.Name(), label)
+	}
+	contents, err := image.Label(label)
+	if err != nil {
+		return errors.Wrapf(err, "retrieving label '%s' for image '%s'", label, image.Name())
+	}
+	if contents == "" {
+		return errors.Wrapf(ErrLabelNotFound, "image '%s' does not have label '%s'", image.Name(), label)
+	}
+	return DecodeLabel(image, label, v)
+}
+
+// DecodeLabel unmarshals the JSON value of the provided label into v.
+// Label values prefixed with LabelPrefixBase64 or LabelPrefixGzip are decoded before unmarshalling;
+// any other value is treated as plain JSON.
 func DecodeLabel(image imgutil.Image, label string, v interface{}) error {
 	if !image.Found() {
 		return nil
@@ -18,12 +55,43 @@ func DecodeLabel(image imgutil.Image, label string, v interface{}) error {
 	if contents == "" {
 		return nil
 	}
-	if err := json.Unmarshal([]byte(contents), v); err != nil {
+	decoded, err := decodeLabelValue(contents)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode label '%s'", label)
+	}
+	if err := json.Unmarshal(decoded, v); err != nil {
 		return errors.Wrapf(err, "failed to unmarshal context of label '%s'", label)
 	}
 	return nil
 }
 
+func decodeLabelValue(contents string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(contents, LabelPrefixBase64):
+		return base64.StdEncoding.DecodeString(strings.TrimPrefix(contents, LabelPrefixBase64))
+	case strings.HasPrefix(contents, LabelPrefixGzip):
+		compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(contents, LabelPrefixGzip))
+		if err != nil {
+			return nil, err
+		}
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		decompressed, err := io.ReadAll(io.LimitReader(reader, MaxDecompressedLabelSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(decompressed) > MaxDecompressedLabelSize {
+			return nil, errors.Errorf("decompressed label value exceeds %d bytes", MaxDecompressedLabelSize)
+		}
+		return decompressed, nil
+	default:
+		return []byte(contents), nil
+	}
+}
+
 func SyncLabels(sourceImg imgutil.Image, destImage imgutil.Image, test func(string) bool) error {
 	if err := removeLabels(destImage, test); err != nil {
 		return err
@@ -0,0 +1,126 @@
+package image_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/buildpacks/imgutil/fakes"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/image"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestLabels(t *testing.T) {
+	spec.Run(t, "Labels", testLabels, spec.Report(report.Terminal{}))
+}
+
+func testLabels(t *testing.T, when spec.G, it spec.S) {
+	when(".DecodeRequiredLabel", func() {
+		type metadata struct {
+			Key string `json:"key"`
+		}
+
+		var fakeImage *fakes.Image
+
+		it.Before(func() {
+			fakeImage = fakes.NewImage("some-image", "", nil)
+		})
+
+		it("decodes the label", func() {
+			h.AssertNil(t, fakeImage.SetLabel("some-label", `{"key": "some-value"}`))
+
+			var md metadata
+			h.AssertNil(t, image.DecodeRequiredLabel(fakeImage, "some-label", &md))
+			h.AssertEq(t, md.Key, "some-value")
+		})
+
+		it("returns ErrLabelNotFound when the label is missing", func() {
+			var md metadata
+			err := image.DecodeRequiredLabel(fakeImage, "some-label", &md)
+			h.AssertEq(t, errors.Is(err, image.ErrLabelNotFound), true)
+			h.AssertError(t, err, "image 'some-image' does not have label 'some-label'")
+		})
+	})
+
+	when(".DecodeLabel", func() {
+		type metadata struct {
+			Key string `json:"key"`
+		}
+
+		var fakeImage *fakes.Image
+
+		it.Before(func() {
+			fakeImage = fakes.NewImage("some-image", "", nil)
+		})
+
+		it("decodes plain JSON", func() {
+			h.AssertNil(t, fakeImage.SetLabel("some-label", `{"key": "some-value"}`))
+
+			var md metadata
+			h.AssertNil(t, image.DecodeLabel(fakeImage, "some-label", &md))
+			h.AssertEq(t, md.Key, "some-value")
+		})
+
+		it("decodes base64-encoded JSON", func() {
+			encoded := base64.StdEncoding.EncodeToString([]byte(`{"key": "some-value"}`))
+			h.AssertNil(t, fakeImage.SetLabel("some-label", image.LabelPrefixBase64+encoded))
+
+			var md metadata
+			h.AssertNil(t, image.DecodeLabel(fakeImage, "some-label", &md))
+			h.AssertEq(t, md.Key, "some-value")
+		})
+
+		it("decodes gzip-compressed JSON", func() {
+			var buf bytes.Buffer
+			writer := gzip.NewWriter(&buf)
+			_, err := writer.Write([]byte(`{"key": "some-value"}`))
+			h.AssertNil(t, err)
+			h.AssertNil(t, writer.Close())
+			h.AssertNil(t, fakeImage.SetLabel("some-label", image.LabelPrefixGzip+base64.StdEncoding.EncodeToString(buf.Bytes())))
+
+			var md metadata
+			h.AssertNil(t, image.DecodeLabel(fakeImage, "some-label", &md))
+			h.AssertEq(t, md.Key, "some-value")
+		})
+
+		it("errors when a gzip-compressed value decompresses beyond the size limit", func() {
+			var buf bytes.Buffer
+			writer := gzip.NewWriter(&buf)
+			_, err := writer.Write(make([]byte, image.MaxDecompressedLabelSize+1))
+			h.AssertNil(t, err)
+			h.AssertNil(t, writer.Close())
+			h.AssertNil(t, fakeImage.SetLabel("some-label", image.LabelPrefixGzip+base64.StdEncoding.EncodeToString(buf.Bytes())))
+
+			var md metadata
+			err = image.DecodeLabel(fakeImage, "some-label", &md)
+			h.AssertError(t, err, "decompressed label value exceeds")
+		})
+
+		it("treats an unknown prefix as plain JSON", func() {
+			h.AssertNil(t, fakeImage.SetLabel("some-label", `zstd:some-value`))
+
+			var md metadata
+			err := image.DecodeLabel(fakeImage, "some-label", &md)
+			h.AssertError(t, err, "failed to unmarshal context of label 'some-label'")
+		})
+
+		it("errors when an encoded value is invalid", func() {
+			h.AssertNil(t, fakeImage.SetLabel("some-label", image.LabelPrefixGzip+"not-base64!"))
+
+			var md metadata
+			err := image.DecodeLabel(fakeImage, "some-label", &md)
+			h.AssertError(t, err, "failed to decode label 'some-label'")
+		})
+
+		it("does nothing when the label is missing", func() {
+			var md metadata
+			h.AssertNil(t, image.DecodeLabel(fakeImage, "some-label", &md))
+			h.AssertEq(t, md.Key, "")
+		})
+	})
+}
@@ -32,6 +32,31 @@ func (h *LayoutHandler) Kind() string {
 	return LayoutKind
 }
 
+// Retag saves a copy of the image at srcRef to the layout path of each destination tag.
+func (h *LayoutHandler) Retag(srcRef string, dstRefs ...string) error {
+	if err := ValidateDestinationTags(true, dstRefs...); err != nil {
+		return err
+	}
+	srcPath, err := h.parseRef(srcRef)
+	if err != nil {
+		return err
+	}
+	for _, dstRef := range dstRefs {
+		dstPath, err := h.parseRef(dstRef)
+		if err != nil {
+			return err
+		}
+		img, err := layout.NewImage(dstPath, layout.FromBaseImagePath(srcPath))
+		if err != nil {
+			return err
+		}
+		if err = img.Save(); err != nil {
+			return fmt.Errorf("failed to tag image %s as %s: %w", srcRef, dstRef, err)
+		}
+	}
+	return nil
+}
+
 func (h *LayoutHandler) parseRef(imageRef string) (string, error) {
 	path, err := layout.ParseRefToPath(imageRef)
 	if err != nil {
@@ -42,6 +67,38 @@ func (h *LayoutHandler) parseRef(imageRef string) (string, error) {
 
 // helpers
 
+// LayoutDigest returns the manifest digest of the image stored for imageRef in the OCI layout directory layoutDir,
+// or an empty string if no image for imageRef is present there.
+func LayoutDigest(layoutDir, imageRef string) (string, error) {
+	path, err := (&LayoutHandler{layoutDir: layoutDir}).parseRef(imageRef)
+	if err != nil {
+		return "", err
+	}
+	if _, err = os.Stat(filepath.Join(path, "index.json")); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	layoutPath, err := layout.FromPath(path)
+	if err != nil {
+		return "", err
+	}
+	index, err := layoutPath.ImageIndex()
+	if err != nil {
+		return "", err
+	}
+	indexManifest, err := index.IndexManifest()
+	if err != nil {
+		return "", err
+	}
+	manifests := indexManifest.Manifests
+	if len(manifests) != 1 {
+		return "", fmt.Errorf("expected image index at %q to have only 1 manifest; found %d", path, len(manifests))
+	}
+	return manifests[0].Digest.String(), nil
+}
+
 // FromLayoutPath takes a path to a directory (such as <layers>/extended/run) containing a single image in "sparse" OCI layout format,
 // and returns a v1.Image along with the path of the image (such as <layers>/extended/run/sha256:<sha256>)
 // or an error if the image cannot be loaded.
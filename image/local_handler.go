@@ -1,6 +1,9 @@
 package image
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/local"
 	"github.com/docker/docker/client"
@@ -27,3 +30,16 @@ func (h *LocalHandler) InitImage(imageRef string) (imgutil.Image, error) {
 func (h *LocalHandler) Kind() string {
 	return LocalKind
 }
+
+// Retag adds the destination tags to the image at srcRef in the daemon.
+func (h *LocalHandler) Retag(srcRef string, dstRefs ...string) error {
+	if err := ValidateDestinationTags(true, dstRefs...); err != nil {
+		return err
+	}
+	for _, dstRef := range dstRefs {
+		if err := h.docker.ImageTag(context.Background(), srcRef, dstRef); err != nil {
+			return fmt.Errorf("failed to tag image %s as %s: %w", srcRef, dstRef, err)
+		}
+	}
+	return nil
+}
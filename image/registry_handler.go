@@ -0,0 +1,504 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buildpacks/imgutil/remote"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/log"
+)
+
+// KeychainProvider returns the keychain to use for a registry access check.
+// It is invoked for every check so that short-lived credentials can be refreshed during a long-running process.
+type KeychainProvider func() (authn.Keychain, error)
+
+type DefaultRegistryHandler struct {
+	keychainProvider   KeychainProvider
+	insecureRegistries []string
+	userAgent          string
+	probeTimeout       time.Duration
+	allowedRegistries  []string
+	logger             log.Logger
+}
+
+// RegistryHandlerOption configures a DefaultRegistryHandler.
+type RegistryHandlerOption func(*DefaultRegistryHandler)
+
+// WithLogger sets the logger that the handler reports the errors underlying failed access checks to;
+// by default they are discarded.
+func WithLogger(logger log.Logger) RegistryHandlerOption {
+	return func(rv *DefaultRegistryHandler) {
+		rv.logger = logger
+	}
+}
+
+// WithUserAgent sets the User-Agent sent with the handler's registry access checks (e.g., "cnb-lifecycle/<version>").
+// It only affects the handler's own checks, not images that are loaded or saved elsewhere.
+func WithUserAgent(ua string) RegistryHandlerOption {
+	return func(rv *DefaultRegistryHandler) {
+		rv.userAgent = ua
+	}
+}
+
+// WithProbeTimeout bounds the time taken by each registry access check; when zero (the default), checks are not bounded
+// beyond the timeouts of the underlying HTTP transport.
+// A check that exceeds the timeout reports the image as inaccessible.
+func WithProbeTimeout(timeout time.Duration) RegistryHandlerOption {
+	return func(rv *DefaultRegistryHandler) {
+		rv.probeTimeout = timeout
+	}
+}
+
+// WithAllowedRegistries restricts the registries that may be pushed to; EnsureWriteAccess fails without contacting the registry
+// for references to any other registry. When no registries are provided (the default), pushes to all registries are allowed.
+// Registries are hosts, optionally with a port (e.g., "some-registry.io:5000"); "docker.io" refers to Docker Hub.
+func WithAllowedRegistries(registries ...string) RegistryHandlerOption {
+	return func(rv *DefaultRegistryHandler) {
+		for _, registry := range registries {
+			if reg, err := name.NewRegistry(registry, name.WeakValidation); err == nil {
+				registry = reg.RegistryStr()
+			}
+			rv.allowedRegistries = append(rv.allowedRegistries, registry)
+		}
+	}
+}
+
+func NewRegistryHandler(keychain authn.Keychain, insecureRegistries []string, opts ...RegistryHandlerOption) *DefaultRegistryHandler {
+	return NewRegistryHandlerWithKeychainProvider(staticKeychain(keychain), insecureRegistries, opts...)
+}
+
+func NewRegistryHandlerWithKeychainProvider(keychainProvider KeychainProvider, insecureRegistries []string, opts ...RegistryHandlerOption) *DefaultRegistryHandler {
+	rv := &DefaultRegistryHandler{
+		keychainProvider:   keychainProvider,
+		insecureRegistries: insecureRegistries,
+		logger:             log.NewDefaultLogger(io.Discard),
+	}
+	for _, opt := range opts {
+		opt(rv)
+	}
+	return rv
+}
+
+func staticKeychain(keychain authn.Keychain) KeychainProvider {
+	return func() (authn.Keychain, error) {
+		return keychain, nil
+	}
+}
+
+func (rv *DefaultRegistryHandler) EnsureReadAccess(imageRefs ...string) error {
+	for _, imageRef := range imageRefs {
+		if imageRef == "" {
+			continue
+		}
+		keychain, err := rv.keychainProvider()
+		if err != nil {
+			return errors.Wrapf(err, "get keychain for %s", imageRef)
+		}
+		if canRead, err := rv.checkReadAccess(imageRef, keychain); !canRead {
+			rv.logger.Debugf("Error checking read access: %s", err)
+			if rv.isTimeout(err) {
+				return errors.Errorf("ensure registry read access to %s: timed out after %s", imageRef, rv.probeTimeout)
+			}
+			return errors.Errorf("ensure registry read access to %s", imageRef)
+		}
+	}
+	return nil
+}
+
+// EnsureWriteAccess checks read access before write access so that the returned error indicates which capability is missing.
+// If the handler has allowed registries, every reference is checked against them before any registry is contacted.
+func (rv *DefaultRegistryHandler) EnsureWriteAccess(imageRefs ...string) error {
+	for _, imageRef := range imageRefs {
+		if imageRef == "" {
+			continue
+		}
+		if err := rv.ensurePushAllowed(imageRef); err != nil {
+			return errors.Wrapf(err, "ensure registry read/write access to %s", imageRef)
+		}
+	}
+	for _, imageRef := range imageRefs {
+		if imageRef == "" {
+			continue
+		}
+		keychain, err := rv.keychainProvider()
+		if err != nil {
+			return errors.Wrapf(err, "get keychain for %s", imageRef)
+		}
+		if canRead, err := rv.checkReadAccess(imageRef, keychain); !canRead {
+			rv.logger.Debugf("Error checking read access: %s", err)
+			if rv.isTimeout(err) {
+				return errors.Errorf("ensure registry read/write access to %s: timed out after %s", imageRef, rv.probeTimeout)
+			}
+			return errors.Errorf("ensure registry read/write access to %s: cannot read %s", imageRef, imageRef)
+		}
+		if err = rv.checkWriteAccess(imageRef, keychain); err != nil {
+			rv.logger.Debugf("Error checking write access: %s", err)
+			if rv.isTimeout(err) {
+				return errors.Errorf("ensure registry read/write access to %s: timed out after %s", imageRef, rv.probeTimeout)
+			}
+			return errors.Errorf("ensure registry read/write access to %s: can read but cannot write to %s", imageRef, imageRef)
+		}
+	}
+	return nil
+}
+
+// PreflightSpec names the image references that a build requires access to.
+type PreflightSpec struct {
+	RunImages       []string // require read access
+	CacheImages     []string // require read and write access
+	DestinationTags []string // require write access
+}
+
+// PreflightError lists every failed check of a Preflight.
+type PreflightError struct {
+	Failures []error
+}
+
+func (e *PreflightError) Error() string {
+	var messages []string
+	for _, failure := range e.Failures {
+		messages = append(messages, failure.Error())
+	}
+	return fmt.Sprintf("registry preflight failed for %d image reference(s): %s", len(e.Failures), strings.Join(messages, "; "))
+}
+
+func (e *PreflightError) Unwrap() []error {
+	return e.Failures
+}
+
+// Preflight checks, concurrently, that every reference in the spec has the access it requires,
+// using EnsureReadAccess for run images and EnsureWriteAccess for cache images and destination tags.
+// If any checks fail, it returns a *PreflightError listing the failures in the order of the spec.
+func (rv *DefaultRegistryHandler) Preflight(spec PreflightSpec) error {
+	var checks []func() error
+	for _, imageRef := range spec.RunImages {
+		imageRef := imageRef
+		checks = append(checks, func() error { return rv.EnsureReadAccess(imageRef) })
+	}
+	for _, imageRef := range append(append([]string{}, spec.CacheImages...), spec.DestinationTags...) {
+		imageRef := imageRef
+		checks = append(checks, func() error { return rv.EnsureWriteAccess(imageRef) })
+	}
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for idx, check := range checks {
+		wg.Add(1)
+		go func(idx int, check func() error) {
+			defer wg.Done()
+			errs[idx] = check()
+		}(idx, check)
+	}
+	wg.Wait()
+
+	var failures []error
+	for _, err := range errs {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) > 0 {
+		return &PreflightError{Failures: failures}
+	}
+	return nil
+}
+
+// AccessResult describes the registry access available for an image reference.
+type AccessResult struct {
+	Ref      string
+	CanRead  bool
+	CanWrite bool
+	// Err is the error that prevented read or write access, if any.
+	Err error
+}
+
+// ReportAccess checks read and write access for each of the provided image references.
+// Unlike EnsureReadAccess and EnsureWriteAccess, it does not stop at the first failure; empty references are skipped.
+// Write access is only checked when the reference can be read.
+func (rv *DefaultRegistryHandler) ReportAccess(imageRefs ...string) []AccessResult {
+	return rv.reportAccess(imageRefs, true)
+}
+
+// AccessRequest groups image references by the registry access they require.
+type AccessRequest struct {
+	Read  []string // e.g., the run image or previous image
+	Write []string // e.g., the cache image or destination tags
+}
+
+// AccessReport lists the outcome of checking each reference in an AccessRequest.
+type AccessReport struct {
+	Read  []AccessResult
+	Write []AccessResult
+}
+
+// Passed returns true if every reference has the access it requires.
+func (r AccessReport) Passed() bool {
+	for _, result := range r.Read {
+		if !result.CanRead {
+			return false
+		}
+	}
+	for _, result := range r.Write {
+		if !result.CanWrite {
+			return false
+		}
+	}
+	return true
+}
+
+// Analyze checks every reference in the request for the access it requires and reports all outcomes without failing on the first.
+// Write access is not checked for references that only require read access.
+func (rv *DefaultRegistryHandler) Analyze(request AccessRequest) AccessReport {
+	return AccessReport{
+		Read:  rv.reportAccess(request.Read, false),
+		Write: rv.reportAccess(request.Write, true),
+	}
+}
+
+func (rv *DefaultRegistryHandler) reportAccess(imageRefs []string, checkWrite bool) []AccessResult {
+	var results []AccessResult
+	for _, imageRef := range imageRefs {
+		if imageRef == "" {
+			continue
+		}
+		result := AccessResult{Ref: imageRef}
+		keychain, err := rv.keychainProvider()
+		if err != nil {
+			result.Err = errors.Wrapf(err, "get keychain for %s", imageRef)
+			results = append(results, result)
+			continue
+		}
+		if result.CanRead, result.Err = rv.checkReadAccess(imageRef, keychain); result.CanRead {
+			if checkWrite {
+				if result.Err = rv.ensurePushAllowed(imageRef); result.Err == nil {
+					result.Err = rv.checkWriteAccess(imageRef, keychain)
+				}
+				result.CanWrite = result.Err == nil
+			}
+		} else if result.Err == nil {
+			result.Err = errors.Errorf("cannot read %s", imageRef)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// CheckReadAccess returns true if the image can be read, or if the registry responds with an error other than 401 or 403
+// (e.g., because the image does not exist), as imgutil's remote.Image.CheckReadAccess does.
+// Unlike EnsureReadAccess, it returns the underlying error when the image cannot be read.
+func (rv *DefaultRegistryHandler) CheckReadAccess(imageRef string) (bool, error) {
+	keychain, err := rv.keychainProvider()
+	if err != nil {
+		return false, errors.Wrapf(err, "get keychain for %s", imageRef)
+	}
+	return rv.checkReadAccess(imageRef, keychain)
+}
+
+// CheckReadAccessWithKeychain is like CheckReadAccess, but uses the provided keychain instead of the handler's.
+func (rv *DefaultRegistryHandler) CheckReadAccessWithKeychain(imageRef string, keychain authn.Keychain) (bool, error) {
+	return rv.checkReadAccess(imageRef, keychain)
+}
+
+// checkReadAccess returns true if the image can be read, or if the registry responds with an error other than 401 or 403.
+// Errors that are not registry responses (e.g., connection failures) are reported as inaccessible.
+func (rv *DefaultRegistryHandler) checkReadAccess(imageRef string, keychain authn.Keychain) (bool, error) {
+	ref, insecure, err := rv.referenceFor(imageRef)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := rv.probeContext()
+	defer cancel()
+	_, err = ggcrremote.Head(ref,
+		ggcrremote.WithAuthFromKeychain(keychain),
+		ggcrremote.WithContext(ctx),
+		ggcrremote.WithTransport(rv.transportFor(ctx, insecure)),
+		ggcrremote.WithUserAgent(rv.userAgent),
+	)
+	if err == nil {
+		return true, nil
+	}
+	var transportErr *transport.Error
+	if errors.As(err, &transportErr) &&
+		transportErr.StatusCode != http.StatusUnauthorized &&
+		transportErr.StatusCode != http.StatusForbidden {
+		return true, nil
+	}
+	return false, rv.timeoutErr(ctx, err)
+}
+
+func (rv *DefaultRegistryHandler) checkWriteAccess(imageRef string, keychain authn.Keychain) error {
+	ref, insecure, err := rv.referenceFor(imageRef)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := rv.probeContext()
+	defer cancel()
+	rt := rv.transportFor(ctx, insecure)
+	if rv.userAgent != "" {
+		// CheckPushPermission accepts a transport rather than options, so apply the user agent as ggcrremote.WithUserAgent would
+		rt = transport.NewUserAgent(rt, rv.userAgent)
+	}
+	return rv.timeoutErr(ctx, ggcrremote.CheckPushPermission(ref, keychain, rt))
+}
+
+// ensurePushAllowed returns an error if the handler has allowed registries and the registry of the provided reference is not one of them.
+func (rv *DefaultRegistryHandler) ensurePushAllowed(imageRef string) error {
+	if len(rv.allowedRegistries) == 0 {
+		return nil
+	}
+	ref, err := name.ParseReference(imageRef, name.WeakValidation)
+	if err != nil {
+		return err
+	}
+	registry := ref.Context().RegistryStr()
+	for _, allowed := range rv.allowedRegistries {
+		if registry == allowed {
+			return nil
+		}
+	}
+	return errors.Errorf("pushes to %s are not permitted", registry)
+}
+
+func (rv *DefaultRegistryHandler) probeContext() (context.Context, context.CancelFunc) {
+	if rv.probeTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), rv.probeTimeout)
+}
+
+// timeoutErr returns an error wrapping context.DeadlineExceeded if the probe context expired, otherwise it returns err.
+func (rv *DefaultRegistryHandler) timeoutErr(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("registry access check timed out after %s: %w", rv.probeTimeout, context.DeadlineExceeded)
+	}
+	return err
+}
+
+func (rv *DefaultRegistryHandler) isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+func (rv *DefaultRegistryHandler) referenceFor(imageRef string) (name.Reference, bool, error) {
+	return parseReference(imageRef, rv.insecureRegistries)
+}
+
+func (rv *DefaultRegistryHandler) transportFor(ctx context.Context, insecure bool) http.RoundTripper {
+	var rt http.RoundTripper = http.DefaultTransport
+	if insecure {
+		rt = insecureTransport()
+	}
+	return &contextTransport{inner: rt, ctx: ctx}
+}
+
+// parseReference parses imageRef, allowing plain HTTP if its registry is one of the insecure registries;
+// it also returns whether the registry is insecure.
+func parseReference(imageRef string, insecureRegistries []string) (name.Reference, bool, error) {
+	ref, err := name.ParseReference(imageRef, name.WeakValidation)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, insecureRegistry := range insecureRegistries {
+		if ref.Context().RegistryStr() == insecureRegistry {
+			ref, err = name.ParseReference(imageRef, name.WeakValidation, name.Insecure)
+			return ref, true, err
+		}
+	}
+	return ref, false, nil
+}
+
+// insecureTransport returns a transport that does not verify the TLS certificates of registries.
+func insecureTransport() http.RoundTripper {
+	rt := http.DefaultTransport.(*http.Transport).Clone()
+	rt.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // #nosec G402
+	return rt
+}
+
+// contextTransport applies the provided context to every request,
+// so that requests made by library functions that do not accept a context (e.g., remote.CheckPushPermission) are still bounded.
+type contextTransport struct {
+	inner http.RoundTripper
+	ctx   context.Context
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.inner.RoundTrip(req.WithContext(t.ctx))
+}
+
+// GetInsecureOptions returns the image options that allow plain HTTP and unverified TLS connections to the provided registries.
+func GetInsecureOptions(insecureRegistries []string) []remote.ImageOption {
+	var opts []remote.ImageOption
+	for _, insecureRegistry := range insecureRegistries {
+		opts = append(opts, remote.WithRegistrySetting(insecureRegistry, true, true))
+	}
+	return opts
+}
+
+// LoadInsecureRegistries reads a list of insecure registries from the file at the provided path.
+// The file may contain either a JSON array of registry hosts, or one registry host per line;
+// in the latter case blank lines and lines beginning with '#' are ignored.
+func LoadInsecureRegistries(path string) ([]string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(contents)
+	registries := []string{}
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var entries []string
+		if err = json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse insecure registries file '%s': %w", path, err)
+		}
+		for idx, entry := range entries {
+			entry = strings.TrimSpace(entry)
+			if !isRegistryHost(entry) {
+				return nil, fmt.Errorf("invalid insecure registry '%s' at entry %d of '%s'", entry, idx+1, path)
+			}
+			registries = append(registries, entry)
+		}
+		return registries, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !isRegistryHost(line) {
+			return nil, fmt.Errorf("invalid insecure registry '%s' on line %d of '%s'", line, lineNum, path)
+		}
+		registries = append(registries, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return registries, nil
+}
+
+// isRegistryHost returns true if the provided string is a bare host, optionally with a port (e.g., "some-registry.io:5000").
+func isRegistryHost(s string) bool {
+	if s == "" || strings.ContainsAny(s, "/ \t") {
+		return false
+	}
+	u, err := url.Parse("//" + s)
+	if err != nil {
+		return false
+	}
+	return u.Host == s && u.Hostname() != ""
+}
@@ -0,0 +1,598 @@
+package image_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	apexlog "github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/image"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestRegistryHandler(t *testing.T) {
+	spec.Run(t, "RegistryHandler", testRegistryHandler, spec.Sequential(), spec.Report(report.Terminal{}))
+}
+
+func testRegistryHandler(t *testing.T, when spec.G, it spec.S) {
+	when("#EnsureReadAccess and #EnsureWriteAccess", func() {
+		var (
+			server          *httptest.Server
+			registryHost    string
+			registryHandler *image.DefaultRegistryHandler
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/some-repo:some-tag")
+			registryHandler = image.NewRegistryHandler(authn.DefaultKeychain, nil)
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("succeeds when the image is accessible", func() {
+			h.AssertNil(t, registryHandler.EnsureReadAccess(registryHost+"/some-repo:some-tag"))
+			h.AssertNil(t, registryHandler.EnsureWriteAccess(registryHost+"/some-repo:some-tag"))
+		})
+
+		it("ignores empty references", func() {
+			h.AssertNil(t, registryHandler.EnsureReadAccess(""))
+			h.AssertNil(t, registryHandler.EnsureWriteAccess(""))
+		})
+
+		it("errors when the image cannot be read", func() {
+			err := registryHandler.EnsureReadAccess(registryHost+"/some-repo:some-tag", registryHost+"/unauthorized-repo:some-tag")
+			h.AssertError(t, err, "ensure registry read access to "+registryHost+"/unauthorized-repo:some-tag")
+		})
+
+		it("logs the error underlying a failed check to the provided logger", func() {
+			logHandler := memory.New()
+			registryHandler = image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithLogger(&apexlog.Logger{Handler: logHandler}))
+
+			h.AssertNotNil(t, registryHandler.EnsureReadAccess(registryHost+"/unauthorized-repo:some-tag"))
+			h.AssertEq(t, len(logHandler.Entries), 1)
+			h.AssertStringContains(t, logHandler.Entries[0].Message, "Error checking read access")
+		})
+
+		when("write access is required", func() {
+			it("errors when the image cannot be read", func() {
+				imageRef := registryHost + "/unauthorized-repo:some-tag"
+				err := registryHandler.EnsureWriteAccess(imageRef)
+				h.AssertError(t, err, "ensure registry read/write access to "+imageRef+": cannot read "+imageRef)
+			})
+
+			it("errors when the image can be read but not written", func() {
+				imageRef := registryHost + "/read-only-repo:some-tag"
+				pushRandomImage(t, imageRef)
+				err := registryHandler.EnsureWriteAccess(imageRef)
+				h.AssertError(t, err, "ensure registry read/write access to "+imageRef+": can read but cannot write to "+imageRef)
+			})
+		})
+	})
+
+	when("#CheckReadAccess", func() {
+		var (
+			server          *httptest.Server
+			registryHost    string
+			registryHandler *image.DefaultRegistryHandler
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/some-repo:some-tag")
+			registryHandler = image.NewRegistryHandler(authn.DefaultKeychain, nil)
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("returns true when the image can be read", func() {
+			canRead, err := registryHandler.CheckReadAccess(registryHost + "/some-repo:some-tag")
+			h.AssertNil(t, err)
+			h.AssertEq(t, canRead, true)
+		})
+
+		it("returns true when the image does not exist", func() {
+			canRead, err := registryHandler.CheckReadAccess(registryHost + "/some-repo:missing-tag")
+			h.AssertNil(t, err)
+			h.AssertEq(t, canRead, true)
+		})
+
+		it("returns true when the registry responds with a server error", func() {
+			canRead, err := registryHandler.CheckReadAccess(registryHost + "/broken-repo:some-tag")
+			h.AssertNil(t, err)
+			h.AssertEq(t, canRead, true)
+		})
+
+		it("returns false when the registry denies access", func() {
+			canRead, err := registryHandler.CheckReadAccess(registryHost + "/unauthorized-repo:some-tag")
+			h.AssertNotNil(t, err)
+			h.AssertEq(t, canRead, false)
+		})
+
+		it("returns false when the registry cannot be reached", func() {
+			server.Close()
+
+			canRead, err := registryHandler.CheckReadAccess(registryHost + "/some-repo:some-tag")
+			h.AssertNotNil(t, err)
+			h.AssertEq(t, canRead, false)
+		})
+	})
+
+	when("#WithProbeTimeout", func() {
+		var (
+			server       *httptest.Server
+			registryHost string
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/some-repo:some-tag")
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("errors when a check exceeds the timeout", func() {
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithProbeTimeout(100*time.Millisecond))
+
+			start := time.Now()
+			err := registryHandler.EnsureReadAccess(registryHost + "/slow-repo:some-tag")
+			h.AssertError(t, err, "ensure registry read access to "+registryHost+"/slow-repo:some-tag: timed out after 100ms")
+			h.AssertEq(t, time.Since(start) < 5*time.Second, true)
+
+			err = registryHandler.EnsureWriteAccess(registryHost + "/slow-repo:some-tag")
+			h.AssertError(t, err, "ensure registry read/write access to "+registryHost+"/slow-repo:some-tag: timed out after 100ms")
+		})
+
+		it("reports a timeout error", func() {
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithProbeTimeout(100*time.Millisecond))
+
+			results := registryHandler.ReportAccess(registryHost+"/slow-repo:some-tag", registryHost+"/some-repo:some-tag")
+			h.AssertEq(t, results[0].CanRead, false)
+			h.AssertEq(t, errors.Is(results[0].Err, context.DeadlineExceeded), true)
+			h.AssertEq(t, results[1].CanWrite, true)
+		})
+	})
+
+	when("#WithAllowedRegistries", func() {
+		var (
+			server       *httptest.Server
+			registryHost string
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/some-repo:some-tag")
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("allows pushes to allowed registries", func() {
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithAllowedRegistries("some-other-registry.io", registryHost))
+
+			h.AssertNil(t, registryHandler.EnsureWriteAccess(registryHost+"/some-repo:some-tag"))
+		})
+
+		it("errors for other registries before contacting any registry", func() {
+			var requests int
+			countingServer := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				requests++
+				server.Config.Handler.ServeHTTP(resp, req)
+			}))
+			defer countingServer.Close()
+			countingURL, err := url.Parse(countingServer.URL)
+			h.AssertNil(t, err)
+
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithAllowedRegistries(countingURL.Host))
+
+			err = registryHandler.EnsureWriteAccess(countingURL.Host+"/some-repo:some-tag", "some-other-registry.io/some-repo:some-tag")
+			h.AssertError(t, err, "ensure registry read/write access to some-other-registry.io/some-repo:some-tag: pushes to some-other-registry.io are not permitted")
+			h.AssertEq(t, requests, 0)
+		})
+
+		it("matches Docker Hub references", func() {
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithAllowedRegistries(registryHost))
+
+			err := registryHandler.EnsureWriteAccess("some-org/some-repo:some-tag")
+			h.AssertError(t, err, "pushes to index.docker.io are not permitted")
+		})
+
+		it("does not restrict read access", func() {
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithAllowedRegistries("some-other-registry.io"))
+
+			h.AssertNil(t, registryHandler.EnsureReadAccess(registryHost+"/some-repo:some-tag"))
+			results := registryHandler.ReportAccess(registryHost + "/some-repo:some-tag")
+			h.AssertEq(t, results[0].CanRead, true)
+			h.AssertEq(t, results[0].CanWrite, false)
+			h.AssertError(t, results[0].Err, "pushes to "+registryHost+" are not permitted")
+		})
+
+		it("allows pushes to all registries by default", func() {
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithAllowedRegistries())
+
+			h.AssertNil(t, registryHandler.EnsureWriteAccess(registryHost+"/some-repo:some-tag"))
+		})
+	})
+
+	when("#WithUserAgent", func() {
+		it("sends the user agent with access checks", func() {
+			var (
+				userAgents []string
+				reg        = registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+				if !strings.HasPrefix(req.UserAgent(), "go-containerregistry-test") {
+					userAgents = append(userAgents, req.UserAgent())
+				}
+				reg.ServeHTTP(resp, req)
+			}))
+			defer server.Close()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			imageRef := serverURL.Host + "/some-repo:some-tag"
+			pushRandomImage(t, imageRef)
+
+			registryHandler := image.NewRegistryHandler(authn.DefaultKeychain, nil, image.WithUserAgent("cnb-lifecycle/some-version"))
+			h.AssertNil(t, registryHandler.EnsureWriteAccess(imageRef))
+
+			h.AssertEq(t, len(userAgents) > 0, true)
+			for _, userAgent := range userAgents {
+				h.AssertStringContains(t, userAgent, "cnb-lifecycle/some-version")
+				// read and write checks identify themselves the same way
+				h.AssertEq(t, userAgent, userAgents[0])
+			}
+		})
+	})
+
+	when("#NewRegistryHandlerWithKeychainProvider", func() {
+		var (
+			server       *httptest.Server
+			registryHost string
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/private-repo:some-tag")
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("fetches a fresh keychain for every check", func() {
+			password := "expired-password"
+			registryHandler := image.NewRegistryHandlerWithKeychainProvider(func() (authn.Keychain, error) {
+				return &fakeKeychain{username: "some-user", password: password}, nil
+			}, nil)
+
+			h.AssertNotNil(t, registryHandler.EnsureReadAccess(registryHost+"/private-repo:some-tag"))
+
+			password = "current-password"
+			h.AssertNil(t, registryHandler.EnsureReadAccess(registryHost+"/private-repo:some-tag"))
+		})
+
+		it("errors when a keychain cannot be provided", func() {
+			registryHandler := image.NewRegistryHandlerWithKeychainProvider(func() (authn.Keychain, error) {
+				return nil, errors.New("some-error")
+			}, nil)
+
+			err := registryHandler.EnsureReadAccess(registryHost + "/private-repo:some-tag")
+			h.AssertError(t, err, "get keychain for "+registryHost+"/private-repo:some-tag: some-error")
+		})
+	})
+
+	when("#ReportAccess", func() {
+		var (
+			server          *httptest.Server
+			registryHost    string
+			registryHandler *image.DefaultRegistryHandler
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/some-repo:some-tag")
+			pushRandomImage(t, registryHost+"/read-only-repo:some-tag")
+			registryHandler = image.NewRegistryHandler(authn.DefaultKeychain, nil)
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("reports access for every reference", func() {
+			results := registryHandler.ReportAccess(
+				registryHost+"/unauthorized-repo:some-tag",
+				"",
+				registryHost+"/read-only-repo:some-tag",
+				registryHost+"/some-repo:some-tag",
+			)
+			h.AssertEq(t, len(results), 3)
+
+			h.AssertEq(t, results[0].Ref, registryHost+"/unauthorized-repo:some-tag")
+			h.AssertEq(t, results[0].CanRead, false)
+			h.AssertEq(t, results[0].CanWrite, false)
+			h.AssertNotNil(t, results[0].Err)
+
+			h.AssertEq(t, results[1].Ref, registryHost+"/read-only-repo:some-tag")
+			h.AssertEq(t, results[1].CanRead, true)
+			h.AssertEq(t, results[1].CanWrite, false)
+			h.AssertNotNil(t, results[1].Err)
+
+			h.AssertEq(t, results[2].Ref, registryHost+"/some-repo:some-tag")
+			h.AssertEq(t, results[2].CanRead, true)
+			h.AssertEq(t, results[2].CanWrite, true)
+			h.AssertNil(t, results[2].Err)
+		})
+	})
+
+	when("#Preflight", func() {
+		var (
+			server          *httptest.Server
+			registryHost    string
+			registryHandler *image.DefaultRegistryHandler
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/some-repo:some-tag")
+			pushRandomImage(t, registryHost+"/read-only-repo:some-tag")
+			registryHandler = image.NewRegistryHandler(authn.DefaultKeychain, nil)
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("passes when every reference has the access it requires", func() {
+			err := registryHandler.Preflight(image.PreflightSpec{
+				RunImages:       []string{registryHost + "/read-only-repo:some-tag"},
+				CacheImages:     []string{registryHost + "/some-repo:some-cache-tag"},
+				DestinationTags: []string{registryHost + "/some-repo:some-tag", registryHost + "/some-repo:other-tag"},
+			})
+			h.AssertNil(t, err)
+		})
+
+		it("reports every failing reference in one error", func() {
+			err := registryHandler.Preflight(image.PreflightSpec{
+				RunImages:       []string{registryHost + "/unauthorized-repo:some-tag", registryHost + "/some-repo:some-tag"},
+				CacheImages:     []string{registryHost + "/read-only-repo:some-cache-tag"},
+				DestinationTags: []string{registryHost + "/some-repo:some-tag", registryHost + "/read-only-repo:some-tag"},
+			})
+
+			var preflightErr *image.PreflightError
+			h.AssertEq(t, errors.As(err, &preflightErr), true)
+			h.AssertEq(t, len(preflightErr.Failures), 3)
+			h.AssertError(t, preflightErr.Failures[0], "ensure registry read access to "+registryHost+"/unauthorized-repo:some-tag")
+			h.AssertError(t, preflightErr.Failures[1], "can read but cannot write to "+registryHost+"/read-only-repo:some-cache-tag")
+			h.AssertError(t, preflightErr.Failures[2], "can read but cannot write to "+registryHost+"/read-only-repo:some-tag")
+			h.AssertError(t, err, "registry preflight failed for 3 image reference(s)")
+		})
+	})
+
+	when("#Analyze", func() {
+		var (
+			server          *httptest.Server
+			registryHost    string
+			registryHandler *image.DefaultRegistryHandler
+		)
+
+		it.Before(func() {
+			server = newFakeRegistry()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			pushRandomImage(t, registryHost+"/some-repo:some-tag")
+			pushRandomImage(t, registryHost+"/read-only-repo:some-tag")
+			registryHandler = image.NewRegistryHandler(authn.DefaultKeychain, nil)
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("passes when every reference has the access it requires", func() {
+			report := registryHandler.Analyze(image.AccessRequest{
+				Read:  []string{registryHost + "/read-only-repo:some-tag"},
+				Write: []string{registryHost + "/some-repo:some-tag"},
+			})
+			h.AssertEq(t, report.Passed(), true)
+			h.AssertEq(t, len(report.Read), 1)
+			h.AssertEq(t, report.Read[0].CanRead, true)
+			h.AssertEq(t, report.Read[0].CanWrite, false)
+			h.AssertNil(t, report.Read[0].Err)
+			h.AssertEq(t, len(report.Write), 1)
+			h.AssertEq(t, report.Write[0].CanWrite, true)
+		})
+
+		it("reports every failing reference", func() {
+			report := registryHandler.Analyze(image.AccessRequest{
+				Read:  []string{registryHost + "/unauthorized-repo:some-tag", registryHost + "/some-repo:some-tag"},
+				Write: []string{registryHost + "/read-only-repo:some-tag", registryHost + "/some-repo:some-tag"},
+			})
+			h.AssertEq(t, report.Passed(), false)
+			h.AssertEq(t, report.Read[0].CanRead, false)
+			h.AssertEq(t, report.Read[1].CanRead, true)
+			h.AssertEq(t, report.Write[0].CanRead, true)
+			h.AssertEq(t, report.Write[0].CanWrite, false)
+			h.AssertEq(t, report.Write[1].CanWrite, true)
+		})
+	})
+
+	when(".GetInsecureOptions", func() {
+		it("returns an option for each registry", func() {
+			h.AssertEq(t, len(image.GetInsecureOptions([]string{"some-registry.io", "some-other-registry.io:5000"})), 2)
+		})
+
+		it("returns no options when no registries are provided", func() {
+			h.AssertEq(t, len(image.GetInsecureOptions(nil)), 0)
+		})
+	})
+
+	when(".LoadInsecureRegistries", func() {
+		var (
+			tmpDir string
+			path   string
+		)
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = os.MkdirTemp("", "insecure-registries")
+			h.AssertNil(t, err)
+			path = filepath.Join(tmpDir, "insecure-registries")
+		})
+
+		it.After(func() {
+			_ = os.RemoveAll(tmpDir)
+		})
+
+		it("reads one registry per line ignoring comments and blank lines", func() {
+			h.Mkfile(t, "# some comment\nsome-registry.io\n\n  some-other-registry.io:5000  \n", path)
+
+			registries, err := image.LoadInsecureRegistries(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, registries, []string{"some-registry.io", "some-other-registry.io:5000"})
+		})
+
+		it("reads a JSON list", func() {
+			h.Mkfile(t, `["some-registry.io", "some-other-registry.io:5000"]`, path)
+
+			registries, err := image.LoadInsecureRegistries(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, registries, []string{"some-registry.io", "some-other-registry.io:5000"})
+		})
+
+		it("returns an empty list for an empty file", func() {
+			h.Mkfile(t, "", path)
+
+			registries, err := image.LoadInsecureRegistries(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, registries, []string{})
+		})
+
+		it("errors with the line of an invalid entry", func() {
+			h.Mkfile(t, "some-registry.io\nhttps://some-other-registry.io/some-repo\n", path)
+
+			_, err := image.LoadInsecureRegistries(path)
+			h.AssertError(t, err, "invalid insecure registry 'https://some-other-registry.io/some-repo' on line 2")
+		})
+
+		it("errors with the index of an invalid JSON entry", func() {
+			h.Mkfile(t, `["some-registry.io", "some-registry.io/some-repo"]`, path)
+
+			_, err := image.LoadInsecureRegistries(path)
+			h.AssertError(t, err, "invalid insecure registry 'some-registry.io/some-repo' at entry 2")
+		})
+
+		it("errors when the file does not exist", func() {
+			_, err := image.LoadInsecureRegistries(filepath.Join(tmpDir, "missing"))
+			h.AssertNotNil(t, err)
+		})
+	})
+}
+
+// newFakeRegistry returns an in-memory registry that denies all requests for repositories with an "unauthorized-" prefix,
+// denies blob uploads for repositories with a "read-only-" prefix, requires the "current-password" credentials
+// for repositories with a "private-" prefix (unless the request is made by the test itself),
+// and does not respond to requests for repositories with a "slow-" prefix until the client gives up.
+func newFakeRegistry() *httptest.Server {
+	reg := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	return httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		isTestRequest := strings.HasPrefix(req.UserAgent(), "go-containerregistry-test")
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/v2/unauthorized-"):
+			resp.WriteHeader(http.StatusUnauthorized)
+			return
+		case strings.HasPrefix(req.URL.Path, "/v2/broken-"):
+			resp.WriteHeader(http.StatusNotImplemented)
+			return
+		case strings.HasPrefix(req.URL.Path, "/v2/slow-"):
+			select {
+			case <-req.Context().Done():
+			case <-time.After(10 * time.Second):
+			}
+			return
+		case strings.HasPrefix(req.URL.Path, "/v2/private-") && !isTestRequest:
+			if _, password, ok := req.BasicAuth(); !ok || password != "current-password" {
+				resp.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		case strings.HasPrefix(req.URL.Path, "/v2/read-only-") &&
+			req.Method == http.MethodPost &&
+			!isTestRequest:
+			resp.WriteHeader(http.StatusForbidden)
+			return
+		}
+		reg.ServeHTTP(resp, req)
+	}))
+}
+
+type fakeKeychain struct {
+	username, password string
+}
+
+func (k *fakeKeychain) Resolve(authn.Resource) (authn.Authenticator, error) {
+	return authn.FromConfig(authn.AuthConfig{Username: k.username, Password: k.password}), nil
+}
+
+func pushRandomImage(t *testing.T, imageRef string) {
+	t.Helper()
+	img, err := random.Image(1024, 1)
+	h.AssertNil(t, err)
+	ref, err := name.ParseReference(imageRef, name.WeakValidation)
+	h.AssertNil(t, err)
+	h.AssertNil(t, ggcrremote.Write(ref, img, ggcrremote.WithUserAgent("go-containerregistry-test")))
+}
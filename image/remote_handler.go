@@ -1,15 +1,20 @@
 package image
 
 import (
+	"fmt"
+
 	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/remote"
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
 const RemoteKind = "remote"
 
 type RemoteHandler struct {
-	keychain authn.Keychain
+	keychain           authn.Keychain
+	insecureRegistries []string
 }
 
 func (h *RemoteHandler) InitImage(imageRef string) (imgutil.Image, error) {
@@ -17,13 +22,68 @@ func (h *RemoteHandler) InitImage(imageRef string) (imgutil.Image, error) {
 		return nil, nil
 	}
 
+	options := []remote.ImageOption{remote.FromBaseImage(imageRef)}
+	options = append(options, GetInsecureOptions(h.insecureRegistries)...)
 	return remote.NewImage(
 		imageRef,
 		h.keychain,
-		remote.FromBaseImage(imageRef),
+		options...,
 	)
 }
 
 func (h *RemoteHandler) Kind() string {
 	return RemoteKind
 }
+
+// Retag adds the destination tags to the image at srcRef in the registry; the destination tags must be on the same registry as srcRef.
+// Tags in the same repository as srcRef point to the existing manifest.
+// For tags in other repositories, the manifest is written to the destination repository
+// and the layers are mounted from the source repository rather than uploaded again,
+// unless the registry does not support cross-repository mounts.
+func (h *RemoteHandler) Retag(srcRef string, dstRefs ...string) error {
+	if err := ValidateDestinationTags(false, append([]string{srcRef}, dstRefs...)...); err != nil {
+		return err
+	}
+	src, insecure, err := parseReference(srcRef, h.insecureRegistries)
+	if err != nil {
+		return err
+	}
+	opts := []ggcrremote.Option{ggcrremote.WithAuthFromKeychain(h.keychain)}
+	if insecure {
+		opts = append(opts, ggcrremote.WithTransport(insecureTransport()))
+	}
+	desc, err := ggcrremote.Get(src, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to get image %s: %w", srcRef, err)
+	}
+	for _, dstRef := range dstRefs {
+		ref, _, err := parseReference(dstRef, h.insecureRegistries)
+		if err != nil {
+			return err
+		}
+		dst, ok := ref.(name.Tag)
+		if !ok {
+			return fmt.Errorf("failed to tag image %s as %s: not a tag", srcRef, dstRef)
+		}
+		if dst.Context().Name() == src.Context().Name() {
+			err = ggcrremote.Tag(dst, desc, opts...)
+		} else {
+			err = writeMounted(dst, desc, opts...)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to tag image %s as %s: %w", srcRef, dstRef, err)
+		}
+	}
+	return nil
+}
+
+// writeMounted writes the image described by desc to dst.
+// The layers of an image read with ggcrremote are ggcrremote.MountableLayers, which ggcrremote.Write mounts
+// from their source repository when it is on the same registry as dst.
+func writeMounted(dst name.Tag, desc *ggcrremote.Descriptor, opts ...ggcrremote.Option) error {
+	img, err := desc.Image()
+	if err != nil {
+		return err
+	}
+	return ggcrremote.Write(dst, img, opts...)
+}
@@ -1,9 +1,21 @@
 package image_test
 
 import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -59,5 +71,165 @@ func testRemoteImageHandler(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 		})
+
+		when("#Retag", func() {
+			var (
+				retagger     image.Retagger
+				server       *httptest.Server
+				registryHost string
+				srcRef       string
+				srcImage     v1.Image
+				srcDigest    v1.Hash
+				mounted      []string
+				uploaded     []string
+			)
+
+			it.Before(func() {
+				var ok bool
+				retagger, ok = imageHandler.(image.Retagger)
+				h.AssertEq(t, ok, true)
+
+				mounted, uploaded = nil, nil
+				fakeRegistry := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					// the fake registry stores blobs across repositories and does not support mounts,
+					// so report blobs as missing from and mountable into some-other-repo
+					if strings.HasPrefix(r.URL.Path, "/v2/some-other-repo/blobs/") {
+						switch {
+						case r.Method == http.MethodHead:
+							w.WriteHeader(http.StatusNotFound)
+							return
+						case r.Method == http.MethodPost && r.URL.Query().Get("from") == "some-repo":
+							mounted = append(mounted, r.URL.Query().Get("mount"))
+							w.WriteHeader(http.StatusCreated)
+							return
+						}
+					}
+					if r.Method == http.MethodPut && r.URL.Query().Get("digest") != "" {
+						uploaded = append(uploaded, r.URL.Query().Get("digest"))
+					}
+					fakeRegistry.ServeHTTP(w, r)
+				}))
+				serverURL, err := url.Parse(server.URL)
+				h.AssertNil(t, err)
+				registryHost = serverURL.Host
+
+				srcRef = registryHost + "/some-repo:some-tag"
+				srcImage, err = random.Image(1024, 2)
+				h.AssertNil(t, err)
+				srcDigest, err = srcImage.Digest()
+				h.AssertNil(t, err)
+				ref, err := name.ParseReference(srcRef, name.WeakValidation)
+				h.AssertNil(t, err)
+				h.AssertNil(t, ggcrremote.Write(ref, srcImage))
+				uploaded = nil
+			})
+
+			it.After(func() {
+				server.Close()
+			})
+
+			digestOf := func(imageRef string) v1.Hash {
+				ref, err := name.ParseReference(imageRef, name.WeakValidation)
+				h.AssertNil(t, err)
+				desc, err := ggcrremote.Get(ref)
+				h.AssertNil(t, err)
+				return desc.Digest
+			}
+
+			it("tags the image in the same repository", func() {
+				dstRef := registryHost + "/some-repo:some-other-tag"
+
+				h.AssertNil(t, retagger.Retag(srcRef, dstRef))
+
+				h.AssertEq(t, digestOf(dstRef), srcDigest)
+				h.AssertEq(t, len(uploaded), 0)
+			})
+
+			it("mounts the layers into another repository without uploading them", func() {
+				dstRef := registryHost + "/some-other-repo:some-tag"
+
+				h.AssertNil(t, retagger.Retag(srcRef, dstRef))
+
+				h.AssertEq(t, digestOf(dstRef), srcDigest)
+				layers, err := srcImage.Layers()
+				h.AssertNil(t, err)
+				configName, err := srcImage.ConfigName()
+				h.AssertNil(t, err)
+				expected := []string{configName.String()}
+				for _, layer := range layers {
+					digest, err := layer.Digest()
+					h.AssertNil(t, err)
+					expected = append(expected, digest.String())
+				}
+				sort.Strings(expected)
+				sort.Strings(mounted)
+				h.AssertEq(t, mounted, expected)
+				h.AssertEq(t, len(uploaded), 0)
+			})
+
+			it("errors when the destination tags are on a different registry", func() {
+				err := retagger.Retag(srcRef, "some-other-registry.io/some-repo:some-tag")
+				h.AssertError(t, err, "writing to multiple registries is unsupported")
+			})
+
+			it("errors when the source image does not exist", func() {
+				err := retagger.Retag(registryHost+"/some-missing-repo:some-tag", registryHost+"/some-repo:some-other-tag")
+				h.AssertError(t, err, "failed to get image "+registryHost+"/some-missing-repo:some-tag")
+			})
+		})
+	})
+
+	when("Remote handler with insecure registries", func() {
+		var (
+			server       *httptest.Server
+			registryHost string
+			srcRef       string
+			srcDigest    v1.Hash
+		)
+
+		it.Before(func() {
+			server = httptest.NewUnstartedServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+			server.Config.ErrorLog = log.New(io.Discard, "", 0)
+			server.StartTLS()
+			serverURL, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registryHost = serverURL.Host
+
+			srcRef = registryHost + "/some-repo:some-tag"
+			srcImage, err := random.Image(1024, 1)
+			h.AssertNil(t, err)
+			srcDigest, err = srcImage.Digest()
+			h.AssertNil(t, err)
+			ref, err := name.ParseReference(srcRef, name.WeakValidation)
+			h.AssertNil(t, err)
+			h.AssertNil(t, ggcrremote.Write(ref, srcImage, ggcrremote.WithTransport(server.Client().Transport)))
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		when("#Retag", func() {
+			it("skips TLS verification for the insecure registries", func() {
+				retagger := image.NewHandler(nil, authn.DefaultKeychain, "", false, image.WithInsecureRegistries(registryHost)).(image.Retagger)
+				dstRef := registryHost + "/some-repo:some-other-tag"
+
+				h.AssertNil(t, retagger.Retag(srcRef, dstRef))
+
+				ref, err := name.ParseReference(dstRef, name.WeakValidation)
+				h.AssertNil(t, err)
+				desc, err := ggcrremote.Get(ref, ggcrremote.WithTransport(server.Client().Transport))
+				h.AssertNil(t, err)
+				h.AssertEq(t, desc.Digest, srcDigest)
+			})
+
+			it("verifies TLS for other registries", func() {
+				retagger := image.NewHandler(nil, authn.DefaultKeychain, "", false).(image.Retagger)
+
+				err := retagger.Retag(srcRef, registryHost+"/some-repo:some-other-tag")
+				h.AssertError(t, err, "failed to get image "+srcRef)
+			})
+		})
 	})
 }
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package encoding
+
+import "golang.org/x/sys/unix"
+
+// processUmask is read when the package is initialized, before the lifecycle may change the umask (e.g., while extracting archives).
+var processUmask = readUmask()
+
+func readUmask() int {
+	umask := unix.Umask(0)
+	unix.Umask(umask)
+	return umask
+}
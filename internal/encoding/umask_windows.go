@@ -0,0 +1,4 @@
+package encoding
+
+// processUmask is always 0 on Windows, which does not apply file modes.
+var processUmask = 0
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -34,9 +35,40 @@ func WriteJSON(path string, data interface{}) error {
 
 // toml
 
+// TOMLEncoder encodes values as TOML.
+type TOMLEncoder interface {
+	EncodeTOML(w io.Writer, v interface{}) error
+}
+
+// TOMLDecoder decodes TOML files into values.
+// As with os.Open, it must return an error satisfying os.IsNotExist when the file does not exist.
+type TOMLDecoder interface {
+	DecodeTOMLFile(path string, v interface{}) error
+}
+
+// BurntSushiTOML implements TOMLEncoder and TOMLDecoder using github.com/BurntSushi/toml.
+type BurntSushiTOML struct{}
+
+func (BurntSushiTOML) EncodeTOML(w io.Writer, v interface{}) error {
+	return toml.NewEncoder(w).Encode(v)
+}
+
+func (BurntSushiTOML) DecodeTOMLFile(path string, v interface{}) error {
+	_, err := toml.DecodeFile(path, v)
+	return err
+}
+
+// defaultTOML is used by DecodeTOMLFile, MarshalTOML, WriteTOML, and WriteTOMLAtomic.
+var defaultTOML = BurntSushiTOML{}
+
+// DecodeTOMLFile decodes the TOML file at the provided path into v using BurntSushiTOML.
+func DecodeTOMLFile(path string, v interface{}) error {
+	return defaultTOML.DecodeTOMLFile(path, v)
+}
+
 func MarshalTOML(v interface{}) ([]byte, error) {
 	buf := new(bytes.Buffer)
-	if err := toml.NewEncoder(buf).Encode(v); err != nil {
+	if err := defaultTOML.EncodeTOML(buf, v); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
@@ -51,5 +83,37 @@ func WriteTOML(path string, data interface{}) error {
 		return err
 	}
 	defer f.Close()
-	return toml.NewEncoder(f).Encode(data)
+	return defaultTOML.EncodeTOML(f, data)
+}
+
+// WriteTOMLAtomic is like WriteTOML, but writes to a temporary file in the same directory that is renamed into place,
+// so that readers never observe a partially written file.
+// As with WriteTOML, a new file has mode 0666 (before umask), and an existing file keeps its mode.
+func WriteTOMLAtomic(path string, data interface{}) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath) // no-op after a successful rename
+	if err = defaultTOML.EncodeTOML(f, data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	// os.CreateTemp creates the file with mode 0600; use the mode os.Create would have used, or the mode of the existing file
+	mode := os.FileMode(0666) &^ os.FileMode(processUmask)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err = os.Chmod(tmpPath, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
 }
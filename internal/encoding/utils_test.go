@@ -3,6 +3,7 @@ package encoding_test
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -48,6 +49,37 @@ func testEncoding(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when(".DecodeTOMLFile", func() {
+		var tmpDir string
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = os.MkdirTemp("", "lifecycle.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+
+		it.After(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		it("should decode TOML", func() {
+			path := filepath.Join(tmpDir, "group.toml")
+			h.Mkfile(t, "[[group]]\n"+`id = "A"`+"\n"+`version = "v1"`+"\n", path)
+
+			var group buildpack.Group
+			h.AssertNil(t, encoding.DecodeTOMLFile(path, &group))
+			h.AssertEq(t, group.Group, []buildpack.GroupElement{{ID: "A", Version: "v1"}})
+		})
+
+		it("should return a not-exist error when the file is missing", func() {
+			var group buildpack.Group
+			err := encoding.DecodeTOMLFile(filepath.Join(tmpDir, "missing.toml"), &group)
+			h.AssertEq(t, os.IsNotExist(err), true)
+		})
+	})
+
 	when(".WriteTOML", func() {
 		var tmpDir string
 
@@ -78,4 +110,63 @@ func testEncoding(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 	})
+	when(".WriteTOMLAtomic", func() {
+		var tmpDir string
+
+		it.Before(func() {
+			tmpDir = t.TempDir()
+		})
+
+		it("writes TOML, replacing any existing file", func() {
+			path := filepath.Join(tmpDir, "subdir", "group.toml")
+			h.Mkdir(t, filepath.Dir(path))
+			h.Mkfile(t, "some-old-content", path)
+
+			group := buildpack.Group{Group: []buildpack.GroupElement{{ID: "A", Version: "v1"}}}
+			h.AssertNil(t, encoding.WriteTOMLAtomic(path, group))
+
+			h.AssertEq(t, h.Rdfile(t, path), "[[group]]\n"+
+				`  id = "A"`+"\n"+
+				`  version = "v1"`+"\n",
+			)
+			entries, err := os.ReadDir(filepath.Dir(path))
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 1)
+		})
+
+		it("creates the file with the same mode as WriteTOML", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "file modes are not applied on Windows")
+			path := filepath.Join(tmpDir, "group.toml")
+			h.AssertNil(t, encoding.WriteTOMLAtomic(path, buildpack.Group{}))
+
+			info, err := os.Stat(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, info.Mode().Perm(), os.FileMode(0666&^h.GetUmask(t)))
+		})
+
+		it("keeps the mode of an existing file", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "file modes are not applied on Windows")
+			path := filepath.Join(tmpDir, "group.toml")
+			h.Mkfile(t, "some-old-content", path)
+			h.AssertNil(t, os.Chmod(path, 0640))
+			h.AssertNil(t, encoding.WriteTOMLAtomic(path, buildpack.Group{}))
+
+			info, err := os.Stat(path)
+			h.AssertNil(t, err)
+			h.AssertEq(t, info.Mode().Perm(), os.FileMode(0640))
+		})
+
+		it("leaves the existing file and no temporary file behind when encoding fails", func() {
+			path := filepath.Join(tmpDir, "group.toml")
+			h.Mkfile(t, "some-old-content", path)
+
+			err := encoding.WriteTOMLAtomic(path, map[string]interface{}{"some-key": make(chan int)})
+			h.AssertNotNil(t, err)
+
+			h.AssertEq(t, h.Rdfile(t, path), "some-old-content")
+			entries, err := os.ReadDir(tmpDir)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(entries), 1)
+		})
+	})
 }
@@ -1,6 +1,10 @@
 package name
 
-import "github.com/google/go-containerregistry/pkg/name"
+import (
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
 
 func ParseMaybe(ref string) string {
 	if nameRef, err := name.ParseReference(ref); err == nil {
@@ -8,3 +12,32 @@ func ParseMaybe(ref string) string {
 	}
 	return ref
 }
+
+// ExpandMirror returns the full reference for a mirror of the primary image.
+// A mirror that is a bare registry host (e.g., "other-registry.io") is shorthand for the primary image's repository and tag
+// (or digest) on that registry; any other mirror is returned verbatim.
+func ExpandMirror(primary, mirror string) string {
+	if !isRegistryHost(mirror) {
+		return mirror
+	}
+	primaryRef, err := name.ParseReference(primary, name.WeakValidation)
+	if err != nil {
+		return mirror
+	}
+	separator := ":"
+	if _, ok := primaryRef.(name.Digest); ok {
+		separator = "@"
+	}
+	return mirror + "/" + primaryRef.Context().RepositoryStr() + separator + primaryRef.Identifier()
+}
+
+func isRegistryHost(ref string) bool {
+	if ref == "" || strings.ContainsAny(ref, "/@") {
+		return false
+	}
+	host, port, hasPort := strings.Cut(ref, ":")
+	if hasPort && (port == "" || strings.Trim(port, "0123456789") != "") {
+		return false
+	}
+	return host == "localhost" || strings.Contains(host, ".")
+}
@@ -1,6 +1,7 @@
 package name_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/sclevine/spec"
@@ -59,4 +60,32 @@ func testRef(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when(".ExpandMirror", func() {
+		when("mirror is a bare registry host", func() {
+			it("uses the repository and tag of the primary image", func() {
+				got := name.ExpandMirror("some.registry/some-library/some-repo:some-tag", "other.registry")
+				h.AssertEq(t, got, "other.registry/some-library/some-repo:some-tag")
+			})
+
+			it("uses the digest of the primary image", func() {
+				digest := "sha256:" + strings.Repeat("a", 64)
+				got := name.ExpandMirror("some.registry/some-repo@"+digest, "other.registry:5000")
+				h.AssertEq(t, got, "other.registry:5000/some-repo@"+digest)
+			})
+
+			it("uses the implicit library and tag of the primary image", func() {
+				got := name.ExpandMirror("some-repo", "localhost")
+				h.AssertEq(t, got, "localhost/library/some-repo:latest")
+			})
+		})
+
+		when("mirror is a full reference", func() {
+			it("returns the provided mirror", func() {
+				h.AssertEq(t, name.ExpandMirror("some.registry/some-repo", "other.registry/other-repo"), "other.registry/other-repo")
+				h.AssertEq(t, name.ExpandMirror("some.registry/some-repo", "some-repo:22.04"), "some-repo:22.04")
+				h.AssertEq(t, name.ExpandMirror("some.registry/some-repo", "some-repo"), "some-repo")
+			})
+		})
+	})
 }
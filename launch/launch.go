@@ -46,6 +46,16 @@ func (p Process) WithPlatformAPI(platformAPI *api.Version) Process {
 	return p
 }
 
+// AttributeProcesses returns a copy of the provided processes with BuildpackID set to the provided buildpack ID.
+func AttributeProcesses(procs []Process, bpID string) []Process {
+	attributed := make([]Process, len(procs))
+	for i, p := range procs {
+		p.BuildpackID = bpID
+		attributed[i] = p
+	}
+	return attributed
+}
+
 type RawCommand struct {
 	Entries     []string
 	PlatformAPI *api.Version
@@ -20,6 +20,32 @@ func TestLaunch(t *testing.T) {
 }
 
 func testLaunch(t *testing.T, when spec.G, it spec.S) {
+	when("AttributeProcesses", func() {
+		it("returns a copy of the processes with the buildpack ID set", func() {
+			procs := []launch.Process{
+				{Type: "web", BuildpackID: "some-other-buildpack-id"},
+				{Type: "worker"},
+			}
+
+			attributed := launch.AttributeProcesses(procs, "some-buildpack-id")
+
+			h.AssertEq(t, len(attributed), 2)
+			h.AssertEq(t, attributed[0].Type, "web")
+			h.AssertEq(t, attributed[0].BuildpackID, "some-buildpack-id")
+			h.AssertEq(t, attributed[1].Type, "worker")
+			h.AssertEq(t, attributed[1].BuildpackID, "some-buildpack-id")
+			h.AssertEq(t, procs[0].BuildpackID, "some-other-buildpack-id")
+			h.AssertEq(t, procs[1].BuildpackID, "")
+		})
+
+		it("returns an empty slice when there are no processes", func() {
+			attributed := launch.AttributeProcesses(nil, "some-buildpack-id")
+
+			h.AssertNotNil(t, attributed)
+			h.AssertEq(t, len(attributed), 0)
+		})
+	})
+
 	when("Process", func() {
 		when("MarshalTOML", func() {
 			it("output command is array", func() {
@@ -27,6 +27,11 @@ func NewDefaultLogger(writer io.Writer) *DefaultLogger {
 	}
 }
 
+// SetWriter changes the destination of subsequent log messages.
+func (l *DefaultLogger) SetWriter(writer io.Writer) {
+	l.Handler = &handler{writer: writer}
+}
+
 func (l *DefaultLogger) HandleLog(entry *log.Entry) error {
 	return l.Handler.HandleLog(entry)
 }
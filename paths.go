@@ -0,0 +1,14 @@
+package lifecycle
+
+import "github.com/buildpacks/lifecycle/buildpack"
+
+// BuildpackLayersDir returns the directory within layersDir where the buildpack with the provided ID writes its layers.
+// Tools that read a completed layers directory should use it to derive paths identically to the lifecycle.
+func BuildpackLayersDir(layersDir, bpID string) string {
+	return buildpack.LayersDirFor(layersDir, bpID)
+}
+
+// BuildpackPlanPath returns the path within planDir of the buildpack plan provided to the buildpack with the provided ID.
+func BuildpackPlanPath(planDir, bpID string) string {
+	return buildpack.PlanPathFor(planDir, bpID)
+}
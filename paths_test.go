@@ -0,0 +1,30 @@
+package lifecycle_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestPaths(t *testing.T) {
+	spec.Run(t, "Paths", testPaths, spec.Report(report.Terminal{}))
+}
+
+func testPaths(t *testing.T, when spec.G, it spec.S) {
+	when(".BuildpackLayersDir", func() {
+		it("escapes the buildpack ID", func() {
+			h.AssertEq(t, lifecycle.BuildpackLayersDir("some-layers-dir", "some-org/some-buildpack"), filepath.Join("some-layers-dir", "some-org_some-buildpack"))
+		})
+	})
+
+	when(".BuildpackPlanPath", func() {
+		it("escapes the buildpack ID", func() {
+			h.AssertEq(t, lifecycle.BuildpackPlanPath("some-plan-dir", "some-org/some-buildpack"), filepath.Join("some-plan-dir", "some-org_some-buildpack", "plan.toml"))
+		})
+	})
+}
@@ -160,6 +160,9 @@ const (
 	// It contains information about the output application image.
 	EnvReportPath     = "CNB_REPORT_PATH"
 	DefaultReportFile = "report.toml"
+	// EnvReportDir, if set, is the directory in which the report file is written when CNB_REPORT_PATH is not set.
+	// It allows reports from all phases to be relocated without specifying each report path.
+	EnvReportDir = "CNB_REPORT_DIR"
 )
 
 // The following are configuration options with respect to caching.
@@ -205,6 +208,13 @@ const (
 	EnvBuildImage = "CNB_BUILD_IMAGE"
 )
 
+// The following are configuration options for registry access.
+const (
+	// EnvInsecureRegistriesFile is the location of a file listing the registries that the lifecycle may access over plain HTTP
+	// or without TLS verification, in the format read by image.LoadInsecureRegistries.
+	EnvInsecureRegistriesFile = "CNB_INSECURE_REGISTRIES_FILE"
+)
+
 // The following are configuration options for the output application image.
 const (
 	// EnvProcessType is the default process for the application image, the entrypoint in the output image config.
@@ -1,6 +1,7 @@
 package files
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -115,6 +116,26 @@ func (m *LayersMetadata) LayersMetadataFor(bpID string) buildpack.LayersMetadata
 	return buildpack.LayersMetadata{}
 }
 
+// RunImageTopLayer returns the diff ID of the top layer of the run image recorded in the metadata.
+func (m *LayersMetadata) RunImageTopLayer() (string, error) {
+	return m.RunImage.topLayer()
+}
+
+// RunImageReference returns the identifier (image ID or digest reference) of the run image recorded in the metadata.
+func (m *LayersMetadata) RunImageReference() (string, error) {
+	return m.RunImage.reference()
+}
+
+// RunImageTopLayer returns the diff ID of the top layer of the run image recorded in the metadata.
+func (m *LayersMetadataCompat) RunImageTopLayer() (string, error) {
+	return m.RunImage.topLayer()
+}
+
+// RunImageReference returns the identifier (image ID or digest reference) of the run image recorded in the metadata.
+func (m *LayersMetadataCompat) RunImageReference() (string, error) {
+	return m.RunImage.reference()
+}
+
 type LayerMetadata struct {
 	SHA string `json:"sha" toml:"sha"`
 }
@@ -125,6 +146,20 @@ type RunImageForRebase struct {
 	RunImageForExport
 }
 
+func (r *RunImageForRebase) topLayer() (string, error) {
+	if r.TopLayer == "" {
+		return "", errors.New("run image top layer not found in layers metadata")
+	}
+	return r.TopLayer, nil
+}
+
+func (r *RunImageForRebase) reference() (string, error) {
+	if r.Reference == "" {
+		return "", errors.New("run image reference not found in layers metadata")
+	}
+	return r.Reference, nil
+}
+
 func (r *RunImageForRebase) Contains(ref string) bool {
 	return r.RunImageForExport.Contains(ref)
 }
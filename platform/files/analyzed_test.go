@@ -95,4 +95,29 @@ func testAnalyzed(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+	when("LayersMetadata", func() {
+		when("#RunImageTopLayer and #RunImageReference", func() {
+			it("returns the recorded run image", func() {
+				md := files.LayersMetadata{RunImage: files.RunImageForRebase{TopLayer: "some-top-layer", Reference: "some-reference"}}
+
+				topLayer, err := md.RunImageTopLayer()
+				h.AssertNil(t, err)
+				h.AssertEq(t, topLayer, "some-top-layer")
+
+				reference, err := md.RunImageReference()
+				h.AssertNil(t, err)
+				h.AssertEq(t, reference, "some-reference")
+			})
+
+			it("errors when the run image is not recorded", func() {
+				md := files.LayersMetadataCompat{}
+
+				_, err := md.RunImageTopLayer()
+				h.AssertError(t, err, "run image top layer not found in layers metadata")
+
+				_, err = md.RunImageReference()
+				h.AssertError(t, err, "run image reference not found in layers metadata")
+			})
+		})
+	})
 }
@@ -1,10 +1,13 @@
 package files
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/BurntSushi/toml"
 
+	iname "github.com/buildpacks/lifecycle/internal/name"
 	"github.com/buildpacks/lifecycle/log"
 )
 
@@ -17,7 +20,15 @@ type Run struct {
 	Images []RunImageForExport `json:"-" toml:"images"`
 }
 
+// ReadRun reads run.toml from the provided path.
+// If the path is a directory (e.g., run.d), the images of every *.toml fragment in it are concatenated in lexical file order.
+// Images declared in more than one fragment (compared in their canonical form, e.g., "run" and "index.docker.io/library/run:latest")
+// are merged, with any additional mirrors appended to the first declaration.
+// As when the path does not exist, empty run metadata is returned if the directory contains no fragments.
 func ReadRun(runPath string, logger log.Logger) (Run, error) {
+	if fi, err := os.Stat(runPath); err == nil && fi.IsDir() {
+		return readRunDir(runPath, logger)
+	}
 	var runMD Run
 	if _, err := toml.DecodeFile(runPath, &runMD); err != nil {
 		if os.IsNotExist(err) {
@@ -28,3 +39,49 @@ func ReadRun(runPath string, logger log.Logger) (Run, error) {
 	}
 	return runMD, nil
 }
+
+func readRunDir(runDir string, logger log.Logger) (Run, error) {
+	fragments, err := filepath.Glob(filepath.Join(runDir, "*.toml"))
+	if err != nil {
+		return Run{}, err
+	}
+	if len(fragments) == 0 {
+		logger.Infof("no run metadata found at path '%s'\n", runDir)
+		return Run{}, nil
+	}
+	var (
+		runMD   Run
+		indexOf = map[string]int{}
+	)
+	for _, fragment := range fragments { // sorted by filepath.Glob
+		var fragmentMD Run
+		if _, err = toml.DecodeFile(fragment, &fragmentMD); err != nil {
+			return Run{}, fmt.Errorf("failed to read run metadata fragment '%s': %w", fragment, err)
+		}
+		for _, image := range fragmentMD.Images {
+			key := iname.ParseMaybe(image.Image)
+			idx, ok := indexOf[key]
+			if !ok {
+				indexOf[key] = len(runMD.Images)
+				runMD.Images = append(runMD.Images, image)
+				continue
+			}
+			for _, mirror := range image.Mirrors {
+				if !containsRef(runMD.Images[idx].Mirrors, mirror) {
+					runMD.Images[idx].Mirrors = append(runMD.Images[idx].Mirrors, mirror)
+				}
+			}
+		}
+	}
+	return runMD, nil
+}
+
+// containsRef returns true if refs contains ref, comparing references in their canonical form.
+func containsRef(refs []string, ref string) bool {
+	for _, item := range refs {
+		if iname.ParseMaybe(item) == iname.ParseMaybe(ref) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package files_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+
+	"github.com/buildpacks/lifecycle/platform/files"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestRun(t *testing.T) {
+	spec.Run(t, "Run", testRun)
+}
+
+func testRun(t *testing.T, when spec.G, it spec.S) {
+	when(".ReadRun", func() {
+		var (
+			tmpDir     string
+			logger     *log.Logger
+			logHandler *memory.Handler
+		)
+
+		it.Before(func() {
+			var err error
+			tmpDir, err = os.MkdirTemp("", "lifecycle.run")
+			h.AssertNil(t, err)
+			logHandler = memory.New()
+			logger = &log.Logger{Handler: logHandler}
+		})
+
+		it.After(func() {
+			_ = os.RemoveAll(tmpDir)
+		})
+
+		when("the path is a file", func() {
+			it("reads the images", func() {
+				runPath := filepath.Join(tmpDir, "run.toml")
+				h.Mkfile(t, `[[images]]
+  image = "some-run-image"
+  mirrors = ["some-mirror"]
+`, runPath)
+
+				runMD, err := files.ReadRun(runPath, logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, runMD.Images, []files.RunImageForExport{{Image: "some-run-image", Mirrors: []string{"some-mirror"}}})
+			})
+		})
+
+		when("the path does not exist", func() {
+			it("returns empty run metadata", func() {
+				runMD, err := files.ReadRun(filepath.Join(tmpDir, "run.toml"), logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(runMD.Images), 0)
+				h.AssertLogEntry(t, logHandler, "no run metadata found at path")
+			})
+		})
+
+		when("the path is a directory of fragments", func() {
+			var runDir string
+
+			it.Before(func() {
+				runDir = filepath.Join(tmpDir, "run.d")
+				h.Mkdir(t, runDir)
+			})
+
+			it("concatenates the images in file order", func() {
+				h.Mkfile(t, `[[images]]
+  image = "some-other-run-image"
+`, filepath.Join(runDir, "20-other.toml"))
+				h.Mkfile(t, `[[images]]
+  image = "some-run-image"
+`, filepath.Join(runDir, "10-base.toml"))
+				h.Mkfile(t, "not toml", filepath.Join(runDir, "README"))
+
+				runMD, err := files.ReadRun(runDir, logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, runMD.Images, []files.RunImageForExport{{Image: "some-run-image"}, {Image: "some-other-run-image"}})
+			})
+
+			it("merges the mirrors of images declared more than once", func() {
+				h.Mkfile(t, `[[images]]
+  image = "some-run-image"
+  mirrors = ["some-mirror"]
+`, filepath.Join(runDir, "10-base.toml"))
+				h.Mkfile(t, `[[images]]
+  image = "some-run-image"
+  mirrors = ["some-mirror", "some-other-mirror"]
+`, filepath.Join(runDir, "20-mirrors.toml"))
+
+				runMD, err := files.ReadRun(runDir, logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, runMD.Images, []files.RunImageForExport{{Image: "some-run-image", Mirrors: []string{"some-mirror", "some-other-mirror"}}})
+			})
+
+			it("merges images whose references differ only in form", func() {
+				h.Mkfile(t, `[[images]]
+  image = "some-run-image"
+  mirrors = ["some-mirror"]
+`, filepath.Join(runDir, "10-base.toml"))
+				h.Mkfile(t, `[[images]]
+  image = "index.docker.io/library/some-run-image:latest"
+  mirrors = ["docker.io/library/some-mirror", "some-other-mirror"]
+`, filepath.Join(runDir, "20-mirrors.toml"))
+
+				runMD, err := files.ReadRun(runDir, logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, runMD.Images, []files.RunImageForExport{{Image: "some-run-image", Mirrors: []string{"some-mirror", "some-other-mirror"}}})
+			})
+
+			it("returns empty run metadata when there are no fragments", func() {
+				h.Mkfile(t, "not toml", filepath.Join(runDir, "README"))
+
+				runMD, err := files.ReadRun(runDir, logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(runMD.Images), 0)
+				h.AssertLogEntry(t, logHandler, "no run metadata found at path")
+			})
+
+			it("errors when a fragment is malformed", func() {
+				h.Mkfile(t, `[[images]`, filepath.Join(runDir, "10-base.toml"))
+
+				_, err := files.ReadRun(runDir, logger)
+				h.AssertError(t, err, "failed to read run metadata fragment")
+			})
+		})
+	})
+}
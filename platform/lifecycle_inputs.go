@@ -12,6 +12,7 @@ import (
 	"github.com/google/go-containerregistry/pkg/authn"
 
 	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/image"
 	"github.com/buildpacks/lifecycle/internal/str"
 	"github.com/buildpacks/lifecycle/log"
 )
@@ -19,46 +20,52 @@ import (
 // LifecycleInputs holds the values of command-line flags and args i.e., platform inputs to the lifecycle.
 // Fields are the cumulative total of inputs across all lifecycle phases and all supported Platform APIs.
 type LifecycleInputs struct {
-	PlatformAPI           *api.Version
-	AnalyzedPath          string
-	AppDir                string
-	BuildConfigDir        string
-	BuildImageRef         string
-	BuildpacksDir         string
-	CacheDir              string
-	CacheImageRef         string
-	DefaultProcessType    string
-	DeprecatedRunImageRef string
-	ExtendKind            string
-	ExtendedDir           string
-	ExtensionsDir         string
-	GeneratedDir          string
-	GroupPath             string
-	KanikoDir             string
-	LaunchCacheDir        string
-	LauncherPath          string
-	LauncherSBOMDir       string
-	LayersDir             string
-	LayoutDir             string
-	LogLevel              string
-	OrderPath             string
-	OutputImageRef        string
-	PlanPath              string
-	PlatformDir           string
-	PreviousImageRef      string
-	ProjectMetadataPath   string
-	ReportPath            string
-	RunImageRef           string
-	RunPath               string
-	StackPath             string
-	UID                   int
-	GID                   int
-	ForceRebase           bool
-	SkipLayers            bool
-	UseDaemon             bool
-	UseLayout             bool
-	AdditionalTags        str.Slice // str.Slice satisfies the `Value` interface required by the `flag` package
-	KanikoCacheTTL        time.Duration
+	PlatformAPI            *api.Version
+	AnalyzedPath           string
+	AppDir                 string
+	BuildConfigDir         string
+	BuildImageRef          string
+	BuildpacksDir          string
+	CacheDir               string
+	CacheImageRef          string
+	DefaultProcessType     string
+	DeprecatedRunImageRef  string
+	ExtendKind             string
+	ExtendedDir            string
+	ExtensionsDir          string
+	GeneratedDir           string
+	GroupPath              string
+	InsecureRegistriesFile string // if set, the registries listed in this file are accessed over plain HTTP or without TLS verification
+	KanikoDir              string
+	LaunchCacheDir         string
+	LauncherPath           string
+	LauncherSBOMDir        string
+	LayersDir              string
+	LayoutDir              string
+	LogLevel               string
+	OrderPath              string
+	OutputImageRef         string
+	PlanPath               string
+	PlatformDir            string
+	PreviousImageRef       string
+	ProjectMetadataPath    string
+	ReportPath             string
+	RunImageRef            string
+	RunImageDigestFile     string // if set, the rebaser accesses the run image by the digest recorded for it in this file (see PinRunImage); not supported with -daemon
+	RunPath                string
+	StackPath              string
+	UID                    int
+	GID                    int
+	ForceRebase            bool
+	ResolveRunImageDigest  bool // if true, GetRunImageForExport returns the run image as a digest reference
+	VerifyRebaseLayers     bool // if true, the rebaser verifies that the app layers keep their order and no layers from the previous run image remain
+	CheckRebaseABI         bool // if true, the rebaser requires -force when the new run image changes the distribution name or major version
+	SkipLayers             bool
+	UseDaemon              bool
+	UseLayout              bool
+	AdditionalTags         str.Slice // str.Slice satisfies the `Value` interface required by the `flag` package
+	InsecureRegistries     []string  // read from InsecureRegistriesFile when inputs are resolved
+	KanikoCacheTTL         time.Duration
 }
 
 const PlaceholderLayers = "<layers>"
@@ -119,7 +126,7 @@ func NewLifecycleInputs(platformAPI *api.Version) *LifecycleInputs {
 		GeneratedDir: envOrDefault(EnvGeneratedDir, filepath.Join(PlaceholderLayers, DefaultGeneratedDir)),
 		GroupPath:    envOrDefault(EnvGroupPath, filepath.Join(PlaceholderLayers, DefaultGroupFile)),
 		PlanPath:     envOrDefault(EnvPlanPath, filepath.Join(PlaceholderLayers, DefaultPlanFile)),
-		ReportPath:   envOrDefault(EnvReportPath, filepath.Join(PlaceholderLayers, DefaultReportFile)),
+		ReportPath:   reportPathOrDefault(filepath.Join(PlaceholderLayers, DefaultReportFile)),
 
 		// Configuration options with respect to caching
 
@@ -139,6 +146,10 @@ func NewLifecycleInputs(platformAPI *api.Version) *LifecycleInputs {
 		PreviousImageRef:      os.Getenv(EnvPreviousImage),
 		RunImageRef:           os.Getenv(EnvRunImage),
 
+		// Configuration options for registry access
+
+		InsecureRegistriesFile: os.Getenv(EnvInsecureRegistriesFile),
+
 		// Configuration options for the output application image
 
 		DefaultProcessType:  os.Getenv(EnvProcessType),
@@ -161,7 +172,7 @@ func NewLifecycleInputs(platformAPI *api.Version) *LifecycleInputs {
 		inputs.GroupPath = envOrDefault(EnvGroupPath, DefaultGroupFile)
 		inputs.PlanPath = envOrDefault(EnvPlanPath, DefaultPlanFile)
 		inputs.ProjectMetadataPath = envOrDefault(EnvProjectMetadataPath, DefaultProjectMetadataFile)
-		inputs.ReportPath = envOrDefault(EnvReportPath, DefaultReportFile)
+		inputs.ReportPath = reportPathOrDefault(DefaultReportFile)
 	}
 
 	return inputs
@@ -186,6 +197,15 @@ func (i *LifecycleInputs) AccessChecker() CheckReadAccess {
 
 type CheckReadAccess func(repo string, keychain authn.Keychain) (bool, error)
 
+// DefaultCheckReadAccess returns a CheckReadAccess that checks whether the provided keychain can read the image from its registry,
+// using the same checks as image.DefaultRegistryHandler.
+// Registries in insecureRegistries are accessed over plain HTTP or without TLS verification.
+// Each invocation of the returned function performs a network call.
+func DefaultCheckReadAccess(insecureRegistries []string) CheckReadAccess {
+	handler := image.NewRegistryHandler(nil, insecureRegistries)
+	return handler.CheckReadAccessWithKeychain
+}
+
 func (i *LifecycleInputs) DestinationImages() []string {
 	var ret []string
 	ret = appendOnce(ret, i.OutputImageRef)
@@ -249,6 +269,15 @@ func envOrDefault(key string, defaultVal string) string {
 	return defaultVal
 }
 
+// reportPathOrDefault returns the report path from CNB_REPORT_PATH,
+// or the default report file in CNB_REPORT_DIR, or else the provided default.
+func reportPathOrDefault(defaultVal string) string {
+	if reportDir := os.Getenv(EnvReportDir); reportDir != "" {
+		defaultVal = filepath.Join(reportDir, DefaultReportFile)
+	}
+	return envOrDefault(EnvReportPath, defaultVal)
+}
+
 func intEnv(k string) int {
 	v := os.Getenv(k)
 	d, err := strconv.Atoi(v)
@@ -1,12 +1,22 @@
 package platform_test
 
 import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+
 	"github.com/buildpacks/lifecycle/api"
 	"github.com/buildpacks/lifecycle/internal/str"
 	"github.com/buildpacks/lifecycle/platform"
@@ -78,6 +88,7 @@ func testLifecycleInputs(t *testing.T, when spec.G, it spec.S) {
 				h.AssertNil(t, os.Setenv(platform.EnvForceRebase, "true"))
 				h.AssertNil(t, os.Setenv(platform.EnvGeneratedDir, "some-generated-dir"))
 				h.AssertNil(t, os.Setenv(platform.EnvGroupPath, "some-group-path"))
+				h.AssertNil(t, os.Setenv(platform.EnvInsecureRegistriesFile, "some-insecure-registries-file"))
 				h.AssertNil(t, os.Setenv(platform.EnvKanikoCacheTTL, "1h0m0s"))
 				h.AssertNil(t, os.Setenv(platform.EnvLaunchCacheDir, "some-launch-cache-dir"))
 				h.AssertNil(t, os.Setenv(platform.EnvLayersDir, "some-layers-dir"))
@@ -112,6 +123,7 @@ func testLifecycleInputs(t *testing.T, when spec.G, it spec.S) {
 				h.AssertNil(t, os.Unsetenv(platform.EnvGID))
 				h.AssertNil(t, os.Unsetenv(platform.EnvGeneratedDir))
 				h.AssertNil(t, os.Unsetenv(platform.EnvGroupPath))
+				h.AssertNil(t, os.Unsetenv(platform.EnvInsecureRegistriesFile))
 				h.AssertNil(t, os.Unsetenv(platform.EnvKanikoCacheTTL))
 				h.AssertNil(t, os.Unsetenv(platform.EnvLaunchCacheDir))
 				h.AssertNil(t, os.Unsetenv(platform.EnvLayersDir))
@@ -151,6 +163,7 @@ func testLifecycleInputs(t *testing.T, when spec.G, it spec.S) {
 				h.AssertEq(t, inputs.GID, 5678)
 				h.AssertEq(t, inputs.GeneratedDir, "some-generated-dir")
 				h.AssertEq(t, inputs.GroupPath, "some-group-path")
+				h.AssertEq(t, inputs.InsecureRegistriesFile, "some-insecure-registries-file")
 				h.AssertEq(t, inputs.KanikoCacheTTL, 1*time.Hour)
 				h.AssertEq(t, inputs.LaunchCacheDir, "some-launch-cache-dir")
 				h.AssertEq(t, inputs.LauncherPath, platform.DefaultLauncherPath)
@@ -187,6 +200,28 @@ func testLifecycleInputs(t *testing.T, when spec.G, it spec.S) {
 			h.AssertEq(t, inputs.ReportPath, filepath.Join("<layers>", "report.toml"))
 		})
 
+		when("the report directory is set", func() {
+			it.Before(func() {
+				h.AssertNil(t, os.Setenv(platform.EnvReportDir, "some-report-dir"))
+			})
+
+			it.After(func() {
+				h.AssertNil(t, os.Unsetenv(platform.EnvReportDir))
+				h.AssertNil(t, os.Unsetenv(platform.EnvReportPath))
+			})
+
+			it("writes the report in the report directory", func() {
+				inputs = platform.NewLifecycleInputs(platformAPI)
+				h.AssertEq(t, inputs.ReportPath, filepath.Join("some-report-dir", "report.toml"))
+			})
+
+			it("prefers the report path", func() {
+				h.AssertNil(t, os.Setenv(platform.EnvReportPath, "some-report-path"))
+				inputs = platform.NewLifecycleInputs(platformAPI)
+				h.AssertEq(t, inputs.ReportPath, "some-report-path")
+			})
+		})
+
 		when("Platform API = 0.5", func() {
 			platformAPI = api.MustParse("0.5")
 
@@ -332,4 +367,59 @@ func testLifecycleInputs(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when("#DefaultCheckReadAccess", func() {
+		var (
+			server   *httptest.Server
+			registry string
+		)
+
+		it.Before(func() {
+			// the registry serves TLS with a self-signed certificate, so it can only be read when it is treated as insecure;
+			// it does not listen on 127.0.0.1, which would be accessed over plain HTTP regardless
+			listener, err := net.Listen("tcp", "127.0.0.2:0")
+			if err != nil {
+				t.Skipf("Unable to listen on 127.0.0.2: %s", err)
+			}
+			server = httptest.NewUnstartedServer(ggcrregistry.New())
+			server.Listener = listener
+			server.StartTLS()
+			u, err := url.Parse(server.URL)
+			h.AssertNil(t, err)
+			registry = u.Host
+
+			ref, err := name.ParseReference(registry + "/some-repo:latest")
+			h.AssertNil(t, err)
+			img, err := random.Image(64, 1)
+			h.AssertNil(t, err)
+			pushTransport := server.Client().Transport.(*http.Transport).Clone()
+			pushTransport.TLSClientConfig.InsecureSkipVerify = true // the certificate is only valid for 127.0.0.1
+			h.AssertNil(t, ggcrremote.Write(ref, img, ggcrremote.WithTransport(pushTransport)))
+		})
+
+		it.After(func() {
+			server.Close()
+		})
+
+		it("returns true when the image can be read from an insecure registry", func() {
+			checkReadAccess := platform.DefaultCheckReadAccess([]string{registry})
+			canRead, err := checkReadAccess(registry+"/some-repo:latest", authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertEq(t, canRead, true)
+		})
+
+		it("fails when the registry is not configured as insecure", func() {
+			checkReadAccess := platform.DefaultCheckReadAccess(nil)
+			canRead, err := checkReadAccess(registry+"/some-repo:latest", authn.DefaultKeychain)
+			h.AssertNotNil(t, err)
+			h.AssertEq(t, canRead, false)
+		})
+
+		it("errors when the reference is invalid", func() {
+			checkReadAccess := platform.DefaultCheckReadAccess([]string{registry})
+			canRead, err := checkReadAccess(registry+"/Some-Repo", authn.DefaultKeychain)
+			h.AssertNotNil(t, err)
+			h.AssertEq(t, canRead, false)
+		})
+	})
 }
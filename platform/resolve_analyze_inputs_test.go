@@ -143,6 +143,39 @@ func testResolveAnalyzeInputs(platformAPI string) func(t *testing.T, when spec.G
 			})
 		})
 
+		when("insecure registries file", func() {
+			it.Before(func() {
+				inputs.RunImageRef = "some-run-image" // satisfy validation
+			})
+
+			when("provided", func() {
+				it("reads the insecure registries", func() {
+					inputs.InsecureRegistriesFile = filepath.Join(t.TempDir(), "insecure-registries")
+					h.Mkfile(t, "some-registry.io\nsome-other-registry.io:5000\n", inputs.InsecureRegistriesFile)
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, inputs.InsecureRegistries, []string{"some-registry.io", "some-other-registry.io:5000"})
+				})
+			})
+
+			when("not exists", func() {
+				it("errors", func() {
+					inputs.InsecureRegistriesFile = filepath.Join(t.TempDir(), "not-exist")
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertNotNil(t, err)
+					h.AssertStringContains(t, err.Error(), "failed to read insecure registries")
+				})
+			})
+
+			when("not provided", func() {
+				it("does not set insecure registries", func() {
+					err := platform.ResolveInputs(platform.Analyze, inputs, logger)
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(inputs.InsecureRegistries), 0)
+				})
+			})
+		})
+
 		when("Platform API < 0.7", func() {
 			it.Before(func() {
 				h.SkipIf(t, api.MustParse(platformAPI).AtLeast("0.7"), "")
@@ -2,10 +2,12 @@ package platform
 
 import (
 	"errors"
+	"fmt"
 	"os"
 
 	"github.com/google/go-containerregistry/pkg/name"
 
+	"github.com/buildpacks/lifecycle/image"
 	"github.com/buildpacks/lifecycle/log"
 	"github.com/buildpacks/lifecycle/platform/files"
 )
@@ -18,6 +20,7 @@ var (
 	ErrRunImageUnsupported           = "-run-image is unsupported"
 	ErrImageUnsupported              = "-image is unsupported"
 	MsgIgnoringLaunchCache           = "Ignoring -launch-cache, only intended for use with -daemon"
+	MsgUsingRunImageFrom             = "Using run image '%s' from %s"
 )
 
 func ResolveInputs(phase LifecyclePhase, i *LifecycleInputs, logger log.Logger) error {
@@ -30,6 +33,7 @@ func ResolveInputs(phase LifecyclePhase, i *LifecycleInputs, logger log.Logger)
 		}
 		ops = append(ops,
 			FillAnalyzeImages,
+			ReadInsecureRegistries,
 			ValidateOutputImageProvided,
 			CheckLaunchCache,
 			ValidateImageRefs,
@@ -40,6 +44,7 @@ func ResolveInputs(phase LifecyclePhase, i *LifecycleInputs, logger log.Logger)
 	case Create:
 		ops = append(ops,
 			FillCreateImages,
+			ReadInsecureRegistries,
 			ValidateOutputImageProvided,
 			CheckCache,
 			CheckLaunchCache,
@@ -90,6 +95,19 @@ func CheckCache(i *LifecycleInputs, logger log.Logger) error {
 	return nil
 }
 
+// ReadInsecureRegistries reads the registries listed in the insecure registries file, if one is provided.
+func ReadInsecureRegistries(i *LifecycleInputs, _ log.Logger) error {
+	if i.InsecureRegistriesFile == "" {
+		return nil
+	}
+	registries, err := image.LoadInsecureRegistries(i.InsecureRegistriesFile)
+	if err != nil {
+		return fmt.Errorf("failed to read insecure registries: %w", err)
+	}
+	i.InsecureRegistries = registries
+	return nil
+}
+
 func CheckLaunchCache(i *LifecycleInputs, logger log.Logger) error {
 	if !i.UseDaemon && i.LaunchCacheDir != "" {
 		logger.Warn(MsgIgnoringLaunchCache)
@@ -230,16 +248,22 @@ func ValidateOutputImageProvided(i *LifecycleInputs, _ log.Logger) error {
 	return nil
 }
 
-func ValidateRebaseRunImage(i *LifecycleInputs, _ log.Logger) error {
+// ValidateRebaseRunImage resolves the run image for rebase and logs where it came from.
+// A run image provided by flag takes precedence over CNB_RUN_IMAGE;
+// if neither is provided, the rebaser falls back to the app image metadata.
+func ValidateRebaseRunImage(i *LifecycleInputs, logger log.Logger) error {
 	switch {
 	case i.DeprecatedRunImageRef != "" && i.RunImageRef != os.Getenv(EnvRunImage):
 		return errors.New(ErrSupplyOnlyOneRunImage)
 	case i.DeprecatedRunImageRef != "":
 		i.RunImageRef = i.DeprecatedRunImageRef
-		return nil
-	default:
-		return nil
+		logger.Infof(MsgUsingRunImageFrom, i.RunImageRef, "-image")
+	case i.RunImageRef != "" && i.RunImageRef == os.Getenv(EnvRunImage):
+		logger.Infof(MsgUsingRunImageFrom, i.RunImageRef, EnvRunImage)
+	case i.RunImageRef != "":
+		logger.Infof(MsgUsingRunImageFrom, i.RunImageRef, "-run-image")
 	}
+	return nil
 }
 
 // ValidateTargetsAreSameRegistry ensures all output images are on the same registry.
@@ -251,21 +275,5 @@ func ValidateTargetsAreSameRegistry(i *LifecycleInputs, _ log.Logger) error {
 }
 
 func ValidateSameRegistry(tags ...string) error {
-	var (
-		reg        string
-		registries = map[string]struct{}{}
-	)
-	for _, imageRef := range tags {
-		ref, err := name.ParseReference(imageRef, name.WeakValidation)
-		if err != nil {
-			return err
-		}
-		reg = ref.Context().RegistryStr()
-		registries[reg] = struct{}{}
-	}
-
-	if len(registries) > 1 {
-		return errors.New("writing to multiple registries is unsupported")
-	}
-	return nil
+	return image.ValidateDestinationTags(false, tags...)
 }
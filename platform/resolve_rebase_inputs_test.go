@@ -0,0 +1,85 @@
+package platform_test
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle/api"
+	llog "github.com/buildpacks/lifecycle/log"
+	"github.com/buildpacks/lifecycle/platform"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestRebaseInputs(t *testing.T) {
+	spec.Run(t, "unit-rebase-inputs", testResolveRebaseInputs, spec.Report(report.Terminal{}))
+}
+
+func testResolveRebaseInputs(t *testing.T, when spec.G, it spec.S) {
+	var (
+		inputs     *platform.LifecycleInputs
+		logHandler *memory.Handler
+		logger     llog.Logger
+	)
+
+	newInputs := func() {
+		inputs = platform.NewLifecycleInputs(api.Platform.Latest())
+		inputs.OutputImageRef = "some-output-image" // satisfy validation
+	}
+
+	it.Before(func() {
+		logHandler = memory.New()
+		logger = &log.Logger{Handler: logHandler}
+	})
+
+	when("run image", func() {
+		when("provided by -run-image", func() {
+			it("takes precedence over CNB_RUN_IMAGE", func() {
+				t.Setenv(platform.EnvRunImage, "some-env-run-image")
+				newInputs()
+				inputs.RunImageRef = "some-flag-run-image"
+
+				h.AssertNil(t, platform.ResolveInputs(platform.Rebase, inputs, logger))
+				h.AssertEq(t, inputs.RunImageRef, "some-flag-run-image")
+				h.AssertLogEntry(t, logHandler, "Using run image 'some-flag-run-image' from -run-image")
+			})
+		})
+
+		when("provided by -image", func() {
+			it("takes precedence over CNB_RUN_IMAGE", func() {
+				t.Setenv(platform.EnvRunImage, "some-env-run-image")
+				newInputs()
+				inputs.DeprecatedRunImageRef = "some-deprecated-flag-run-image"
+
+				h.AssertNil(t, platform.ResolveInputs(platform.Rebase, inputs, logger))
+				h.AssertEq(t, inputs.RunImageRef, "some-deprecated-flag-run-image")
+				h.AssertLogEntry(t, logHandler, "Using run image 'some-deprecated-flag-run-image' from -image")
+			})
+		})
+
+		when("provided by CNB_RUN_IMAGE", func() {
+			it("uses the environment variable", func() {
+				t.Setenv(platform.EnvRunImage, "some-env-run-image")
+				newInputs()
+
+				h.AssertNil(t, platform.ResolveInputs(platform.Rebase, inputs, logger))
+				h.AssertEq(t, inputs.RunImageRef, "some-env-run-image")
+				h.AssertLogEntry(t, logHandler, "Using run image 'some-env-run-image' from CNB_RUN_IMAGE")
+			})
+		})
+
+		when("not provided", func() {
+			it("leaves the run image to be read from the app image metadata", func() {
+				t.Setenv(platform.EnvRunImage, "")
+				newInputs()
+
+				h.AssertNil(t, platform.ResolveInputs(platform.Rebase, inputs, logger))
+				h.AssertEq(t, inputs.RunImageRef, "")
+				h.AssertEq(t, len(logHandler.Entries), 0)
+			})
+		})
+	})
+}
@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/BurntSushi/toml"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 
 	"github.com/buildpacks/lifecycle/auth"
 	"github.com/buildpacks/lifecycle/cmd"
+	"github.com/buildpacks/lifecycle/image"
 	iname "github.com/buildpacks/lifecycle/internal/name"
 	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/platform/files"
@@ -20,7 +23,61 @@ const (
 	OSDistributionVersionLabel = "io.buildpacks.distribution.version"
 )
 
+// NormalizeRef returns the canonical form of the provided image reference, with the default registry (index.docker.io),
+// the library/ namespace for official images, and the latest tag filled in where they are implied,
+// so that references written in different forms (e.g., "run" and "docker.io/library/run:latest") can be compared.
+// References that cannot be parsed are returned unchanged.
+func NormalizeRef(ref string) string {
+	return iname.ParseMaybe(ref)
+}
+
+// PinRunImage returns the digest reference for runImageRef recorded in the digest file at path,
+// so that the run image can be accessed without resolving its tag.
+// The digest file is a TOML table mapping image references to digests, e.g.,
+//
+//	"registry.example.com/some/run:some-tag" = "sha256:..."
+//
+// References are compared in their canonical form (see NormalizeRef).
+// If runImageRef is already a digest reference it is returned unchanged;
+// otherwise, it is an error for runImageRef to be missing from the digest file.
+func PinRunImage(runImageRef, path string) (string, error) {
+	ref, err := name.ParseReference(runImageRef, name.WeakValidation)
+	if err != nil {
+		return "", fmt.Errorf("parsing run image reference '%s': %w", runImageRef, err)
+	}
+	if _, ok := ref.(name.Digest); ok {
+		return runImageRef, nil
+	}
+
+	var digests map[string]string
+	if _, err = toml.DecodeFile(path, &digests); err != nil {
+		return "", fmt.Errorf("reading run image digest file '%s': %w", path, err)
+	}
+	for tagRef, digest := range digests {
+		if NormalizeRef(tagRef) != NormalizeRef(runImageRef) {
+			continue
+		}
+		pinned, err := name.NewDigest(ref.Context().Name()+"@"+digest, name.WeakValidation)
+		if err != nil {
+			return "", fmt.Errorf("invalid digest '%s' for run image '%s' in '%s': %w", digest, tagRef, path, err)
+		}
+		return pinned.String(), nil
+	}
+	return "", fmt.Errorf("run image '%s' is not pinned in digest file '%s'", runImageRef, path)
+}
+
+// GetRunImageForExport returns the run image metadata that should be recorded in the exported image.
+// When inputs.ResolveRunImageDigest is set, the selected image is resolved to a digest reference.
+// In layout mode the digest is read from the OCI layout directory if the image is present there; otherwise a remote call is used.
 func GetRunImageForExport(inputs LifecycleInputs) (files.RunImageForExport, error) {
+	runImage, err := getRunImageForExport(inputs)
+	if err != nil || !inputs.ResolveRunImageDigest || runImage.Image == "" {
+		return runImage, err
+	}
+	return resolveRunImageDigest(runImage, inputs)
+}
+
+func getRunImageForExport(inputs LifecycleInputs) (files.RunImageForExport, error) {
 	if inputs.PlatformAPI.LessThan("0.12") {
 		stackMD, err := files.ReadStack(inputs.StackPath, cmd.DefaultLogger)
 		if err != nil {
@@ -35,13 +92,13 @@ func GetRunImageForExport(inputs LifecycleInputs) (files.RunImageForExport, erro
 	if len(runMD.Images) == 0 {
 		return files.RunImageForExport{}, nil
 	}
-	inputRef := iname.ParseMaybe(inputs.RunImageRef)
+	inputRef := NormalizeRef(inputs.RunImageRef)
 	for _, runImage := range runMD.Images {
-		if iname.ParseMaybe(runImage.Image) == inputRef {
+		if NormalizeRef(runImage.Image) == inputRef {
 			return runImage, nil
 		}
 		for _, mirror := range runImage.Mirrors {
-			if iname.ParseMaybe(mirror) == inputRef {
+			if NormalizeRef(iname.ExpandMirror(runImage.Image, mirror)) == inputRef {
 				return runImage, nil
 			}
 		}
@@ -57,46 +114,174 @@ func GetRunImageForExport(inputs LifecycleInputs) (files.RunImageForExport, erro
 	return runMD.Images[0], nil
 }
 
-func BestRunImageMirrorFor(targetRegistry string, runImageMD files.RunImageForExport, checkReadAccess CheckReadAccess) (string, error) {
-	var runImageMirrors []string
-	if runImageMD.Image == "" {
-		return "", errors.New("missing run image metadata")
+// AllRunImageRefs returns every run image and mirror declared in run.toml (or stack.toml for Platform API < 0.12),
+// with mirrors expanded and duplicates removed, so that platforms can pre-pull the images the build might use.
+func AllRunImageRefs(inputs LifecycleInputs) ([]string, error) {
+	var runImages []files.RunImageForExport
+	if inputs.PlatformAPI.LessThan("0.12") {
+		stackMD, err := files.ReadStack(inputs.StackPath, cmd.DefaultLogger)
+		if err != nil {
+			return nil, err
+		}
+		runImages = append(runImages, stackMD.RunImage)
+	} else {
+		runMD, err := files.ReadRun(inputs.RunPath, cmd.DefaultLogger)
+		if err != nil {
+			return nil, err
+		}
+		runImages = runMD.Images
+	}
+
+	var refs []string
+	seen := map[string]bool{}
+	for _, runImage := range runImages {
+		if runImage.Image == "" {
+			continue
+		}
+		mirrors, err := runImageMirrorsFor(runImage)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range mirrors {
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+func resolveRunImageDigest(runImage files.RunImageForExport, inputs LifecycleInputs) (files.RunImageForExport, error) {
+	ref, err := name.ParseReference(runImage.Image, name.WeakValidation)
+	if err != nil {
+		return files.RunImageForExport{}, fmt.Errorf("failed to parse run image reference '%s': %w", runImage.Image, err)
+	}
+	if _, ok := ref.(name.Digest); ok {
+		return runImage, nil
+	}
+	if inputs.UseLayout && inputs.LayoutDir != "" {
+		digest, err := image.LayoutDigest(inputs.LayoutDir, runImage.Image)
+		if err != nil {
+			return files.RunImageForExport{}, fmt.Errorf("failed to resolve digest for run image '%s' from layout: %w", runImage.Image, err)
+		}
+		if digest != "" {
+			runImage.Image = ref.Context().Digest(digest).Name()
+			return runImage, nil
+		}
+	}
+	keychain, err := auth.DefaultKeychain(runImage.Image)
+	if err != nil {
+		return files.RunImageForExport{}, fmt.Errorf("unable to create keychain: %w", err)
+	}
+	desc, err := remote.Head(ref, remote.WithAuthFromKeychain(keychain))
+	if err != nil {
+		return files.RunImageForExport{}, fmt.Errorf("failed to resolve digest for run image '%s': %w", runImage.Image, err)
 	}
-	runImageMirrors = append(runImageMirrors, runImageMD.Image)
-	runImageMirrors = append(runImageMirrors, runImageMD.Mirrors...)
+	runImage.Image = ref.Context().Digest(desc.Digest.String()).Name()
+	return runImage, nil
+}
 
+// BestRunImageMirrorFor selects the run image (or mirror) to use for the target registry,
+// using a keychain constructed from the default credentials for the run image and its mirrors.
+func BestRunImageMirrorFor(targetRegistry string, runImageMD files.RunImageForExport, checkReadAccess CheckReadAccess) (string, error) {
+	runImageMirrors, err := runImageMirrorsFor(runImageMD)
+	if err != nil {
+		return "", err
+	}
 	keychain, err := auth.DefaultKeychain(runImageMirrors...)
 	if err != nil {
 		return "", fmt.Errorf("unable to create keychain: %w", err)
 	}
+	return BestRunImageMirrorForWithKeychain(targetRegistry, runImageMD, checkReadAccess, keychain)
+}
+
+// BestRunImageMirrorForWithKeychain is like BestRunImageMirrorFor, but checks read access using the provided keychain.
+// It prefers an accessible mirror on the target registry, and otherwise selects the first accessible image.
+func BestRunImageMirrorForWithKeychain(targetRegistry string, runImageMD files.RunImageForExport, checkReadAccess CheckReadAccess, keychain authn.Keychain) (string, error) {
+	runImageMirrors, err := runImageMirrorsFor(runImageMD)
+	if err != nil {
+		return "", err
+	}
+
+	// Parse each mirror once, for use in both passes below
+	mirrors := parseMirrors(runImageMirrors)
 
 	// Try to select run image on the same registry as the target
-	runImageRef := byRegistry(targetRegistry, runImageMirrors, checkReadAccess, keychain)
+	runImageRef := byRegistry(targetRegistry, mirrors, checkReadAccess, keychain)
 	if runImageRef != "" {
+		cmd.DefaultLogger.Debugf("Selected run image mirror '%s' on target registry '%s'", runImageRef, targetRegistry)
 		return runImageRef, nil
 	}
 
 	// Select the first run image we have access to
-	for _, image := range runImageMirrors {
-		if ok, _ := checkReadAccess(image, keychain); ok {
-			return image, nil
+	for _, mirror := range mirrors {
+		ok, err := checkReadAccess(mirror.image, keychain)
+		logMirrorProbe(mirror.image, false, ok, err)
+		if ok {
+			cmd.DefaultLogger.Debugf("Selected run image mirror '%s': no accessible mirror on target registry '%s'", mirror.image, targetRegistry)
+			return mirror.image, nil
 		}
 	}
 
 	return "", errors.New("failed to find accessible run image")
 }
 
-func byRegistry(reg string, images []string, checkReadAccess CheckReadAccess, keychain authn.Keychain) string {
+// parsedMirror is a run image mirror together with its registry, or the error encountered while parsing it.
+type parsedMirror struct {
+	image    string
+	registry string
+	err      error
+}
+
+func parseMirrors(images []string) []parsedMirror {
+	mirrors := make([]parsedMirror, 0, len(images))
 	for _, image := range images {
+		mirror := parsedMirror{image: image}
 		ref, err := name.ParseReference(image, name.WeakValidation)
 		if err != nil {
+			mirror.err = err
+		} else {
+			mirror.registry = ref.Context().RegistryStr()
+		}
+		mirrors = append(mirrors, mirror)
+	}
+	return mirrors
+}
+
+func runImageMirrorsFor(runImageMD files.RunImageForExport) ([]string, error) {
+	if runImageMD.Image == "" {
+		return nil, errors.New("missing run image metadata")
+	}
+	runImageMirrors := []string{runImageMD.Image}
+	for _, mirror := range runImageMD.Mirrors {
+		runImageMirrors = append(runImageMirrors, iname.ExpandMirror(runImageMD.Image, mirror))
+	}
+	return runImageMirrors, nil
+}
+
+func byRegistry(reg string, mirrors []parsedMirror, checkReadAccess CheckReadAccess, keychain authn.Keychain) string {
+	for _, mirror := range mirrors {
+		if mirror.err != nil {
+			cmd.DefaultLogger.Debugf("Skipping run image mirror '%s': %s", mirror.image, mirror.err)
 			continue
 		}
-		if reg == ref.Context().RegistryStr() {
-			if ok, _ := checkReadAccess(image, keychain); ok {
-				return image
+		if reg == mirror.registry {
+			ok, err := checkReadAccess(mirror.image, keychain)
+			logMirrorProbe(mirror.image, true, ok, err)
+			if ok {
+				return mirror.image
 			}
 		}
 	}
 	return ""
 }
+
+func logMirrorProbe(image string, colocated, readable bool, err error) {
+	if err != nil {
+		cmd.DefaultLogger.Debugf("Probed run image mirror '%s' (on target registry: %t): readable: %t: %s", image, colocated, readable, err)
+		return
+	}
+	cmd.DefaultLogger.Debugf("Probed run image mirror '%s' (on target registry: %t): readable: %t", image, colocated, readable)
+}
@@ -1,11 +1,28 @@
 package platform_test
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 
+	ilayout "github.com/buildpacks/imgutil/layout"
+
+	"github.com/buildpacks/lifecycle/cmd"
+	llog "github.com/buildpacks/lifecycle/log"
 	"github.com/buildpacks/lifecycle/platform"
 	"github.com/buildpacks/lifecycle/platform/files"
 	h "github.com/buildpacks/lifecycle/testhelpers"
@@ -75,6 +92,16 @@ func testRunImage(t *testing.T, when spec.G, it spec.S) {
 						})
 					})
 				})
+
+				when("reference is fully qualified", func() {
+					inputs.RunImageRef = "docker.io/library/some-run-image-from-run-toml-1:latest"
+
+					it("still matches", func() {
+						result, err := platform.GetRunImageForExport(inputs)
+						h.AssertNil(t, err)
+						h.AssertEq(t, result.Image, "some-run-image-from-run-toml-1")
+					})
+				})
 			})
 
 			when("contains an image mirror matching run image ref", func() {
@@ -125,6 +152,117 @@ func testRunImage(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("resolving the run image digest", func() {
+			var (
+				server        *httptest.Server
+				tmpDir        string
+				runImageRef   string
+				runImageInput platform.LifecycleInputs
+			)
+
+			it.Before(func() {
+				server = httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+				serverURL, err := url.Parse(server.URL)
+				h.AssertNil(t, err)
+				runImageRef = serverURL.Host + "/some-run-image:some-tag"
+
+				img, err := random.Image(1024, 1)
+				h.AssertNil(t, err)
+				ref, err := name.ParseReference(runImageRef, name.WeakValidation)
+				h.AssertNil(t, err)
+				h.AssertNil(t, remote.Write(ref, img))
+
+				tmpDir = t.TempDir()
+				runPath := filepath.Join(tmpDir, "run.toml")
+				h.Mkfile(t, fmt.Sprintf("[[images]]\n image = %q\n mirrors = [\"some-mirror\"]\n", runImageRef), runPath)
+
+				runImageInput = platform.LifecycleInputs{
+					LayersDir:             filepath.Join("testdata", "layers"),
+					PlatformAPI:           api.Platform.Latest(),
+					RunImageRef:           runImageRef,
+					RunPath:               runPath,
+					ResolveRunImageDigest: true,
+				}
+			})
+
+			it.After(func() {
+				server.Close()
+			})
+
+			it("returns the image as a digest reference", func() {
+				result, err := platform.GetRunImageForExport(runImageInput)
+				h.AssertNil(t, err)
+
+				digestRef, err := name.NewDigest(result.Image)
+				h.AssertNil(t, err)
+				h.AssertEq(t, digestRef.Context().Name(), strings.TrimSuffix(runImageRef, ":some-tag"))
+				h.AssertEq(t, result.Mirrors, []string{"some-mirror"})
+			})
+
+			when("using a layout directory", func() {
+				var layoutDir string
+
+				it.Before(func() {
+					layoutDir = t.TempDir()
+					runImageInput.UseLayout = true
+					runImageInput.LayoutDir = layoutDir
+				})
+
+				it("reads the digest from the layout when the image is present", func() {
+					img, err := random.Image(1024, 1)
+					h.AssertNil(t, err)
+					layoutImagePath, err := ilayout.ParseRefToPath(runImageRef)
+					h.AssertNil(t, err)
+					layoutPath, err := layout.Write(filepath.Join(layoutDir, layoutImagePath), empty.Index)
+					h.AssertNil(t, err)
+					h.AssertNil(t, layoutPath.AppendImage(img))
+					expectedDigest, err := img.Digest()
+					h.AssertNil(t, err)
+
+					result, err := platform.GetRunImageForExport(runImageInput)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, result.Image, strings.TrimSuffix(runImageRef, ":some-tag")+"@"+expectedDigest.String())
+				})
+
+				it("falls back to the registry when the image is not in the layout", func() {
+					result, err := platform.GetRunImageForExport(runImageInput)
+					h.AssertNil(t, err)
+
+					digestRef, err := name.NewDigest(result.Image)
+					h.AssertNil(t, err)
+					h.AssertEq(t, digestRef.Context().Name(), strings.TrimSuffix(runImageRef, ":some-tag"))
+				})
+			})
+
+			it("errors when the image cannot be found", func() {
+				h.Mkfile(t, fmt.Sprintf("[[images]]\n image = %q\n", strings.TrimSuffix(runImageRef, ":some-tag")+":missing-tag"), runImageInput.RunPath)
+				runImageInput.RunImageRef = ""
+
+				_, err := platform.GetRunImageForExport(runImageInput)
+				h.AssertNotNil(t, err)
+				h.AssertStringContains(t, err.Error(), "failed to resolve digest for run image")
+			})
+		})
+
+		when("contains a bare registry host mirror matching run image ref", func() {
+			it("returns the image", func() {
+				runPath := filepath.Join(t.TempDir(), "run.toml")
+				h.Mkfile(t, "[[images]]\n image = \"some-registry.io/some-run-image:some-tag\"\n mirrors = [\"other-registry.io\"]\n", runPath)
+				shorthandInputs := inputs
+				shorthandInputs.PlatformAPI = api.Platform.Latest()
+				shorthandInputs.RunPath = runPath
+				shorthandInputs.RunImageRef = "other-registry.io/some-run-image:some-tag"
+
+				result, err := platform.GetRunImageForExport(shorthandInputs)
+				h.AssertNil(t, err)
+				h.AssertEq(t, result, files.RunImageForExport{
+					Image:   "some-registry.io/some-run-image:some-tag",
+					Mirrors: []string{"other-registry.io"},
+				})
+			})
+		})
+
 		when("platform api < 0.12", func() {
 			inputs.PlatformAPI = api.MustParse("0.11")
 
@@ -161,6 +299,142 @@ func testRunImage(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when(".NormalizeRef", func() {
+		it("fills in the implied registry, namespace, and tag", func() {
+			for _, tc := range []struct{ ref, expected string }{
+				{"run", "index.docker.io/library/run:latest"},
+				{"run:some-tag", "index.docker.io/library/run:some-tag"},
+				{"docker.io/run", "index.docker.io/library/run:latest"},
+				{"docker.io/library/run:latest", "index.docker.io/library/run:latest"},
+				{"some-org/run", "index.docker.io/some-org/run:latest"},
+				{"some-registry.io/run", "some-registry.io/run:latest"},
+				{"some-registry.io:5000/some-org/run:some-tag", "some-registry.io:5000/some-org/run:some-tag"},
+				{
+					"run@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+					"index.docker.io/library/run@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				},
+			} {
+				h.AssertEq(t, platform.NormalizeRef(tc.ref), tc.expected)
+			}
+		})
+
+		it("returns references that cannot be parsed unchanged", func() {
+			h.AssertEq(t, platform.NormalizeRef("!@#$"), "!@#$")
+		})
+	})
+
+	when(".PinRunImage", func() {
+		const digest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+		var digestFile string
+
+		it.Before(func() {
+			digestFile = filepath.Join(t.TempDir(), "digests.toml")
+			h.Mkfile(t, `"some-registry.io/some-org/run:some-tag" = "`+digest+`"`+"\n"+
+				`"run" = "`+digest+`"`+"\n",
+				digestFile,
+			)
+		})
+
+		it("returns the pinned digest reference", func() {
+			pinned, err := platform.PinRunImage("some-registry.io/some-org/run:some-tag", digestFile)
+			h.AssertNil(t, err)
+			h.AssertEq(t, pinned, "some-registry.io/some-org/run@"+digest)
+		})
+
+		it("matches references written in a different form", func() {
+			pinned, err := platform.PinRunImage("docker.io/library/run:latest", digestFile)
+			h.AssertNil(t, err)
+			h.AssertEq(t, pinned, "index.docker.io/library/run@"+digest)
+		})
+
+		it("returns digest references unchanged", func() {
+			pinned, err := platform.PinRunImage("some-registry.io/other-run@"+digest, digestFile)
+			h.AssertNil(t, err)
+			h.AssertEq(t, pinned, "some-registry.io/other-run@"+digest)
+		})
+
+		it("errors when the run image is not pinned", func() {
+			_, err := platform.PinRunImage("some-registry.io/other-run:some-tag", digestFile)
+			h.AssertError(t, err, "run image 'some-registry.io/other-run:some-tag' is not pinned in digest file")
+		})
+
+		it("errors when the digest is invalid", func() {
+			h.Mkfile(t, `"run" = "not-a-digest"`+"\n", digestFile)
+			_, err := platform.PinRunImage("run", digestFile)
+			h.AssertError(t, err, "invalid digest 'not-a-digest'")
+		})
+
+		it("errors when the digest file cannot be read", func() {
+			_, err := platform.PinRunImage("run", filepath.Join(t.TempDir(), "missing.toml"))
+			h.AssertError(t, err, "reading run image digest file")
+		})
+	})
+
+	when(".AllRunImageRefs", func() {
+		var inputs platform.LifecycleInputs
+
+		it.Before(func() {
+			inputs = platform.LifecycleInputs{
+				PlatformAPI: api.Platform.Latest(),
+				RunPath:     filepath.Join("testdata", "layers", "run.toml"),
+				StackPath:   filepath.Join("testdata", "layers", "stack.toml"),
+			}
+		})
+
+		it("returns every image and mirror in run.toml", func() {
+			refs, err := platform.AllRunImageRefs(inputs)
+			h.AssertNil(t, err)
+			h.AssertEq(t, refs, []string{
+				"some-run-image-from-run-toml",
+				"some-run-image-mirror-from-run-toml",
+				"some-other-run-image-mirror-from-run-toml",
+				"some-run-image-from-run-toml-1",
+				"some-run-image-mirror-from-run-toml-1",
+				"some-other-run-image-mirror-from-run-toml-1",
+			})
+		})
+
+		it("removes duplicates", func() {
+			inputs.RunPath = h.TempFile(t, "", "run.toml")
+			h.Mkfile(t, `[[images]]
+  image = "some-run-image"
+  mirrors = ["some-mirror"]
+
+[[images]]
+  image = "some-mirror"
+  mirrors = ["some-run-image", "some-other-mirror"]
+`, inputs.RunPath)
+
+			refs, err := platform.AllRunImageRefs(inputs)
+			h.AssertNil(t, err)
+			h.AssertEq(t, refs, []string{"some-run-image", "some-mirror", "some-other-mirror"})
+		})
+
+		when("run.toml does not exist", func() {
+			it("returns no references", func() {
+				inputs.RunPath = "foo"
+
+				refs, err := platform.AllRunImageRefs(inputs)
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(refs), 0)
+			})
+		})
+
+		when("platform API < 0.12", func() {
+			it("returns the image and mirrors in stack.toml", func() {
+				inputs.PlatformAPI = api.MustParse("0.11")
+
+				refs, err := platform.AllRunImageRefs(inputs)
+				h.AssertNil(t, err)
+				h.AssertEq(t, refs, []string{
+					"some-run-image-from-stack-toml",
+					"some-run-image-mirror-from-stack-toml",
+					"some-other-run-image-mirror-from-stack-toml",
+				})
+			})
+		})
+	})
+
 	when(".EnvVarsFor", func() {
 		it("returns the right thing", func() {
 			tm := files.TargetMetadata{Arch: "pentium", ArchVariant: "mmx", ID: "my-id", OS: "linux", Distribution: &files.OSDistribution{Name: "nix", Version: "22.11"}}
@@ -239,6 +513,66 @@ func testRunImage(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("debug logging", func() {
+			var (
+				logs           *bytes.Buffer
+				originalLogger *llog.DefaultLogger
+			)
+
+			it.Before(func() {
+				logs = &bytes.Buffer{}
+				originalLogger = cmd.DefaultLogger
+				cmd.DefaultLogger = llog.NewDefaultLogger(logs)
+			})
+
+			it.After(func() {
+				cmd.DefaultLogger = originalLogger
+			})
+
+			it("logs each mirror probed and the selected mirror", func() {
+				checkReadAccess := func(image string, _ authn.Keychain) (bool, error) {
+					return image == "myorg/myrepo", nil
+				}
+				name, err := platform.BestRunImageMirrorFor("gcr.io", stackMD.RunImage, checkReadAccess)
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "myorg/myrepo")
+
+				h.AssertStringContains(t, logs.String(), "Probed run image mirror 'gcr.io/org/repo' (on target registry: true): readable: false")
+				h.AssertStringContains(t, logs.String(), "Probed run image mirror 'first.com/org/repo' (on target registry: false): readable: false")
+				h.AssertStringContains(t, logs.String(), "Probed run image mirror 'myorg/myrepo' (on target registry: false): readable: true")
+				h.AssertStringContains(t, logs.String(), "Selected run image mirror 'myorg/myrepo': no accessible mirror on target registry 'gcr.io'")
+			})
+
+			it("logs the mirror selected on the target registry", func() {
+				_, err := platform.BestRunImageMirrorFor("gcr.io", stackMD.RunImage, nopCheckReadAccess)
+				h.AssertNil(t, err)
+				h.AssertStringContains(t, logs.String(), "Selected run image mirror 'gcr.io/org/repo' on target registry 'gcr.io'")
+			})
+		})
+
+		when("a mirror is a bare registry host", func() {
+			it.Before(func() {
+				stackMD.RunImage.Mirrors = []string{"gcr.io", "myorg/myrepo"}
+			})
+
+			it("expands it using the repository of the run image", func() {
+				name, err := platform.BestRunImageMirrorFor("gcr.io", stackMD.RunImage, nopCheckReadAccess)
+				h.AssertNil(t, err)
+				h.AssertEq(t, name, "gcr.io/org/repo:latest")
+			})
+
+			it("checks access with the expanded reference", func() {
+				var checked []string
+				checkReadAccess := func(image string, _ authn.Keychain) (bool, error) {
+					checked = append(checked, image)
+					return false, nil
+				}
+				_, err := platform.BestRunImageMirrorFor("some-registry.io", stackMD.RunImage, checkReadAccess)
+				h.AssertNotNil(t, err)
+				h.AssertEq(t, checked, []string{"first.com/org/repo", "gcr.io/org/repo:latest", "myorg/myrepo"})
+			})
+		})
+
 		when("one of the images is non-parsable", func() {
 			it.Before(func() {
 				stackMD.RunImage.Mirrors = []string{"as@ohd@as@op", "gcr.io/myorg/myrepo"}
@@ -251,4 +585,45 @@ func testRunImage(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when(".BestRunImageMirrorForWithKeychain", func() {
+		it("checks read access using the provided keychain", func() {
+			runImageMD := files.RunImageForExport{
+				Image:   "first.com/org/repo",
+				Mirrors: []string{"gcr.io/org/repo"},
+			}
+			var keychains []authn.Keychain
+			checkReadAccess := func(_ string, keychain authn.Keychain) (bool, error) {
+				keychains = append(keychains, keychain)
+				return true, nil
+			}
+
+			name, err := platform.BestRunImageMirrorForWithKeychain("gcr.io", runImageMD, checkReadAccess, authn.DefaultKeychain)
+			h.AssertNil(t, err)
+			h.AssertEq(t, name, "gcr.io/org/repo")
+			h.AssertEq(t, len(keychains), 1)
+			h.AssertEq(t, keychains[0] == authn.DefaultKeychain, true)
+		})
+
+		it("errors when the run image metadata is missing", func() {
+			_, err := platform.BestRunImageMirrorForWithKeychain("gcr.io", files.RunImageForExport{}, nil, authn.DefaultKeychain)
+			h.AssertError(t, err, "missing run image metadata")
+		})
+	})
+}
+
+func BenchmarkBestRunImageMirrorFor(b *testing.B) {
+	runImageMD := files.RunImageForExport{Image: "first.com/org/repo"}
+	for idx := 0; idx < 100; idx++ {
+		runImageMD.Mirrors = append(runImageMD.Mirrors, fmt.Sprintf("mirror-%d.com/org/repo", idx))
+	}
+	checkReadAccess := func(image string, _ authn.Keychain) (bool, error) {
+		return image == "mirror-99.com/org/repo", nil
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := platform.BestRunImageMirrorForWithKeychain("some-registry.io", runImageMD, checkReadAccess, authn.DefaultKeychain); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
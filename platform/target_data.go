@@ -1,6 +1,9 @@
 package platform
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/buildpacks/imgutil"
 
 	"github.com/buildpacks/lifecycle/buildpack"
@@ -123,3 +126,83 @@ func TargetSatisfiedForRebase(t files.TargetMetadata, appTargetMetadata files.Ta
 	}
 	return true
 }
+
+// TargetMismatch describes a run image label whose value differs from the app target metadata.
+type TargetMismatch struct {
+	Label    string
+	Expected string
+	Actual   string
+}
+
+// RunImageTargetReport is the result of comparing a run image's target labels against the app target metadata.
+type RunImageTargetReport struct {
+	// Mismatches are the labels whose values differ from the app target metadata.
+	Mismatches []TargetMismatch
+	// Unknown are the labels that the app target metadata expects but that are missing from the run image.
+	Unknown []string
+}
+
+// ValidateRunImageTarget compares the target id and distribution labels of a run image against the app target metadata.
+// Fields that are not set in the app target metadata are not compared.
+// Missing labels are reported as unknown and only cause an error when strict is set; otherwise they are logged as warnings.
+func ValidateRunImageTarget(appTarget files.TargetMetadata, runImageLabels map[string]string, strict bool, logger log.Logger) (RunImageTargetReport, error) {
+	expected := map[string]string{}
+	if appTarget.ID != "" {
+		expected[TargetLabel] = appTarget.ID
+	}
+	if appTarget.Distribution != nil {
+		expected[OSDistributionNameLabel] = appTarget.Distribution.Name
+		expected[OSDistributionVersionLabel] = appTarget.Distribution.Version
+	}
+
+	var report RunImageTargetReport
+	for _, label := range []string{TargetLabel, OSDistributionNameLabel, OSDistributionVersionLabel} {
+		expectedValue, ok := expected[label]
+		if !ok {
+			continue
+		}
+		actualValue, ok := runImageLabels[label]
+		if !ok {
+			report.Unknown = append(report.Unknown, label)
+			continue
+		}
+		if actualValue != expectedValue {
+			report.Mismatches = append(report.Mismatches, TargetMismatch{Label: label, Expected: expectedValue, Actual: actualValue})
+		}
+	}
+
+	if len(report.Mismatches) > 0 {
+		var details []string
+		for _, mismatch := range report.Mismatches {
+			details = append(details, fmt.Sprintf("label '%s': expected '%s', found '%s'", mismatch.Label, mismatch.Expected, mismatch.Actual))
+		}
+		return report, fmt.Errorf("run image target does not match app target: %s", strings.Join(details, "; "))
+	}
+	if len(report.Unknown) > 0 {
+		if strict {
+			return report, fmt.Errorf("run image is missing target label(s): %s", strings.Join(report.Unknown, ", "))
+		}
+		logger.Warnf("Unable to verify run image target, missing label(s): %s", strings.Join(report.Unknown, ", "))
+	}
+	return report, nil
+}
+
+// DistributionChanged returns true if the run image distribution differs from the app target distribution in name or major version,
+// which is likely to break the ABI that the app layers depend on. A change in minor version (e.g., from 22.04 to 22.10) is not reported.
+func (r RunImageTargetReport) DistributionChanged() bool {
+	for _, mismatch := range r.Mismatches {
+		switch mismatch.Label {
+		case OSDistributionNameLabel:
+			return true
+		case OSDistributionVersionLabel:
+			if majorVersion(mismatch.Expected) != majorVersion(mismatch.Actual) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func majorVersion(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
+}
@@ -68,6 +68,121 @@ func testTargetData(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 
+	when(".ValidateRunImageTarget", func() {
+		var (
+			appTarget  files.TargetMetadata
+			logHandler *memory.Handler
+			logger     *log.Logger
+		)
+
+		it.Before(func() {
+			appTarget = files.TargetMetadata{
+				ID:           "some-target-id",
+				OS:           "linux",
+				Arch:         "amd64",
+				Distribution: &files.OSDistribution{Name: "ubuntu", Version: "22.04"},
+			}
+			logHandler = memory.New()
+			logger = &log.Logger{Handler: logHandler}
+		})
+
+		it("succeeds when all labels match", func() {
+			report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+				platform.TargetLabel:                "some-target-id",
+				platform.OSDistributionNameLabel:    "ubuntu",
+				platform.OSDistributionVersionLabel: "22.04",
+			}, false, logger)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(report.Mismatches), 0)
+			h.AssertEq(t, len(report.Unknown), 0)
+		})
+
+		it("reports mismatched labels", func() {
+			report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+				platform.TargetLabel:                "some-target-id",
+				platform.OSDistributionNameLabel:    "ubuntu",
+				platform.OSDistributionVersionLabel: "18.04",
+			}, false, logger)
+			h.AssertError(t, err, "label 'io.buildpacks.distribution.version': expected '22.04', found '18.04'")
+			h.AssertEq(t, report.Mismatches, []platform.TargetMismatch{{
+				Label:    platform.OSDistributionVersionLabel,
+				Expected: "22.04",
+				Actual:   "18.04",
+			}})
+		})
+
+		it("does not compare fields that are not set on the app target", func() {
+			appTarget.ID = ""
+			appTarget.Distribution = nil
+
+			report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+				platform.OSDistributionNameLabel: "alpine",
+			}, true, logger)
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(report.Unknown), 0)
+		})
+
+		when("#DistributionChanged", func() {
+			it("is false when only the minor version changes", func() {
+				report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+					platform.TargetLabel:                "some-target-id",
+					platform.OSDistributionNameLabel:    "ubuntu",
+					platform.OSDistributionVersionLabel: "22.10",
+				}, false, logger)
+				h.AssertNotNil(t, err)
+				h.AssertEq(t, report.DistributionChanged(), false)
+			})
+
+			it("is true when the major version changes", func() {
+				report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+					platform.TargetLabel:                "some-target-id",
+					platform.OSDistributionNameLabel:    "ubuntu",
+					platform.OSDistributionVersionLabel: "24.04",
+				}, false, logger)
+				h.AssertNotNil(t, err)
+				h.AssertEq(t, report.DistributionChanged(), true)
+			})
+
+			it("is true when the distribution name changes", func() {
+				report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+					platform.TargetLabel:                "some-target-id",
+					platform.OSDistributionNameLabel:    "debian",
+					platform.OSDistributionVersionLabel: "22.04",
+				}, false, logger)
+				h.AssertNotNil(t, err)
+				h.AssertEq(t, report.DistributionChanged(), true)
+			})
+
+			it("is false when only the target id changes", func() {
+				report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+					platform.TargetLabel:                "some-other-target-id",
+					platform.OSDistributionNameLabel:    "ubuntu",
+					platform.OSDistributionVersionLabel: "22.04",
+				}, false, logger)
+				h.AssertNotNil(t, err)
+				h.AssertEq(t, report.DistributionChanged(), false)
+			})
+		})
+
+		when("labels are missing", func() {
+			it("warns and allows the run image", func() {
+				report, err := platform.ValidateRunImageTarget(appTarget, map[string]string{
+					platform.OSDistributionNameLabel: "ubuntu",
+				}, false, logger)
+				h.AssertNil(t, err)
+				h.AssertEq(t, report.Unknown, []string{platform.TargetLabel, platform.OSDistributionVersionLabel})
+				h.AssertLogEntry(t, logHandler, "Unable to verify run image target, missing label(s): io.buildpacks.id, io.buildpacks.distribution.version")
+			})
+
+			when("strict", func() {
+				it("errors", func() {
+					_, err := platform.ValidateRunImageTarget(appTarget, map[string]string{}, true, logger)
+					h.AssertError(t, err, "run image is missing target label(s): io.buildpacks.id, io.buildpacks.distribution.name, io.buildpacks.distribution.version")
+				})
+			})
+		})
+	})
+
 	when(".GetTargetOSFromFileSystem", func() {
 		it("populates appropriately", func() {
 			logr := &log.Logger{Handler: memory.New()}
@@ -7,6 +7,9 @@ import (
 	"strings"
 
 	"github.com/buildpacks/imgutil"
+	"github.com/buildpacks/imgutil/local"
+	"github.com/buildpacks/imgutil/remote"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pkg/errors"
 
 	"github.com/buildpacks/lifecycle/api"
@@ -23,16 +26,50 @@ var (
 	msgAppImageNotMarkedRebasable       = "app image is not marked as rebasable"
 	msgRunImageMDNotContainsName        = "rebase app image: new base image '%s' not found in existing run image metadata: %s"
 	msgUnableToSatisfyTargetConstraints = "unable to satisfy target os/arch constraints; new run image: %s, old run image: %s"
+	msgAppImageBuiltWithExtensions      = "app image run image was extended by image extensions (%s); rebasing may discard layers from the extended run image"
+	msgDistributionMayBreakABI          = "new base image distribution '%s' may not be compatible with the app image distribution '%s'"
 )
 
 type Rebaser struct {
 	Logger      log.Logger
 	PlatformAPI *api.Version
 	Force       bool
+	// VerifyLayers, if true, causes Rebase to fail unless the rebased image consists of exactly the layers of the new base image
+	// followed by the app layers in their original order, i.e., no layers of the previous base image remain.
+	// The layers are then recorded in the report. It requires images that expose their layers (e.g., registry images).
+	VerifyLayers bool
+	// CheckABICompatibility, if true, causes Rebase to fail unless -force is provided when the distribution of the new base image
+	// differs in name or major version from the distribution recorded in the app image, as the app layers may not be compatible with it.
+	// This is most useful for app images built using platform API < 0.12, whose targets are not otherwise validated.
+	CheckABICompatibility bool
 }
 
 type RebaseReport struct {
 	Image files.ImageReport `toml:"image"`
+	// Rebased is false when the app image was already based on the new base image and was left untouched.
+	Rebased bool `toml:"rebased"`
+	// Layers are the diff IDs of the rebased image, from bottom to top.
+	// They are only reported when the layers are verified (see Rebaser.VerifyLayers).
+	Layers []string `toml:"layers,omitempty"`
+	// FailedTags are the tags that could not be saved when the rebased image was only partially saved.
+	FailedTags []RebaseTagFailure `toml:"failed-tags,omitempty"`
+	// LayersBefore and LayersAfter are the number of layers in the app image before and after rebasing.
+	// They are only reported when the layers are verified.
+	LayersBefore int `toml:"layers-before,omitzero"`
+	LayersAfter  int `toml:"layers-after,omitzero"`
+	// Error is set when Rebase fails after saving has started.
+	Error string `toml:"error,omitempty"`
+}
+
+// RebaseTagFailure describes a tag of the rebased image that could not be saved.
+type RebaseTagFailure struct {
+	Tag   string `toml:"tag"`
+	Error string `toml:"error"`
+}
+
+// Partial returns true if the report describes a failed rebase for which some progress was recorded.
+func (r RebaseReport) Partial() bool {
+	return r.Error != ""
 }
 
 func (r *Rebaser) Rebase(workingImage imgutil.Image, newBaseImage imgutil.Image, outputImageRef string, additionalNames []string) (RebaseReport, error) {
@@ -40,6 +77,7 @@ func (r *Rebaser) Rebase(workingImage imgutil.Image, newBaseImage imgutil.Image,
 	if err != nil {
 		return RebaseReport{}, fmt.Errorf("failed to get app image platform API: %w", err)
 	}
+
 	// perform platform API-specific validations
 	if appPlatformAPI == "" || api.MustParse(appPlatformAPI).LessThan("0.12") {
 		if err = validateStackID(workingImage, newBaseImage); err != nil {
@@ -54,12 +92,51 @@ func (r *Rebaser) Rebase(workingImage imgutil.Image, newBaseImage imgutil.Image,
 		}
 	}
 
+	if r.CheckABICompatibility {
+		if err = r.validateDistribution(workingImage, newBaseImage); err != nil {
+			return RebaseReport{}, err
+		}
+	}
+
 	// get existing metadata label
 	var origMetadata files.LayersMetadataCompat
 	if err = image.DecodeLabel(workingImage, platform.LifecycleMetadataLabel, &origMetadata); err != nil {
 		return RebaseReport{}, fmt.Errorf("get image metadata: %w", err)
 	}
 
+	// skip rebasing if the app image is already based on the new base image
+	identifier, err := newBaseImage.Identifier()
+	if err != nil {
+		return RebaseReport{}, fmt.Errorf("get run image id or digest: %w", err)
+	}
+	if !r.Force && origMetadata.RunImage.Reference == identifier.String() &&
+		outputImageRef == workingImage.Name() && len(additionalNames) == 0 {
+		r.Logger.Infof("App image is already based on run image '%s'; skipping rebase", identifier.String())
+		report := RebaseReport{Rebased: false}
+		if report.Image, err = unchangedImageReport(workingImage); err != nil {
+			return RebaseReport{}, err
+		}
+		return report, nil
+	}
+
+	// record the app layers so that their order can be verified after rebasing
+	var origLayers, appLayers, newBaseLayers []string
+	if r.VerifyLayers {
+		var appHasLayers, newBaseHasLayers bool
+		if origLayers, appHasLayers, err = layerDiffIDs(workingImage); err != nil {
+			return RebaseReport{}, fmt.Errorf("get app image layers: %w", err)
+		}
+		if newBaseLayers, newBaseHasLayers, err = layerDiffIDs(newBaseImage); err != nil {
+			return RebaseReport{}, fmt.Errorf("get run image layers: %w", err)
+		}
+		if !appHasLayers || !newBaseHasLayers {
+			return RebaseReport{}, errors.New("verify rebase: layers of the app image and run image are unavailable; layers can only be verified for registry images")
+		}
+		if appLayers, err = layersAbove(origLayers, origMetadata.RunImage.TopLayer); err != nil {
+			return RebaseReport{}, fmt.Errorf("get app image layers: %w", err)
+		}
+	}
+
 	// rebase
 	if err = workingImage.Rebase(origMetadata.RunImage.TopLayer, newBaseImage); err != nil {
 		return RebaseReport{}, fmt.Errorf("rebase app image: %w", err)
@@ -70,9 +147,14 @@ func (r *Rebaser) Rebase(workingImage imgutil.Image, newBaseImage imgutil.Image,
 	if err != nil {
 		return RebaseReport{}, fmt.Errorf("get rebase run image top layer SHA: %w", err)
 	}
-	identifier, err := newBaseImage.Identifier()
-	if err != nil {
-		return RebaseReport{}, fmt.Errorf("get run image id or digest: %w", err)
+	var rebasedLayers []string
+	if r.VerifyLayers {
+		if rebasedLayers, err = verifyAppLayerOrder(workingImage, origMetadata.RunImage.TopLayer, appLayers); err != nil {
+			return RebaseReport{}, fmt.Errorf("verify rebase: %w", err)
+		}
+		if err = verifyLayerCount(rebasedLayers, newBaseLayers, appLayers); err != nil {
+			return RebaseReport{}, fmt.Errorf("verify rebase: %w", err)
+		}
 	}
 	origMetadata.RunImage.Reference = identifier.String()
 	if r.PlatformAPI.AtLeast("0.12") {
@@ -116,6 +198,9 @@ func (r *Rebaser) Rebase(workingImage imgutil.Image, newBaseImage imgutil.Image,
 	if err := workingImage.SetLabel(platform.LifecycleMetadataLabel, string(data)); err != nil {
 		return RebaseReport{}, fmt.Errorf("set app image metadata label: %w", err)
 	}
+	if err = verifyRebasedMetadata(workingImage, origMetadata.RunImage.TopLayer, origMetadata.RunImage.Reference); err != nil {
+		return RebaseReport{}, fmt.Errorf("verify rebase: %w", err)
+	}
 
 	// update other labels
 	hasPrefix := func(l string) bool {
@@ -129,17 +214,133 @@ func (r *Rebaser) Rebase(workingImage imgutil.Image, newBaseImage imgutil.Image,
 	}
 
 	// save
-	report := RebaseReport{}
-	report.Image, err = saveImageAs(workingImage, outputImageRef, additionalNames, r.Logger)
-	if err != nil {
-		return RebaseReport{}, err
+	report := RebaseReport{Rebased: true, Layers: rebasedLayers}
+	if r.VerifyLayers {
+		report.LayersBefore, report.LayersAfter = len(origLayers), len(rebasedLayers)
 	}
+	report.Image, err = saveImageAs(workingImage, outputImageRef, additionalNames, r.Logger)
 	if !r.supportsManifestSize() {
 		// unset manifest size in report.toml for old platform API versions
 		report.Image.ManifestSize = 0
 	}
+	if err != nil {
+		// return what was saved, so that partial progress can be reported
+		report.Error = err.Error()
+		var saveErr imgutil.SaveError
+		if errors.As(err, &saveErr) {
+			for _, d := range saveErr.Errors {
+				report.FailedTags = append(report.FailedTags, RebaseTagFailure{Tag: d.ImageName, Error: d.Cause.Error()})
+			}
+		}
+		return report, err
+	}
+
+	return report, nil
+}
 
-	return report, err
+// unchangedImageReport describes an app image that was not saved because it did not need to be rebased.
+func unchangedImageReport(workingImage imgutil.Image) (files.ImageReport, error) {
+	var imageReport files.ImageReport
+	id, err := workingImage.Identifier()
+	if err != nil {
+		return files.ImageReport{}, fmt.Errorf("get app image id or digest: %w", err)
+	}
+	switch v := id.(type) {
+	case local.IDIdentifier:
+		imageReport.ImageID = v.String()
+	case remote.DigestIdentifier:
+		imageReport.Digest = v.Digest.DigestStr()
+	}
+	return imageReport, nil
+}
+
+// verifyRebasedMetadata ensures the metadata label on the rebased image records the new run image.
+func verifyRebasedMetadata(workingImage imgutil.Image, expectedTopLayer, expectedReference string) error {
+	var rebasedMetadata files.LayersMetadataCompat
+	if err := image.DecodeLabel(workingImage, platform.LifecycleMetadataLabel, &rebasedMetadata); err != nil {
+		return err
+	}
+	topLayer, err := rebasedMetadata.RunImageTopLayer()
+	if err != nil {
+		return err
+	}
+	if topLayer != expectedTopLayer {
+		return fmt.Errorf("recorded run image top layer '%s' does not match new run image top layer '%s'", topLayer, expectedTopLayer)
+	}
+	reference, err := rebasedMetadata.RunImageReference()
+	if err != nil {
+		return err
+	}
+	if reference != expectedReference {
+		return fmt.Errorf("recorded run image reference '%s' does not match new run image reference '%s'", reference, expectedReference)
+	}
+	return nil
+}
+
+// layeredImage is implemented by images that expose their underlying v1.Image (e.g., registry images).
+type layeredImage interface {
+	UnderlyingImage() v1.Image
+}
+
+// layerDiffIDs returns the diff IDs of the provided image from bottom to top,
+// and false if the image does not expose its layers.
+func layerDiffIDs(img imgutil.Image) ([]string, bool, error) {
+	layered, ok := img.(layeredImage)
+	if !ok {
+		return nil, false, nil
+	}
+	configFile, err := layered.UnderlyingImage().ConfigFile()
+	if err != nil {
+		return nil, true, err
+	}
+	var diffIDs []string
+	for _, diffID := range configFile.RootFS.DiffIDs {
+		diffIDs = append(diffIDs, diffID.String())
+	}
+	return diffIDs, true, nil
+}
+
+// layersAbove returns the layers above the given base image top layer.
+func layersAbove(layers []string, baseTopLayer string) ([]string, error) {
+	for idx, layer := range layers {
+		if layer == baseTopLayer {
+			return layers[idx+1:], nil
+		}
+	}
+	return nil, fmt.Errorf("base image top layer '%s' not found in image layers", baseTopLayer)
+}
+
+// verifyAppLayerOrder ensures the app layers were re-applied in their original order atop the new base image,
+// and returns the layers of the rebased image.
+func verifyAppLayerOrder(workingImage imgutil.Image, newBaseTopLayer string, expectedAppLayers []string) ([]string, error) {
+	rebasedLayers, _, err := layerDiffIDs(workingImage)
+	if err != nil {
+		return nil, err
+	}
+	appLayers, err := layersAbove(rebasedLayers, newBaseTopLayer)
+	if err != nil {
+		return nil, err
+	}
+	if len(appLayers) != len(expectedAppLayers) {
+		return nil, fmt.Errorf("expected %d app layers atop the new base image, found %d", len(expectedAppLayers), len(appLayers))
+	}
+	for idx := range appLayers {
+		if appLayers[idx] != expectedAppLayers[idx] {
+			return nil, fmt.Errorf("app layers were not preserved in their original order: expected '%s' at position %d, found '%s'", expectedAppLayers[idx], idx, appLayers[idx])
+		}
+	}
+	return rebasedLayers, nil
+}
+
+// verifyLayerCount ensures the rebased image consists of the layers of the new base image and the app layers only,
+// i.e., that no layers of the previous base image remain.
+func verifyLayerCount(rebasedLayers, newBaseLayers, appLayers []string) error {
+	expected := len(newBaseLayers) + len(appLayers)
+	if len(rebasedLayers) != expected {
+		return fmt.Errorf("expected %d layers (%d from the run image and %d app layers), found %d; layers from the previous run image may remain",
+			expected, len(newBaseLayers), len(appLayers), len(rebasedLayers))
+	}
+	return nil
 }
 
 func containsName(origMetadata files.LayersMetadataCompat, newBaseName string) bool {
@@ -202,16 +403,56 @@ func validateMixins(appImg, newBaseImg imgutil.Image) error {
 	return nil
 }
 
+// validateDistribution compares the distribution labels of the app image, which are inherited from the run image it was built on,
+// with those of the new base image. A change in distribution name or major version is likely to break the ABI that the app layers depend on.
+func (r *Rebaser) validateDistribution(appImg imgutil.Image, newBaseImg imgutil.Image) error {
+	appTarget, err := platform.GetTargetMetadata(appImg)
+	if err != nil {
+		return fmt.Errorf("get app image target: %w", err)
+	}
+	appDist := appTarget.Distribution
+	if appDist == nil || appDist.Name == "" || appDist.Version == "" {
+		r.Logger.Debug("Skipping distribution compatibility check: app image distribution labels are missing")
+		return nil
+	}
+	newBaseLabels, err := newBaseImg.Labels()
+	if err != nil {
+		return fmt.Errorf("get new base image labels: %w", err)
+	}
+	// only the distribution is compared; other target mismatches are handled by validateTarget
+	report, err := platform.ValidateRunImageTarget(files.TargetMetadata{Distribution: appDist}, newBaseLabels, false, r.Logger)
+	if err == nil || !report.DistributionChanged() {
+		return nil
+	}
+	newBaseDist := &files.OSDistribution{
+		Name:    newBaseLabels[platform.OSDistributionNameLabel],
+		Version: newBaseLabels[platform.OSDistributionVersionLabel],
+	}
+	if !r.Force {
+		return fmt.Errorf(msgDistributionMayBreakABI+"; "+msgProvideForceToOverride, distributionString(newBaseDist), distributionString(appDist))
+	}
+	r.Logger.Warnf(msgDistributionMayBreakABI, distributionString(newBaseDist), distributionString(appDist))
+	return nil
+}
+
+func distributionString(dist *files.OSDistribution) string {
+	return dist.Name + "@" + dist.Version
+}
+
 func (r *Rebaser) validateTarget(appImg imgutil.Image, newBaseImg imgutil.Image) error {
 	rebasable, err := appImg.Label(platform.RebasableLabel)
 	if err != nil {
 		return fmt.Errorf("get app image rebasable label: %w", err)
 	}
 	if rebasable == "false" {
+		msg, err := notRebasableMessage(appImg)
+		if err != nil {
+			return err
+		}
 		if !r.Force {
-			return fmt.Errorf(msgAppImageNotMarkedRebasable + "; " + msgProvideForceToOverride)
+			return errors.New(msg + "; " + msgProvideForceToOverride)
 		}
-		r.Logger.Warn(msgAppImageNotMarkedRebasable)
+		r.Logger.Warn(msg)
 	}
 
 	// check the OS, architecture, and variant values
@@ -243,6 +484,26 @@ func (r *Rebaser) validateTarget(appImg imgutil.Image, newBaseImg imgutil.Image)
 	return nil
 }
 
+// notRebasableMessage explains why the app image is not marked as rebasable.
+// The run image top layer recorded by the exporter is the top layer of the original (un-extended) run image,
+// so the exporter marks images whose run image was extended by image extensions as not rebasable:
+// rebasing them would replace the extension layers with the new base image.
+// Images built with extensions that only extended the build image are unaffected.
+func notRebasableMessage(appImg imgutil.Image) (string, error) {
+	var buildMD files.BuildMetadata
+	if err := image.DecodeLabel(appImg, platform.BuildMetadataLabel, &buildMD); err != nil {
+		return "", fmt.Errorf("get app image build metadata: %w", err)
+	}
+	if len(buildMD.Extensions) == 0 {
+		return msgAppImageNotMarkedRebasable, nil
+	}
+	var ids []string
+	for _, ext := range buildMD.Extensions {
+		ids = append(ids, ext.String())
+	}
+	return fmt.Sprintf(msgAppImageBuiltWithExtensions, strings.Join(ids, ", ")), nil
+}
+
 func (r *Rebaser) supportsManifestSize() bool {
 	return r.PlatformAPI.AtLeast("0.6")
 }
@@ -2,14 +2,21 @@ package lifecycle_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/memory"
+	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/fakes"
 	"github.com/buildpacks/imgutil/local"
 	"github.com/buildpacks/imgutil/remote"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -107,6 +114,7 @@ func testRebaser(t *testing.T, when spec.G, it spec.S) {
 				report, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
 				h.AssertNil(t, err)
 				h.AssertContains(t, report.Image.Tags, "some-repo/app-image", "some-repo/app-image:foo", "some-repo/app-image:bar")
+				h.AssertEq(t, report.Partial(), false)
 			})
 
 			it("sets the top layer in the metadata", func() {
@@ -396,6 +404,23 @@ func testRebaser(t *testing.T, when spec.G, it spec.S) {
 			})
 
 			when("report.toml", func() {
+				when("some tags cannot be saved", func() {
+					it("returns a partial report with the saved and failed tags", func() {
+						additionalNames = append(additionalNames, "some-repo/app-image:INVALID!")
+
+						report, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+						h.AssertError(t, err, "failed to write image to the following tags")
+
+						h.AssertEq(t, report.Partial(), true)
+						h.AssertEq(t, report.Rebased, true)
+						h.AssertEq(t, report.Image.Tags, []string{"some-repo/app-image", "some-repo/app-image:foo", "some-repo/app-image:bar"})
+						h.AssertEq(t, len(report.FailedTags), 1)
+						h.AssertEq(t, report.FailedTags[0].Tag, "some-repo/app-image:INVALID!")
+						h.AssertStringContains(t, report.FailedTags[0].Error, "INVALID!")
+						h.AssertEq(t, report.Error, err.Error())
+					})
+				})
+
 				when("image has a digest identifier", func() {
 					var fakeRemoteDigest = "sha256:c27a27006b74a056bed5d9edcebc394783880abe8691a8c87c78b7cffa6fa5ad"
 
@@ -480,6 +505,232 @@ func testRebaser(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("app image is already based on the new base image", func() {
+			it.Before(func() {
+				lifecycleMD := files.LayersMetadata{
+					RunImage: files.RunImageForRebase{
+						TopLayer:  "new-top-layer-sha",
+						Reference: "new-run-id",
+						RunImageForExport: files.RunImageForExport{
+							Image: fakeNewBaseImage.Name(),
+						},
+					},
+				}
+				label, err := json.Marshal(lifecycleMD)
+				h.AssertNil(t, err)
+				h.AssertNil(t, fakeAppImage.SetLabel(platform.LifecycleMetadataLabel, string(label)))
+			})
+
+			it("skips the save and reports the unchanged image", func() {
+				report, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), nil)
+				h.AssertNil(t, err)
+
+				h.AssertEq(t, report.Rebased, false)
+				h.AssertEq(t, report.Image.ImageID, "some-image-id")
+				h.AssertEq(t, fakeAppImage.IsSaved(), false)
+				assertLogEntry(t, logHandler, "App image is already based on run image 'new-run-id'; skipping rebase")
+			})
+
+			when("force", func() {
+				it.Before(func() {
+					rebaser.Force = true
+				})
+
+				it("rebases and saves the image", func() {
+					report, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), nil)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, report.Rebased, true)
+					h.AssertEq(t, fakeAppImage.IsSaved(), true)
+				})
+			})
+
+			when("the image is saved under other names", func() {
+				it("rebases and saves the image", func() {
+					report, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, "some-repo/app-image:prod", nil)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, report.Rebased, true)
+					h.AssertContains(t, fakeAppImage.SavedNames(), "some-repo/app-image:prod")
+				})
+			})
+		})
+
+		when("verifying layer order", func() {
+			var (
+				appImage       *layeredFakeImage
+				newBaseImage   *layeredFakeImage
+				appLayers      []v1.Layer
+				newBaseDiffIDs []string
+				appDiffIDs     []string
+			)
+
+			it.Before(func() {
+				oldBaseLayers := randomLayers(t, 2)
+				appLayers = randomLayers(t, 3)
+				newBaseLayers := randomLayers(t, 2)
+
+				appV1Image, err := mutate.AppendLayers(empty.Image, append(oldBaseLayers, appLayers...)...)
+				h.AssertNil(t, err)
+				newBaseV1Image, err := mutate.AppendLayers(empty.Image, newBaseLayers...)
+				h.AssertNil(t, err)
+				appImage = &layeredFakeImage{Image: fakeAppImage, image: appV1Image}
+				newBaseImage = &layeredFakeImage{Image: fakeNewBaseImage, image: newBaseV1Image}
+
+				oldBaseTopLayer, err := oldBaseLayers[1].DiffID()
+				h.AssertNil(t, err)
+				lifecycleMD := files.LayersMetadata{
+					RunImage: files.RunImageForRebase{
+						TopLayer: oldBaseTopLayer.String(),
+						RunImageForExport: files.RunImageForExport{
+							Image: fakeNewBaseImage.Name(),
+						},
+					},
+				}
+				label, err := json.Marshal(lifecycleMD)
+				h.AssertNil(t, err)
+				h.AssertNil(t, fakeAppImage.SetLabel(platform.LifecycleMetadataLabel, string(label)))
+
+				newBaseDiffIDs = diffIDsFor(t, newBaseLayers)
+				appDiffIDs = diffIDsFor(t, appLayers)
+			})
+
+			when("not verifying layers", func() {
+				it("does not verify the order of the app layers or report layers", func() {
+					appImage.reorder = true
+
+					report, err := rebaser.Rebase(appImage, newBaseImage, fakeAppImage.Name(), additionalNames)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, len(report.Layers), 0)
+					h.AssertEq(t, report.LayersBefore, 0)
+					h.AssertEq(t, report.LayersAfter, 0)
+				})
+			})
+
+			when("verifying layers", func() {
+				it.Before(func() {
+					rebaser.VerifyLayers = true
+				})
+
+				it("reports the layers of the rebased image with the app layers in their original order", func() {
+					report, err := rebaser.Rebase(appImage, newBaseImage, fakeAppImage.Name(), additionalNames)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, report.Layers, append(newBaseDiffIDs, appDiffIDs...))
+				})
+
+				it("reports the layer counts before and after rebasing", func() {
+					report, err := rebaser.Rebase(appImage, newBaseImage, fakeAppImage.Name(), additionalNames)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, report.LayersBefore, 5)
+					h.AssertEq(t, report.LayersAfter, 5)
+				})
+
+				when("the app layers are reordered", func() {
+					it.Before(func() {
+						appImage.reorder = true
+					})
+
+					it("errors", func() {
+						_, err := rebaser.Rebase(appImage, newBaseImage, fakeAppImage.Name(), additionalNames)
+						h.AssertError(t, err, fmt.Sprintf("verify rebase: app layers were not preserved in their original order: expected '%s' at position 0", appDiffIDs[0]))
+						h.AssertEq(t, fakeAppImage.IsSaved(), false)
+					})
+				})
+
+				when("layers from the old base image remain", func() {
+					it.Before(func() {
+						appImage.keepOldBase = true
+					})
+
+					it("errors", func() {
+						_, err := rebaser.Rebase(appImage, newBaseImage, fakeAppImage.Name(), additionalNames)
+						h.AssertError(t, err, "verify rebase: expected 5 layers (2 from the run image and 3 app layers), found 7; layers from the previous run image may remain")
+						h.AssertEq(t, fakeAppImage.IsSaved(), false)
+					})
+				})
+
+				when("the recorded run image top layer is not in the app image", func() {
+					it.Before(func() {
+						lifecycleMD := files.LayersMetadata{
+							RunImage: files.RunImageForRebase{
+								TopLayer: "sha256:some-missing-layer",
+								RunImageForExport: files.RunImageForExport{
+									Image: fakeNewBaseImage.Name(),
+								},
+							},
+						}
+						label, err := json.Marshal(lifecycleMD)
+						h.AssertNil(t, err)
+						h.AssertNil(t, fakeAppImage.SetLabel(platform.LifecycleMetadataLabel, string(label)))
+					})
+
+					it("errors before rebasing", func() {
+						_, err := rebaser.Rebase(appImage, newBaseImage, fakeAppImage.Name(), additionalNames)
+						h.AssertError(t, err, "get app image layers: base image top layer 'sha256:some-missing-layer' not found in image layers")
+						h.AssertEq(t, fakeAppImage.IsSaved(), false)
+					})
+				})
+
+				when("the images do not expose their layers", func() {
+					it("errors", func() {
+						_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+						h.AssertError(t, err, "verify rebase: layers of the app image and run image are unavailable")
+					})
+				})
+			})
+		})
+
+		when("checking ABI compatibility", func() {
+			it.Before(func() {
+				// for images built using platform API >= 0.12, target validation already requires the same distribution
+				h.AssertNil(t, fakeAppImage.SetEnv(platform.EnvPlatformAPI, "0.11"))
+				rebaser.CheckABICompatibility = true
+				h.AssertNil(t, fakeAppImage.SetLabel(platform.OSDistributionNameLabel, "ubuntu"))
+				h.AssertNil(t, fakeAppImage.SetLabel(platform.OSDistributionVersionLabel, "22.04"))
+				h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.OSDistributionNameLabel, "ubuntu"))
+			})
+
+			it("allows a new minor version of the distribution", func() {
+				h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.OSDistributionVersionLabel, "22.10"))
+
+				_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+				h.AssertNil(t, err)
+			})
+
+			it("errors when the major version of the distribution changes", func() {
+				h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.OSDistributionVersionLabel, "24.04"))
+
+				_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+				h.AssertError(t, err, "new base image distribution 'ubuntu@24.04' may not be compatible with the app image distribution 'ubuntu@22.04'; please provide -force to override")
+				h.AssertEq(t, fakeAppImage.IsSaved(), false)
+			})
+
+			it("errors when the distribution changes", func() {
+				h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.OSDistributionNameLabel, "debian"))
+				h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.OSDistributionVersionLabel, "22.04"))
+
+				_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+				h.AssertError(t, err, "new base image distribution 'debian@22.04' may not be compatible with the app image distribution 'ubuntu@22.04'")
+			})
+
+			it("warns when the major version changes and force is provided", func() {
+				rebaser.Force = true
+				h.AssertNil(t, fakeNewBaseImage.SetLabel(platform.OSDistributionVersionLabel, "24.04"))
+
+				_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+				h.AssertNil(t, err)
+				assertLogEntry(t, logHandler, "new base image distribution 'ubuntu@24.04' may not be compatible with the app image distribution 'ubuntu@22.04'")
+			})
+
+			it("skips the check when the distribution labels are missing", func() {
+				_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+				h.AssertNil(t, err)
+			})
+		})
+
 		when("validating rebasable", func() {
 			when("rebasable label is false", func() {
 				it.Before(func() {
@@ -553,6 +804,79 @@ func testRebaser(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("validating extensions", func() {
+			when("app image run image was extended", func() {
+				it.Before(func() {
+					h.AssertNil(t, fakeAppImage.SetLabel(platform.BuildMetadataLabel, `{"buildpacks": [], "extensions": [{"id": "some-extension-id", "version": "some-extension-version"}]}`))
+					h.AssertNil(t, fakeAppImage.SetLabel(platform.RebasableLabel, "false"))
+				})
+
+				when("force", func() {
+					when("false", func() {
+						it.Before(func() {
+							rebaser.Force = false
+						})
+
+						it("errors", func() {
+							_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+							h.AssertError(t, err, "app image run image was extended by image extensions (some-extension-id@some-extension-version)")
+							h.AssertError(t, err, "please provide -force to override")
+							h.AssertStringDoesNotContain(t, err.Error(), "app image is not marked as rebasable")
+						})
+					})
+
+					when("true", func() {
+						it.Before(func() {
+							rebaser.Force = true
+						})
+
+						it("warns and allows rebase", func() {
+							_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+							h.AssertNil(t, err)
+
+							assertLogEntry(t, logHandler, "app image run image was extended by image extensions")
+							h.AssertNoLogEntry(t, logHandler, "app image is not marked as rebasable")
+						})
+					})
+				})
+			})
+
+			when("app image was built with extensions that did not extend the run image", func() {
+				it.Before(func() {
+					h.AssertNil(t, fakeAppImage.SetLabel(platform.BuildMetadataLabel, `{"buildpacks": [], "extensions": [{"id": "some-extension-id", "version": "some-extension-version"}]}`))
+					h.AssertNil(t, fakeAppImage.SetLabel(platform.RebasableLabel, "true"))
+				})
+
+				it("allows rebase", func() {
+					_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+					h.AssertNil(t, err)
+				})
+			})
+
+			when("platform API < 0.12", func() {
+				it.Before(func() {
+					rebaser.PlatformAPI = api.MustParse("0.11")
+					h.AssertNil(t, fakeAppImage.SetLabel(platform.BuildMetadataLabel, `{"buildpacks": [], "extensions": [{"id": "some-extension-id", "version": "some-extension-version"}]}`))
+				})
+
+				it("allows rebase of images built with extensions", func() {
+					_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+					h.AssertNil(t, err)
+				})
+			})
+
+			when("app image was built without extensions", func() {
+				it.Before(func() {
+					h.AssertNil(t, fakeAppImage.SetLabel(platform.BuildMetadataLabel, `{"buildpacks": [{"id": "some-buildpack-id", "version": "some-buildpack-version"}]}`))
+				})
+
+				it("allows rebase", func() {
+					_, err := rebaser.Rebase(fakeAppImage, fakeNewBaseImage, fakeAppImage.Name(), additionalNames)
+					h.AssertNil(t, err)
+				})
+			})
+		})
+
 		when("validating mixins", func() {
 			when("mixins are missing on the run image", func() {
 				it("allows rebase", func() {
@@ -871,3 +1195,85 @@ func testRebaser(t *testing.T, when spec.G, it spec.S) {
 		})
 	})
 }
+
+// layeredFakeImage is a fake image that exposes an underlying v1.Image, so that the order of its layers can be verified.
+type layeredFakeImage struct {
+	*fakes.Image
+	image       v1.Image
+	reorder     bool
+	keepOldBase bool
+}
+
+func (i *layeredFakeImage) UnderlyingImage() v1.Image {
+	return i.image
+}
+
+func (i *layeredFakeImage) TopLayer() (string, error) {
+	layers, err := i.image.Layers()
+	if err != nil {
+		return "", err
+	}
+	diffID, err := layers[len(layers)-1].DiffID()
+	return diffID.String(), err
+}
+
+// Rebase replaces the layers up to and including baseTopLayer with the layers of newBase.
+// If reorder is set, the app layers are re-applied in reverse order.
+// If keepOldBase is set, the old base layers are left beneath the layers of newBase.
+func (i *layeredFakeImage) Rebase(baseTopLayer string, newBase imgutil.Image) error {
+	layers, err := i.image.Layers()
+	if err != nil {
+		return err
+	}
+	var oldBaseLayers, appLayers []v1.Layer
+	for idx, layer := range layers {
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return err
+		}
+		if diffID.String() == baseTopLayer {
+			oldBaseLayers = append(oldBaseLayers, layers[:idx+1]...)
+			appLayers = append(appLayers, layers[idx+1:]...)
+			break
+		}
+	}
+	if i.reorder {
+		for left, right := 0, len(appLayers)-1; left < right; left, right = left+1, right-1 {
+			appLayers[left], appLayers[right] = appLayers[right], appLayers[left]
+		}
+	}
+	base := newBase.(*layeredFakeImage).image
+	if i.keepOldBase {
+		newBaseLayers, err := base.Layers()
+		if err != nil {
+			return err
+		}
+		if base, err = mutate.AppendLayers(empty.Image, append(oldBaseLayers, newBaseLayers...)...); err != nil {
+			return err
+		}
+	}
+	if i.image, err = mutate.AppendLayers(base, appLayers...); err != nil {
+		return err
+	}
+	return i.Image.Rebase(baseTopLayer, newBase)
+}
+
+func randomLayers(t *testing.T, n int) []v1.Layer {
+	var layers []v1.Layer
+	for idx := 0; idx < n; idx++ {
+		layer, err := random.Layer(64, types.DockerLayer)
+		h.AssertNil(t, err)
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+func diffIDsFor(t *testing.T, layers []v1.Layer) []string {
+	var diffIDs []string
+	for _, layer := range layers {
+		diffID, err := layer.DiffID()
+		h.AssertNil(t, err)
+		diffIDs = append(diffIDs, diffID.String())
+	}
+	return diffIDs
+}
@@ -0,0 +1,94 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/buildpack"
+	"github.com/buildpacks/lifecycle/internal/str"
+)
+
+// ValidateBuildpackDir checks that the buildpack at the provided path could be run by this lifecycle,
+// without performing a build.
+// It verifies that buildpack.toml is readable, that its buildpack API is valid and supported,
+// that the required fields are present, and (for component buildpacks) that bin/build is an executable file.
+// All problems found are returned together as a single error.
+func ValidateBuildpackDir(path string) error {
+	descriptor, err := buildpack.ReadBpDescriptor(filepath.Join(path, "buildpack.toml"))
+	if err != nil {
+		return fmt.Errorf("read buildpack descriptor: %w", err)
+	}
+
+	var errs []error
+	if descriptor.WithAPI == "" {
+		errs = append(errs, errors.New("missing required field 'api'"))
+	} else if bpAPI, err := api.NewVersion(descriptor.WithAPI); err != nil {
+		errs = append(errs, fmt.Errorf("parse buildpack API '%s': %w", descriptor.WithAPI, err))
+	} else if !api.Buildpack.IsSupported(bpAPI) {
+		errs = append(errs, fmt.Errorf("buildpack API version '%s' is incompatible with the lifecycle", descriptor.WithAPI))
+	}
+	if descriptor.Buildpack.ID == "" {
+		errs = append(errs, errors.New("missing required field 'buildpack.id'"))
+	}
+	if descriptor.Buildpack.Version == "" {
+		errs = append(errs, errors.New("missing required field 'buildpack.version'"))
+	}
+	if len(descriptor.Order) == 0 {
+		if err = validateExecutable(filepath.Join(path, "bin", "build")); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateMixins checks that the run image mixins the buildpack declares for stackID in the stacks section of its buildpack.toml
+// are all present in providedMixins. A stack with ID "*" matches any stack, and its mixins are also required.
+// Mixins prefixed with "build:" are only required at build time and are ignored; "run:" prefixes are removed before comparing.
+// If any mixins are missing, the returned error lists them.
+func ValidateMixins(bp *buildpack.BpDescriptor, stackID string, providedMixins []string) error {
+	var required []string
+	for _, stack := range bp.Stacks {
+		if stack.ID != stackID && stack.ID != "*" {
+			continue
+		}
+		for _, m := range stack.Mixins {
+			if strings.HasPrefix(m, "build:") {
+				continue
+			}
+			required = append(required, m)
+		}
+	}
+	if len(required) == 0 {
+		return nil
+	}
+
+	_, missing, _ := str.Compare(removeStagePrefixes(providedMixins), removeStagePrefixes(required))
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("buildpack %s is missing required mixin(s) for stack %s: %s", bp.Buildpack.ID, stackID, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func validateExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("missing executable '%s'", path)
+		}
+		return fmt.Errorf("stat '%s': %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("expected '%s' to be a file, found a directory", path)
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return fmt.Errorf("'%s' is not executable", path)
+	}
+	return nil
+}
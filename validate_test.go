@@ -0,0 +1,221 @@
+package lifecycle_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	"github.com/buildpacks/lifecycle/buildpack"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestValidate(t *testing.T) {
+	spec.Run(t, "Validate", testValidate, spec.Report(report.Terminal{}))
+}
+
+func testValidate(t *testing.T, when spec.G, it spec.S) {
+	when(".ValidateBuildpackDir", func() {
+		var bpDir string
+
+		it.Before(func() {
+			var err error
+			bpDir, err = os.MkdirTemp("", "lifecycle.validate")
+			h.AssertNil(t, err)
+			h.Mkdir(t, filepath.Join(bpDir, "bin"))
+		})
+
+		it.After(func() {
+			_ = os.RemoveAll(bpDir)
+		})
+
+		writeBuildpackTOML := func(contents string) {
+			h.Mkfile(t, contents, filepath.Join(bpDir, "buildpack.toml"))
+		}
+
+		writeBuildExecutable := func(mode os.FileMode) {
+			path := filepath.Join(bpDir, "bin", "build")
+			h.Mkfile(t, "#!/usr/bin/env bash\n", path)
+			h.AssertNil(t, os.Chmod(path, mode))
+		}
+
+		when("the buildpack is valid", func() {
+			it("succeeds", func() {
+				writeBuildpackTOML(`
+api = "0.9"
+[buildpack]
+  id = "some-buildpack-id"
+  version = "some-buildpack-version"
+`)
+				writeBuildExecutable(0755)
+
+				h.AssertNil(t, lifecycle.ValidateBuildpackDir(bpDir))
+			})
+		})
+
+		when("the buildpack is a composite buildpack", func() {
+			it("does not require bin/build", func() {
+				writeBuildpackTOML(`
+api = "0.9"
+[buildpack]
+  id = "some-buildpack-id"
+  version = "some-buildpack-version"
+
+[[order]]
+  [[order.group]]
+    id = "some-other-buildpack-id"
+    version = "some-other-buildpack-version"
+`)
+
+				h.AssertNil(t, lifecycle.ValidateBuildpackDir(bpDir))
+			})
+		})
+
+		when("buildpack.toml is missing", func() {
+			it("errors", func() {
+				err := lifecycle.ValidateBuildpackDir(bpDir)
+				h.AssertError(t, err, "read buildpack descriptor")
+			})
+		})
+
+		when("the buildpack API is not supported", func() {
+			it("errors", func() {
+				writeBuildpackTOML(`
+api = "0.1"
+[buildpack]
+  id = "some-buildpack-id"
+  version = "some-buildpack-version"
+`)
+				writeBuildExecutable(0755)
+
+				err := lifecycle.ValidateBuildpackDir(bpDir)
+				h.AssertError(t, err, "buildpack API version '0.1' is incompatible with the lifecycle")
+			})
+		})
+
+		when("the buildpack API cannot be parsed", func() {
+			it("errors", func() {
+				writeBuildpackTOML(`
+api = "some-api"
+[buildpack]
+  id = "some-buildpack-id"
+  version = "some-buildpack-version"
+`)
+				writeBuildExecutable(0755)
+
+				err := lifecycle.ValidateBuildpackDir(bpDir)
+				h.AssertError(t, err, "parse buildpack API 'some-api'")
+			})
+		})
+
+		when("bin/build is not executable", func() {
+			it("errors", func() {
+				if runtime.GOOS == "windows" {
+					t.Skip("executable bits are not checked on Windows")
+				}
+				writeBuildpackTOML(`
+api = "0.9"
+[buildpack]
+  id = "some-buildpack-id"
+  version = "some-buildpack-version"
+`)
+				writeBuildExecutable(0644)
+
+				err := lifecycle.ValidateBuildpackDir(bpDir)
+				h.AssertError(t, err, "is not executable")
+			})
+		})
+
+		when("there are multiple problems", func() {
+			it("reports all of them", func() {
+				writeBuildpackTOML(`
+[buildpack]
+  id = "some-buildpack-id"
+`)
+
+				err := lifecycle.ValidateBuildpackDir(bpDir)
+				h.AssertError(t, err, "missing required field 'api'")
+				h.AssertError(t, err, "missing required field 'buildpack.version'")
+				h.AssertError(t, err, "missing executable")
+			})
+		})
+	})
+	when(".ValidateMixins", func() {
+		var descriptor *buildpack.BpDescriptor
+
+		it.Before(func() {
+			descriptor = &buildpack.BpDescriptor{
+				Buildpack: buildpack.BpInfo{BaseInfo: buildpack.BaseInfo{ID: "some-buildpack-id"}},
+				Stacks: []buildpack.StackMetadata{
+					{ID: "some-stack-id", Mixins: []string{"mixin-a", "run:mixin-b", "build:mixin-c"}},
+				},
+			}
+		})
+
+		it("succeeds when all run mixins are provided", func() {
+			h.AssertNil(t, lifecycle.ValidateMixins(descriptor, "some-stack-id", []string{"mixin-a", "mixin-b"}))
+		})
+
+		it("ignores stage prefixes on the provided mixins", func() {
+			h.AssertNil(t, lifecycle.ValidateMixins(descriptor, "some-stack-id", []string{"run:mixin-a", "run:mixin-b"}))
+		})
+
+		it("returns the missing mixins", func() {
+			err := lifecycle.ValidateMixins(descriptor, "some-stack-id", []string{"mixin-c"})
+			h.AssertError(t, err, "buildpack some-buildpack-id is missing required mixin(s) for stack some-stack-id: mixin-a, mixin-b")
+		})
+
+		it("only requires the mixins of the stack being built on", func() {
+			descriptor.Stacks = []buildpack.StackMetadata{
+				{ID: "some-stack-id", Mixins: []string{"mixin-a"}},
+				{ID: "some-other-stack-id", Mixins: []string{"mixin-b"}},
+			}
+
+			h.AssertNil(t, lifecycle.ValidateMixins(descriptor, "some-stack-id", []string{"mixin-a"}))
+			h.AssertNil(t, lifecycle.ValidateMixins(descriptor, "some-other-stack-id", []string{"mixin-b"}))
+			err := lifecycle.ValidateMixins(descriptor, "some-other-stack-id", []string{"mixin-a"})
+			h.AssertError(t, err, "missing required mixin(s) for stack some-other-stack-id: mixin-b")
+		})
+
+		it("requires the mixins of a wildcard stack", func() {
+			descriptor.Stacks = []buildpack.StackMetadata{
+				{ID: "*", Mixins: []string{"mixin-a"}},
+				{ID: "some-other-stack-id", Mixins: []string{"mixin-b"}},
+			}
+
+			h.AssertNil(t, lifecycle.ValidateMixins(descriptor, "some-stack-id", []string{"mixin-a"}))
+			err := lifecycle.ValidateMixins(descriptor, "some-stack-id", nil)
+			h.AssertError(t, err, "missing required mixin(s) for stack some-stack-id: mixin-a")
+		})
+
+		it("succeeds when no mixins are declared", func() {
+			descriptor.Stacks = nil
+			h.AssertNil(t, lifecycle.ValidateMixins(descriptor, "some-stack-id", nil))
+		})
+
+		it("reads mixins from buildpack.toml", func() {
+			tmpDir, err := os.MkdirTemp("", "lifecycle.validate")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(tmpDir)
+			path := filepath.Join(tmpDir, "buildpack.toml")
+			h.Mkfile(t, `
+api = "0.9"
+[buildpack]
+  id = "some-buildpack-id"
+  version = "some-buildpack-version"
+[[stacks]]
+  id = "some-stack-id"
+  mixins = ["mixin-a"]
+`, path)
+			descriptor, err = buildpack.ReadBpDescriptor(path)
+			h.AssertNil(t, err)
+
+			err = lifecycle.ValidateMixins(descriptor, "some-stack-id", nil)
+			h.AssertError(t, err, "missing required mixin(s) for stack some-stack-id: mixin-a")
+		})
+	})
+}